@@ -0,0 +1,80 @@
+package binlog
+
+// InsertStatement is the rows inserted into a table by a statement,
+// which may be logged as several WRITE_ROWS_EVENTs, e.g. emit one bulk INSERT per statement
+type InsertStatement struct {
+	Schema  string
+	Table   string
+	Columns []string        // the present columns, in the order of table columns
+	Values  [][]interface{} // one slice per row, aligned with Columns
+}
+
+// InsertBatcher aggregate the WRITE_ROWS_EVENTs of a statement into InsertStatements,
+// UPDATE_ROWS_EVENT and DELETE_ROWS_EVENT are ignored, the zero value is ready to use.
+type InsertBatcher struct {
+	statements []*InsertStatement
+}
+
+// Add will add a rows event into the statement in progress, return the InsertStatements of statement
+// when the event has STMT_END_F, one for each table (e.g. the tables inserted by triggers) in order
+func (b *InsertBatcher) Add(e *BinRowsEvent) []*InsertStatement {
+	if e.Action() == RowsActionInsert && e.tableMap != nil && len(e.Rows) > 0 {
+		b.add(e)
+	}
+	if !e.StmtEnd() {
+		return nil
+	}
+	statements := b.statements
+	b.statements = nil
+	return statements
+}
+
+// Flush return the InsertStatements in progress, e.g. the binary log ends before STMT_END_F
+func (b *InsertBatcher) Flush() []*InsertStatement {
+	statements := b.statements
+	b.statements = nil
+	return statements
+}
+
+// add will append the rows of event to the statement of the same table and columns
+func (b *InsertBatcher) add(e *BinRowsEvent) {
+	table := e.tableMap
+	var columns []string
+	for i := 0; i < int(e.ColumnCount); i++ {
+		if e.ColumnsBitmap1.isSet(uint(i)) {
+			columns = append(columns, table.ColumnName(i))
+		}
+	}
+
+	var statement *InsertStatement
+	for _, s := range b.statements {
+		if s.Schema == table.Schema && s.Table == table.Table && equalStrings(s.Columns, columns) {
+			statement = s
+			break
+		}
+	}
+	if statement == nil {
+		statement = &InsertStatement{Schema: table.Schema, Table: table.Table, Columns: columns}
+		b.statements = append(b.statements, statement)
+	}
+
+	for _, row := range e.Rows {
+		values := make([]interface{}, len(columns))
+		for i, column := range columns {
+			values[i] = row[column]
+		}
+		statement.Values = append(statement.Values, values)
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,71 @@
+package binlog
+
+// collations of charsets, the collation ids are the ids of INFORMATION_SCHEMA.COLLATIONS
+// https://github.com/mysql/mysql-server/blob/8.0/strings (CHARSET_INFO)
+var charsetCollations = map[string][]uint16{
+	"big5":     {1, 84},
+	"dec8":     {3, 69},
+	"cp850":    {4, 80},
+	"hp8":      {6, 72},
+	"koi8r":    {7, 74},
+	"latin1":   {5, 8, 15, 31, 47, 48, 49, 94},
+	"latin2":   {2, 9, 21, 27, 77},
+	"swe7":     {10, 82},
+	"ascii":    {11, 65},
+	"ujis":     {12, 91},
+	"sjis":     {13, 88},
+	"cp1251":   {14, 23, 50, 51, 52},
+	"hebrew":   {16, 71},
+	"tis620":   {18, 89},
+	"euckr":    {19, 85},
+	"latin7":   {20, 41, 42, 79},
+	"koi8u":    {22, 75},
+	"gb2312":   {24, 86},
+	"greek":    {25, 70},
+	"cp1250":   {26, 34, 44, 66, 99},
+	"gbk":      {28, 87},
+	"cp1257":   {29, 58, 59},
+	"latin5":   {30, 78},
+	"armscii8": {32, 64},
+	"utf8":     append([]uint16{33, 76, 83, 223}, collationRange(192, 215)...),
+	"ucs2":     append([]uint16{35, 90, 159}, collationRange(128, 151)...),
+	"cp866":    {36, 68},
+	"keybcs2":  {37, 73},
+	"macce":    {38, 43},
+	"macroman": {39, 53},
+	"cp852":    {40, 81},
+	"utf8mb4":  append(append([]uint16{45, 46}, collationRange(224, 247)...), collationRange(255, 323)...),
+	"utf16":    append([]uint16{54, 55}, collationRange(101, 124)...),
+	"utf16le":  {56, 62},
+	"cp1256":   {57, 67},
+	"utf32":    append([]uint16{60, 61}, collationRange(160, 183)...),
+	"binary":   {63},
+	"geostd8":  {92, 93},
+	"cp932":    {95, 96},
+	"eucjpms":  {97, 98},
+	"gb18030":  {248, 249, 250},
+}
+
+// collationCharsets mapping collation id => charset name
+var collationCharsets = func() map[uint16]string {
+	charsets := make(map[uint16]string)
+	for charset, collations := range charsetCollations {
+		for _, id := range collations {
+			charsets[id] = charset
+		}
+	}
+	return charsets
+}()
+
+func collationRange(from, to uint16) []uint16 {
+	ids := make([]uint16, 0, to-from+1)
+	for id := from; id <= to; id++ {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// CharsetName return the charset name of collation id, e.g. utf8mb4 of 45, empty if unknown
+func CharsetName(collationID uint16) string {
+	return collationCharsets[collationID]
+}
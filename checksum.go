@@ -3,9 +3,12 @@ package binlog
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
+	"fmt"
 	"hash/crc32"
 	"strconv"
 	"strings"
+	"sync"
 	"unicode"
 )
 
@@ -21,11 +24,31 @@ import (
 // This option was added in MySQL 5.6.2.
 const binlogChecksumLength = 4
 
+// checksumLengths mapping binlog checksum algorithm to the length of its checksum trailer
+var checksumLengths = map[byte]int{
+	BinlogChecksumAlgOff:   0,
+	BinlogChecksumAlgUndef: 0,
+	BinlogChecksumAlgCRC32: binlogChecksumLength,
+}
+
+// ChecksumLength return the length of checksum trailer of the algorithm, false if the algorithm is unknown,
+// see RegisterChecksumValidator
+func ChecksumLength(checksumType byte) (int, bool) {
+	checksumMu.RLock()
+	defer checksumMu.RUnlock()
+	length, ok := checksumLengths[checksumType]
+	return length, ok
+}
+
 var mysqlChecksumVersion = 5<<10<<10 + 6<<10 + 2
 
+// mysqlVersion return the version number of server version, e.g. 5.6.2-log, 0 if unparseable
 func mysqlVersion(versionStr string) int {
 	var version int
 	split := strings.Split(versionStr, ".")
+	if len(split) < 2 {
+		return 0
+	}
 	f, _ := strconv.Atoi(split[0])
 	s, _ := strconv.Atoi(split[1])
 	version = f<<10<<10 + s<<10
@@ -33,7 +56,7 @@ func mysqlVersion(versionStr string) int {
 		return version
 	}
 
-	index := 0
+	index := len(split[2])
 	for i, c := range split[2] {
 		if !unicode.IsNumber(c) {
 			index = i
@@ -50,20 +73,75 @@ func hasChecksum(versionStr string) bool {
 	return mysqlVersion(versionStr) >= mysqlChecksumVersion
 }
 
-// ChecksumValidate will validate binary log event checksum
+// ErrChecksumFailed is returned when the event checksum mismatch
+var ErrChecksumFailed = errors.New("binlog checksum validation failed")
+
+// ChecksumValidator validate the data with the checksum value stored in the event trailer
+type ChecksumValidator func(expectedChecksum []byte, data []byte) bool
+
+// checksumMu guards the registry of checksum algorithms, the decoders read it concurrently
+var checksumMu sync.RWMutex
+
+// checksumValidators mapping binlog checksum algorithm to its validator
+var checksumValidators = map[byte]ChecksumValidator{
+	BinlogChecksumAlgOff:   noneValidate,
+	BinlogChecksumAlgUndef: noneValidate,
+	BinlogChecksumAlgCRC32: crc32Validate,
+}
+
+// ChecksumAlgorithmName return the name of binlog_checksum, NONE, CRC32 or UNKNOWN(n)
+func ChecksumAlgorithmName(checksumType byte) string {
+	switch checksumType {
+	case BinlogChecksumAlgOff:
+		return "NONE"
+	case BinlogChecksumAlgCRC32:
+		return "CRC32"
+	}
+	return fmt.Sprintf("UNKNOWN(%d)", checksumType)
+}
+
+// RegisterChecksumValidator will register a validator for the checksum algorithm with the length of
+// its checksum trailer, the validator and length registered before will be replaced,
+// and a nil validator unregisters the algorithm. It is safe to call concurrently with decoding.
+func RegisterChecksumValidator(checksumType byte, length int, validator ChecksumValidator) {
+	checksumMu.Lock()
+	defer checksumMu.Unlock()
+	if validator == nil {
+		delete(checksumValidators, checksumType)
+		delete(checksumLengths, checksumType)
+		return
+	}
+	checksumLengths[checksumType] = length
+	checksumValidators[checksumType] = validator
+}
+
+// ChecksumValidate will validate binary log event checksum, return false if mismatch.
+// The checksum of unknown algorithm is not validated, see VerifyChecksum for the reason of failure.
+func ChecksumValidate(checksumType byte, expectedChecksum []byte, data []byte) bool {
+	return !errors.Is(VerifyChecksum(checksumType, expectedChecksum, data), ErrChecksumFailed)
+}
+
+// VerifyChecksum will validate binary log event checksum by the registered validator,
+// return ErrChecksumFailed if mismatch, or an error if the algorithm is unknown.
 // This information is from 'github.com/siddontang/go-mysql/replication/parser.go'
 // mysql use zlib's CRC32 implementation, which uses polynomial 0xedb88320UL.
 // reference: https://github.com/madler/zlib/blob/master/crc32.c
 // https://github.com/madler/zlib/blob/master/doc/rfc1952.txt#L419
-func ChecksumValidate(checksumType byte, expectedChecksum []byte, data []byte) bool {
-	switch checksumType {
-	case BinlogChecksumAlgCRC32:
-		return crc32Validate(expectedChecksum, data)
-	case BinlogChecksumAlgUndef, BinlogChecksumAlgOff:
-		fallthrough
-	default:
-		return true
+func VerifyChecksum(checksumType byte, expectedChecksum []byte, data []byte) error {
+	checksumMu.RLock()
+	validator, ok := checksumValidators[checksumType]
+	checksumMu.RUnlock()
+	if !ok {
+		return fmt.Errorf("unsupported binlog checksum algorithm %d", checksumType)
+	}
+	if !validator(expectedChecksum, data) {
+		return ErrChecksumFailed
 	}
+	return nil
+}
+
+func noneValidate(expectedChecksum []byte, data []byte) bool {
+	return true
 }
 
 func crc32Validate(expectedChecksum []byte, data []byte) bool {
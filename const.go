@@ -1,5 +1,7 @@
 package binlog
 
+import "fmt"
+
 // https://dev.mysql.com/doc/internals/en/com-query-response.html#packet-Protocol::MYSQL_TYPE_STRING
 const (
 	MySQLTypeDecimal   = 0x00
@@ -25,6 +27,9 @@ const (
 	MySQLTypeDatetime2  = 0x12
 	MySQLTypeTime2      = 0x13
 
+	// mysql 8.0, the multi-valued index, only in binary log
+	MySQLTypeTypedArray = 0xf4
+
 	// start with 0xf5
 	MySQLTypeJSON       = 0xf5
 	MySQLTypeNewDecimal = 0xf6
@@ -39,84 +44,128 @@ const (
 	MySQLTypeGeometry   = 0xff
 )
 
+// EventType is the type of binary log event
+type EventType uint8
+
+// String return the name of event type, e.g. QUERY_EVENT
+func (t EventType) String() string {
+	if name, ok := EventType2Str[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("UNKNOWN_EVENT(%#x)", uint8(t))
+}
+
+// sql_mode bits of Q_SQL_MODE_CODE
+// https://github.com/mysql/mysql-server/blob/8.0/sql/system_variables.h
+const (
+	SQLModeANSIQuotes         uint64 = 1 << 2
+	SQLModeNoBackslashEscapes uint64 = 1 << 20
+)
+
+// rowsRelated return bool of if the event is TABLE_MAP_EVENT or ROWS_EVENT
+func (t EventType) rowsRelated() bool {
+	switch t {
+	case TableMapEvent,
+		WriteRowsEventV0, UpdateRowsEventV0, DeleteRowsEventV0,
+		WriteRowsEventV1, UpdateRowsEventV1, DeleteRowsEventV1,
+		WriteRowsEventV2, UpdateRowsEventV2, DeleteRowsEventV2:
+		return true
+	}
+	return false
+}
+
 // https://dev.mysql.com/doc/internals/en/binlog-event-type.html
 const (
-	UnknownEvent           = 0x00
-	StartEventV3           = 0x01
-	QueryEvent             = 0x02
-	StopEvent              = 0x03
-	RotateEvent            = 0x04
-	IntvarEvent            = 0x05
-	LoadEvent              = 0x06
-	SlaveEvent             = 0x07
-	CreateFileEvent        = 0x08
-	AppendBlockEvent       = 0x09
-	ExecLoadEvent          = 0x0a
-	DeleteFileEvent        = 0x0b
-	NewLoadEvent           = 0x0c
-	RandEvent              = 0x0d
-	UserVarEvent           = 0x0e
-	FormatDescriptionEvent = 0x0f
-	XIDEvent               = 0x10
-	BeginLoadQueryEvent    = 0x11
-	ExecuteLoadQueryEvent  = 0x12
-	TableMapEvent          = 0x13
-	WriteRowsEventV0       = 0x14
-	UpdateRowsEventV0      = 0x15
-	DeleteRowsEventV0      = 0x16
-	WriteRowsEventV1       = 0x17
-	UpdateRowsEventV1      = 0x18
-	DeleteRowsEventV1      = 0x19
-	IncidentEvent          = 0x1a
-	HeartbeatEvent         = 0x1b
-	IgnorableEvent         = 0x1c
-	RowsQueryEvent         = 0x1d
-	WriteRowsEventV2       = 0x1e
-	UpdateRowsEventV2      = 0x1f
-	DeleteRowsEventV2      = 0x20
-	GTIDEvent              = 0x21
-	AnonymousGTIDEvent     = 0x22
-	PreviousGTIDEvent      = 0x23
+	UnknownEvent            EventType = 0x00
+	StartEventV3            EventType = 0x01
+	QueryEvent              EventType = 0x02
+	StopEvent               EventType = 0x03
+	RotateEvent             EventType = 0x04
+	IntvarEvent             EventType = 0x05
+	LoadEvent               EventType = 0x06
+	SlaveEvent              EventType = 0x07
+	CreateFileEvent         EventType = 0x08
+	AppendBlockEvent        EventType = 0x09
+	ExecLoadEvent           EventType = 0x0a
+	DeleteFileEvent         EventType = 0x0b
+	NewLoadEvent            EventType = 0x0c
+	RandEvent               EventType = 0x0d
+	UserVarEvent            EventType = 0x0e
+	FormatDescriptionEvent  EventType = 0x0f
+	XIDEvent                EventType = 0x10
+	BeginLoadQueryEvent     EventType = 0x11
+	ExecuteLoadQueryEvent   EventType = 0x12
+	TableMapEvent           EventType = 0x13
+	WriteRowsEventV0        EventType = 0x14
+	UpdateRowsEventV0       EventType = 0x15
+	DeleteRowsEventV0       EventType = 0x16
+	WriteRowsEventV1        EventType = 0x17
+	UpdateRowsEventV1       EventType = 0x18
+	DeleteRowsEventV1       EventType = 0x19
+	IncidentEvent           EventType = 0x1a
+	HeartbeatEvent          EventType = 0x1b
+	IgnorableEvent          EventType = 0x1c
+	RowsQueryEvent          EventType = 0x1d
+	WriteRowsEventV2        EventType = 0x1e
+	UpdateRowsEventV2       EventType = 0x1f
+	DeleteRowsEventV2       EventType = 0x20
+	GTIDEvent               EventType = 0x21
+	AnonymousGTIDEvent      EventType = 0x22
+	PreviousGTIDEvent       EventType = 0x23
+	TransactionContextEvent EventType = 0x24
+	ViewChangeEvent         EventType = 0x25
+	XAPrepareLogEvent       EventType = 0x26
+
+	// mysql 8.0
+	PartialUpdateRowsEvent  EventType = 0x27
+	TransactionPayloadEvent EventType = 0x28
+	HeartbeatLogEventV2     EventType = 0x29 // 8.0.26
 )
 
 // EventType2Str mapping the name of binary log event type
-var EventType2Str = map[uint8]string{
-	UnknownEvent:           "UNKNOWN_EVENT",
-	StartEventV3:           "START_EVENT_V3",
-	QueryEvent:             "QUERY_EVENT",
-	StopEvent:              "STOP_EVENT",
-	RotateEvent:            "ROTATE_EVENT",
-	IntvarEvent:            "INTVAR_EVENT",
-	LoadEvent:              "LOAD_EVENT",
-	SlaveEvent:             "SLAVE_EVENT",
-	CreateFileEvent:        "CREATE_FILE_EVENT",
-	AppendBlockEvent:       "APPEND_BLOCK_EVENT",
-	ExecLoadEvent:          "EXEC_LOAD_EVENT",
-	DeleteFileEvent:        "DELETE_FILE_EVENT",
-	NewLoadEvent:           "NEW_LOAD_EVENT",
-	RandEvent:              "RAND_EVENT",
-	UserVarEvent:           "USER_VAR_EVENT",
-	FormatDescriptionEvent: "FORMAT_DESCRIPTION_EVENT",
-	XIDEvent:               "XID_EVENT",
-	BeginLoadQueryEvent:    "BEGIN_LOAD_QUERY_EVENT",
-	ExecuteLoadQueryEvent:  "EXECUTE_LOAD_QUERY_EVENT",
-	TableMapEvent:          "TABLE_MAP_EVENT",
-	WriteRowsEventV0:       "WRITE_ROWS_EVENTv0",
-	UpdateRowsEventV0:      "UPDATE_ROWS_EVENTv0",
-	DeleteRowsEventV0:      "DELETE_ROWS_EVENTv0",
-	WriteRowsEventV1:       "WRITE_ROWS_EVENTv1",
-	UpdateRowsEventV1:      "UPDATE_ROWS_EVENTv1",
-	DeleteRowsEventV1:      "DELETE_ROWS_EVENTv1",
-	IncidentEvent:          "INCIDENT_EVENT",
-	HeartbeatEvent:         "HEARTBEAT_EVENT",
-	IgnorableEvent:         "IGNORABLE_EVENT",
-	RowsQueryEvent:         "ROWS_QUERY_EVENT",
-	WriteRowsEventV2:       "WRITE_ROWS_EVENTv2",
-	UpdateRowsEventV2:      "UPDATE_ROWS_EVENTv2",
-	DeleteRowsEventV2:      "DELETE_ROWS_EVENTv2",
-	GTIDEvent:              "GTID_EVENT",
-	AnonymousGTIDEvent:     "ANONYMOUS_GTID_EVENT",
-	PreviousGTIDEvent:      "PREVIOUS_GTIDS_EVENT",
+var EventType2Str = map[EventType]string{
+	UnknownEvent:            "UNKNOWN_EVENT",
+	StartEventV3:            "START_EVENT_V3",
+	QueryEvent:              "QUERY_EVENT",
+	StopEvent:               "STOP_EVENT",
+	RotateEvent:             "ROTATE_EVENT",
+	IntvarEvent:             "INTVAR_EVENT",
+	LoadEvent:               "LOAD_EVENT",
+	SlaveEvent:              "SLAVE_EVENT",
+	CreateFileEvent:         "CREATE_FILE_EVENT",
+	AppendBlockEvent:        "APPEND_BLOCK_EVENT",
+	ExecLoadEvent:           "EXEC_LOAD_EVENT",
+	DeleteFileEvent:         "DELETE_FILE_EVENT",
+	NewLoadEvent:            "NEW_LOAD_EVENT",
+	RandEvent:               "RAND_EVENT",
+	UserVarEvent:            "USER_VAR_EVENT",
+	FormatDescriptionEvent:  "FORMAT_DESCRIPTION_EVENT",
+	XIDEvent:                "XID_EVENT",
+	BeginLoadQueryEvent:     "BEGIN_LOAD_QUERY_EVENT",
+	ExecuteLoadQueryEvent:   "EXECUTE_LOAD_QUERY_EVENT",
+	TableMapEvent:           "TABLE_MAP_EVENT",
+	WriteRowsEventV0:        "WRITE_ROWS_EVENTv0",
+	UpdateRowsEventV0:       "UPDATE_ROWS_EVENTv0",
+	DeleteRowsEventV0:       "DELETE_ROWS_EVENTv0",
+	WriteRowsEventV1:        "WRITE_ROWS_EVENTv1",
+	UpdateRowsEventV1:       "UPDATE_ROWS_EVENTv1",
+	DeleteRowsEventV1:       "DELETE_ROWS_EVENTv1",
+	IncidentEvent:           "INCIDENT_EVENT",
+	HeartbeatEvent:          "HEARTBEAT_EVENT",
+	IgnorableEvent:          "IGNORABLE_EVENT",
+	RowsQueryEvent:          "ROWS_QUERY_EVENT",
+	WriteRowsEventV2:        "WRITE_ROWS_EVENTv2",
+	UpdateRowsEventV2:       "UPDATE_ROWS_EVENTv2",
+	DeleteRowsEventV2:       "DELETE_ROWS_EVENTv2",
+	GTIDEvent:               "GTID_EVENT",
+	AnonymousGTIDEvent:      "ANONYMOUS_GTID_EVENT",
+	PreviousGTIDEvent:       "PREVIOUS_GTIDS_EVENT",
+	TransactionContextEvent: "TRANSACTION_CONTEXT_EVENT",
+	ViewChangeEvent:         "VIEW_CHANGE_EVENT",
+	XAPrepareLogEvent:       "XA_PREPARE_LOG_EVENT",
+	PartialUpdateRowsEvent:  "PARTIAL_UPDATE_ROWS_EVENT",
+	TransactionPayloadEvent: "TRANSACTION_PAYLOAD_EVENT",
+	HeartbeatLogEventV2:     "HEARTBEAT_LOG_EVENT_V2",
 }
 
 // BINGLOG_CHECKSUM_ALG
@@ -142,6 +191,13 @@ const (
 	QInvokers              = 0x0b
 	QUpdatedDBNames        = 0x0c
 	QMicroseconds          = 0x0d
+
+	// since mysql 8.0
+	QExplicitDefaultsForTimestamp = 0x0e
+	QDDLLoggedWithXID             = 0x0f
+	QDefaultCollationForUTF8MB4   = 0x10
+	QSQLRequirePrimaryKey         = 0x11
+	QDefaultTableEncryption       = 0x12
 )
 
 // QStatusKey2Str is the name of status_vars
@@ -160,4 +216,51 @@ var QStatusKey2Str = map[uint8]string{
 	QInvokers:              "Q_INVOKERS",
 	QUpdatedDBNames:        "Q_UPDATED_DB_NAMES",
 	QMicroseconds:          "Q_MICROSECONDS",
+
+	QExplicitDefaultsForTimestamp: "Q_EXPLICIT_DEFAULTS_FOR_TIMESTAMP",
+	QDDLLoggedWithXID:             "Q_DDL_LOGGED_WITH_XID",
+	QDefaultCollationForUTF8MB4:   "Q_DEFAULT_COLLATION_FOR_UTF8MB4",
+	QSQLRequirePrimaryKey:         "Q_SQL_REQUIRE_PRIMARY_KEY",
+	QDefaultTableEncryption:       "Q_DEFAULT_TABLE_ENCRYPTION",
 }
+
+// TABLE_MAP_EVENT optional metadata fields, binlog_row_metadata (mysql 8.0.1)
+// https://github.com/mysql/mysql-server/blob/8.0/libbinlogevents/include/rows_event.h
+const (
+	TableMapOptSignedness               = 0x01
+	TableMapOptDefaultCharset           = 0x02
+	TableMapOptColumnCharset            = 0x03
+	TableMapOptColumnName               = 0x04
+	TableMapOptSetStrValue              = 0x05
+	TableMapOptEnumStrValue             = 0x06
+	TableMapOptGeometryType             = 0x07
+	TableMapOptSimplePrimaryKey         = 0x08
+	TableMapOptPrimaryKeyWithPrefix     = 0x09
+	TableMapOptEnumAndSetDefaultCharset = 0x0a
+	TableMapOptEnumAndSetColumnCharset  = 0x0b
+	TableMapOptColumnVisibility         = 0x0c
+)
+
+// event header flags
+// https://dev.mysql.com/doc/internals/en/binlog-event-flag.html
+const (
+	LogEventBinlogInUseF           = 0x0001
+	LogEventForcedRotateF          = 0x0002
+	LogEventThreadSpecificF        = 0x0004
+	LogEventSuppressUseF           = 0x0008
+	LogEventUpdateTableMapVersionF = 0x0010
+	LogEventArtificialF            = 0x0020
+	LogEventRelayLogF              = 0x0040
+	LogEventIgnorableF             = 0x0080
+	LogEventNoFilterF              = 0x0100
+	LogEventMTSIsolateF            = 0x0200
+)
+
+// ROWS_EVENT flags
+// https://dev.mysql.com/doc/internals/en/rows-event.html
+const (
+	RowsEventStmtEndF             = 0x0001
+	RowsEventNoForeignKeyChecksF  = 0x0002
+	RowsEventRelaxedUniqueChecksF = 0x0004
+	RowsEventCompleteRowsF        = 0x0008
+)
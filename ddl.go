@@ -0,0 +1,405 @@
+package binlog
+
+import (
+	"strings"
+)
+
+// ddlKeywords is the leading keywords of DDL statements
+var ddlKeywords = []string{"CREATE", "ALTER", "DROP", "RENAME", "TRUNCATE"}
+
+// StopBeforeDDL is a StopFunc which stops before the first DDL QUERY_EVENT, e.g. capture the DML under
+// a stable schema, and re-fetch the schema before resuming from the start of the DDL event.
+// With GTID, the GTID_EVENT of the DDL has been dispatched.
+func StopBeforeDDL(event *BinEvent) bool {
+	query, ok := event.Body.(*BinQueryEvent)
+	return ok && IsDDL(query.Query)
+}
+
+// IsDDL return bool of if the query of QUERY_EVENT is a DDL statement.
+// The detection is conservative, only the first keyword is checked after the leading comments,
+// e.g. BEGIN, COMMIT, INSERT and GRANT are not DDL.
+func IsDDL(query string) bool {
+	query = trimLeadingComments(query)
+	end := strings.IndexFunc(query, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z')
+	})
+	if end >= 0 {
+		query = query[:end]
+	}
+	for _, keyword := range ddlKeywords {
+		if strings.EqualFold(query, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// trimLeadingComments trim the leading spaces and comments, e.g. /* ApplicationName=... */ or # comment
+func trimLeadingComments(query string) string {
+	for {
+		query = strings.TrimSpace(query)
+		switch {
+		case strings.HasPrefix(query, "/*"):
+			end := strings.Index(query, "*/")
+			if end < 0 {
+				return ""
+			}
+			query = query[end+2:]
+		case strings.HasPrefix(query, "#"), strings.HasPrefix(query, "-- "):
+			end := strings.IndexByte(query, '\n')
+			if end < 0 {
+				return ""
+			}
+			query = query[end+1:]
+		default:
+			return query
+		}
+	}
+}
+
+// DDLOperation is the operation of DDL statement
+type DDLOperation int
+
+// DDL operations
+const (
+	DDLOther DDLOperation = iota
+	DDLCreateTable
+	DDLAlterTable
+	DDLDropTable
+	DDLRenameTable
+	DDLTruncateTable
+	DDLCreateIndex
+	DDLDropIndex
+	DDLCreateDatabase
+	DDLAlterDatabase
+	DDLDropDatabase
+)
+
+// String return the name of operation
+func (op DDLOperation) String() string {
+	switch op {
+	case DDLCreateTable:
+		return "CREATE TABLE"
+	case DDLAlterTable:
+		return "ALTER TABLE"
+	case DDLDropTable:
+		return "DROP TABLE"
+	case DDLRenameTable:
+		return "RENAME TABLE"
+	case DDLTruncateTable:
+		return "TRUNCATE TABLE"
+	case DDLCreateIndex:
+		return "CREATE INDEX"
+	case DDLDropIndex:
+		return "DROP INDEX"
+	case DDLCreateDatabase:
+		return "CREATE DATABASE"
+	case DDLAlterDatabase:
+		return "ALTER DATABASE"
+	case DDLDropDatabase:
+		return "DROP DATABASE"
+	}
+	return "OTHER"
+}
+
+// DDLTable is the table affected by DDL, Table is empty for database operations
+type DDLTable struct {
+	Schema string
+	Table  string
+}
+
+// String return 'db.table', or 'db' for database
+func (t DDLTable) String() string {
+	if t.Table == "" {
+		return t.Schema
+	}
+	return t.Schema + "." + t.Table
+}
+
+// DDLChange is the affected tables of DDL statement.
+// For RENAME TABLE and ALTER TABLE ... RENAME, NewTables[i] is the new name of Tables[i].
+type DDLChange struct {
+	Operation DDLOperation
+	Tables    []DDLTable
+	NewTables []DDLTable
+}
+
+// ParseDDL extract the operation and affected tables of common DDL statements, CREATE/ALTER/DROP/RENAME/TRUNCATE
+// of tables and databases, the unqualified table names belong to schema, which is the schema of QUERY_EVENT.
+// It is a lightweight tokenizer rather than a SQL parser, return false if the statement is not recognized.
+func ParseDDL(schema, query string) (*DDLChange, bool) {
+	p := &ddlParser{tokens: tokenizeSQL(query), schema: schema}
+	change := &DDLChange{}
+
+	switch {
+	case p.keyword("CREATE"):
+		p.keyword("TEMPORARY")
+		switch {
+		case p.keyword("TABLE"):
+			p.ifExists()
+			change.Operation = DDLCreateTable
+			change.Tables = p.tables(false)
+		case p.keyword("DATABASE"), p.keyword("SCHEMA"):
+			p.ifExists()
+			change.Operation = DDLCreateDatabase
+			change.Tables = p.database()
+		default:
+			p.keyword("UNIQUE")
+			p.keyword("FULLTEXT")
+			p.keyword("SPATIAL")
+			if !p.keyword("INDEX") {
+				return nil, false
+			}
+			change.Operation = DDLCreateIndex
+			change.Tables = p.indexTable()
+		}
+	case p.keyword("DROP"):
+		p.keyword("TEMPORARY")
+		switch {
+		case p.keyword("TABLE"), p.keyword("TABLES"):
+			p.ifExists()
+			change.Operation = DDLDropTable
+			change.Tables = p.tables(true)
+		case p.keyword("DATABASE"), p.keyword("SCHEMA"):
+			p.ifExists()
+			change.Operation = DDLDropDatabase
+			change.Tables = p.database()
+		case p.keyword("INDEX"):
+			change.Operation = DDLDropIndex
+			change.Tables = p.indexTable()
+		default:
+			return nil, false
+		}
+	case p.keyword("ALTER"):
+		p.keyword("ONLINE")
+		p.keyword("IGNORE")
+		switch {
+		case p.keyword("TABLE"):
+			change.Operation = DDLAlterTable
+			change.Tables = p.tables(false)
+			if newTable, ok := p.alterRename(); ok {
+				change.NewTables = []DDLTable{newTable}
+			}
+		case p.keyword("DATABASE"), p.keyword("SCHEMA"):
+			change.Operation = DDLAlterDatabase
+			change.Tables = p.database()
+		default:
+			return nil, false
+		}
+	case p.keyword("RENAME"):
+		if !p.keyword("TABLE") && !p.keyword("TABLES") {
+			return nil, false
+		}
+		change.Operation = DDLRenameTable
+		for {
+			from, ok := p.table()
+			if !ok || !p.keyword("TO") {
+				return nil, false
+			}
+			to, ok := p.table()
+			if !ok {
+				return nil, false
+			}
+			change.Tables = append(change.Tables, from)
+			change.NewTables = append(change.NewTables, to)
+			if !p.symbol(",") {
+				break
+			}
+		}
+	case p.keyword("TRUNCATE"):
+		p.keyword("TABLE")
+		change.Operation = DDLTruncateTable
+		change.Tables = p.tables(false)
+	default:
+		return nil, false
+	}
+
+	if len(change.Tables) == 0 {
+		return nil, false
+	}
+	return change, true
+}
+
+// sqlToken is a token of SQL, quoted is true if it is a quoted identifier or string
+type sqlToken struct {
+	text   string
+	quoted bool
+}
+
+// tokenizeSQL split query into words, quoted identifiers, strings and symbols, comments are skipped
+func tokenizeSQL(query string) []sqlToken {
+	var tokens []sqlToken
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				return tokens
+			}
+			i += end + 4
+		case c == '#' || strings.HasPrefix(query[i:], "-- "):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				return tokens
+			}
+			i += end + 1
+		case c == '`' || c == '\'' || c == '"':
+			// quoted, the doubled quote is an escaped quote
+			var text strings.Builder
+			j := i + 1
+			for ; j < len(query); j++ {
+				if query[j] == c {
+					if j+1 < len(query) && query[j+1] == c {
+						text.WriteByte(c)
+						j++
+						continue
+					}
+					break
+				}
+				text.WriteByte(query[j])
+			}
+			tokens = append(tokens, sqlToken{text: text.String(), quoted: true})
+			i = j + 1
+		case isIdentifierByte(c):
+			j := i
+			for j < len(query) && isIdentifierByte(query[j]) {
+				j++
+			}
+			tokens = append(tokens, sqlToken{text: query[i:j]})
+			i = j
+		default:
+			tokens = append(tokens, sqlToken{text: query[i : i+1]})
+			i++
+		}
+	}
+	return tokens
+}
+
+func isIdentifierByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '$' || c >= 0x80
+}
+
+// ddlParser consume the tokens of DDL statement
+type ddlParser struct {
+	tokens []sqlToken
+	pos    int
+	schema string
+}
+
+// keyword consume the next token if it is the keyword
+func (p *ddlParser) keyword(keyword string) bool {
+	if p.pos < len(p.tokens) && !p.tokens[p.pos].quoted && strings.EqualFold(p.tokens[p.pos].text, keyword) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// symbol consume the next token if it is the symbol
+func (p *ddlParser) symbol(symbol string) bool {
+	if p.pos < len(p.tokens) && !p.tokens[p.pos].quoted && p.tokens[p.pos].text == symbol {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// ifExists consume IF EXISTS or IF NOT EXISTS
+func (p *ddlParser) ifExists() {
+	if p.keyword("IF") {
+		p.keyword("NOT")
+		p.keyword("EXISTS")
+	}
+}
+
+// identifier consume an identifier
+func (p *ddlParser) identifier() (string, bool) {
+	if p.pos >= len(p.tokens) {
+		return "", false
+	}
+	token := p.tokens[p.pos]
+	if !token.quoted && !isIdentifierByte(token.text[0]) {
+		return "", false
+	}
+	p.pos++
+	return token.text, true
+}
+
+// table consume a table name, schema.table or table
+func (p *ddlParser) table() (DDLTable, bool) {
+	name, ok := p.identifier()
+	if !ok {
+		return DDLTable{}, false
+	}
+	if !p.symbol(".") {
+		return DDLTable{Schema: p.schema, Table: name}, true
+	}
+	table, ok := p.identifier()
+	if !ok {
+		return DDLTable{}, false
+	}
+	return DDLTable{Schema: name, Table: table}, true
+}
+
+// tables consume a table name, or table names separated by comma if multiple
+func (p *ddlParser) tables(multiple bool) []DDLTable {
+	var tables []DDLTable
+	for {
+		table, ok := p.table()
+		if !ok {
+			return tables
+		}
+		tables = append(tables, table)
+		if !multiple || !p.symbol(",") {
+			return tables
+		}
+	}
+}
+
+// database consume a database name, the database of statement if omitted
+func (p *ddlParser) database() []DDLTable {
+	name, ok := p.identifier()
+	if !ok {
+		name = p.schema
+	}
+	if name == "" {
+		return nil
+	}
+	return []DDLTable{{Schema: name}}
+}
+
+// indexTable consume 'index_name ON table'
+func (p *ddlParser) indexTable() []DDLTable {
+	if _, ok := p.identifier(); !ok || !p.keyword("ON") {
+		return nil
+	}
+	return p.tables(false)
+}
+
+// alterRename find the 'RENAME [TO|AS] new_table' of ALTER TABLE,
+// RENAME COLUMN, RENAME INDEX and RENAME KEY are not table renames
+func (p *ddlParser) alterRename() (DDLTable, bool) {
+	depth := 0
+	for p.pos < len(p.tokens) {
+		switch {
+		case p.symbol("("):
+			depth++
+		case p.symbol(")"):
+			depth--
+		case depth == 0 && p.keyword("RENAME"):
+			if p.keyword("COLUMN") || p.keyword("INDEX") || p.keyword("KEY") {
+				continue
+			}
+			if !p.keyword("TO") {
+				p.keyword("AS")
+			}
+			return p.table()
+		default:
+			p.pos++
+		}
+	}
+	return DDLTable{}, false
+}
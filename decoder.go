@@ -3,10 +3,14 @@ package binlog
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 )
 
@@ -14,6 +18,29 @@ import (
 // https://dev.mysql.com/doc/internals/en/binlog-file-header.html
 var binFileHeader = []byte{254, 98, 105, 110}
 
+// encryptedBinFileHeader : A binlog file encrypted by binlog_encryption=ON (mysql 8.0.14) starts with [ fd 'bin' ]
+var encryptedBinFileHeader = []byte{253, 98, 105, 110}
+
+// ErrEncryptedBinlog is returned when the binary log is encrypted, which is not supported to decode
+var ErrEncryptedBinlog = errors.New("encrypted binary log is not supported")
+
+// ErrUnsupportedBinlogVersion is returned when the binlog_version is not 4, e.g. the binary logs of mysql 3.23 and 4.x
+var ErrUnsupportedBinlogVersion = errors.New("unsupported binlog version")
+
+// ErrNoFormatDescription is returned when an event depends on the FORMAT_DESCRIPTION_EVENT, which is not decoded,
+// e.g. decoding a fragment of binary log from the middle
+var ErrNoFormatDescription = errors.New("no FORMAT_DESCRIPTION_EVENT seen; inject one via SetFormatDescription")
+
+// ErrTransactionTooLarge is returned by WalkTransaction when a transaction exceeds BinReaderOption.MaxTransactionSize
+// and StreamLargeTransactions is not set
+var ErrTransactionTooLarge = errors.New("transaction exceeds MaxTransactionSize")
+
+// ErrUnsupportedEvent is returned when the event type is not supported to decode
+var ErrUnsupportedEvent = errors.New("not support event")
+
+// defaultMaxEventSize is the default of BinReaderOption.MaxEventSize, 1GB, the same as max_allowed_packet
+const defaultMaxEventSize int64 = 1 << 30
+
 // BinReaderOption will describe the details to tell decoders when it should start and when stop.
 // with time [start, end)
 type BinReaderOption struct {
@@ -21,20 +48,216 @@ type BinReaderOption struct {
 	EndPos    int64
 	StartTime time.Time
 	EndTime   time.Time
+
+	// StartFunc and StopFunc augment the position and time with arbitrary conditions,
+	// e.g. start at a server id, stop at a GTID or before a DDL (StopBeforeDDL).
+	// Decoding starts from the first event meeting all of the set StartPos, StartTime and StartFunc,
+	// StartFunc is not called again once it returns true. It stops before the first event meeting
+	// any of the set EndPos, EndTime and StopFunc. FORMAT_DESCRIPTION_EVENT is always decoded.
+	StartFunc func(header *BinEventHeader) bool
+
+	// events from these servers will be skipped before decoding body
+	IgnoreServerIDs []int64
+
+	// column names of tables, 'db.table' => ordered column names, see LoadColumnNames
+	// rows are keyed by positional '@N' names if the column name is unknown.
+	// The names of a table are stale and dropped after ALTER TABLE or DROP TABLE in binary log
+	ColumnNames map[string][]string
+
+	// receive the statistics of decoding, do nothing if nil
+	Metrics Metrics
+
+	// receive the diagnostics of decoding, do nothing if nil
+	Logger Logger
+
+	// skip the transactions whose GTID is contained in the set, e.g. resume from gtid_executed
+	// events are still decoded, so the table maps are kept for later transactions
+	StartGTID *GTIDSet
+
+	// the binary log is a relay log, ROTATE_EVENTs copied from master carry the master coordinates,
+	// only the ROTATE_EVENT written by slave (LOG_EVENT_RELAY_LOG_F) switches to the next relay log
+	RelayLog bool
+
+	// receive the DDL statements of QUERY_EVENT, see IsDDL
+	OnDDL func(schema, query string)
+
+	// receive the latest position from HEARTBEAT_EVENT, e.g. checkpoint progress while there is no change
+	Heartbeat func(fileName string, pos int64)
+
+	// continue with the next binary log in the same directory after ROTATE_EVENT,
+	// StartPos and EndPos only apply to the first binary log
+	FollowRotate bool
+
+	// on a decode error, scan forward for the next plausible event header and resume,
+	// see SkippedBytes for the number of bytes skipped
+	RecoverMode bool
+
+	// the event larger than it is regarded as corrupted, rather than allocating memory for it.
+	// 1GB if zero
+	MaxEventSize int64
+
+	// unsupported and unknown events are returned as BinEventUnParsed with raw body instead of error
+	SkipUnsupported bool
+
+	// decode and dispatch the events whose type returns true, others are skipped before decoding body.
+	// FORMAT_DESCRIPTION_EVENT and TABLE_MAP_EVENT are never filtered, since later events depend on them.
+	EventTypeFilter func(eventType EventType) bool
+
+	// continue to the end of the transaction when EndPos/EndTime falls inside it
+	StopAtTransactionEnd bool
+
+	// stop before the decoded event if it returns true, see StartFunc
+	StopFunc func(event *BinEvent) bool
+
+	// hooks invoked before and after decoding each event body, e.g. profiling per event type
+	BeforeDecode func(header *BinEventHeader)
+	AfterDecode  func(header *BinEventHeader, elapsed time.Duration)
+
+	// stop walking without error after dispatching so many events, or so many rows of ROWS_EVENT,
+	// e.g. sampling the binary log. no limit if zero
+	MaxEvents int64
+	MaxRows   int64
+
+	// bound the events buffered by WalkTransaction, the sum of event sizes in binary log, e.g. INSERT ... SELECT
+	// over millions of rows, the decoded events take several times of it in memory. no limit if zero.
+	// Once exceeded, WalkTransaction fails with ErrTransactionTooLarge, or passes the buffered events to f as
+	// a Transaction with Continues if StreamLargeTransactions. Streaming bounds the memory, but the parts reach
+	// f before the transaction commits (or rolls back), so the consumer has to stage them until the last part.
+	// A larger limit costs more memory for fewer and later parts.
+	MaxTransactionSize      int64
+	StreamLargeTransactions bool
+
+	// the raw bytes (header and body) of events failed to decode or unsupported are dumped into it
+	// with offsets, e.g. os.Stderr for reverse-engineering new event types. nothing is dumped if nil
+	HexDump io.Writer
+
+	// the expected 4 bytes magic of binary log, e.g. the binary logs of forks, [ fe 'bin' ] if nil.
+	// the magic is not checked at all if SkipHeaderCheck, but 4 bytes are still skipped
+	FileHeader      []byte
+	SkipHeaderCheck bool
+
+	// the events failed checksum are still decoded and returned with ChecksumOK false, rather than error,
+	// e.g. forensics on partially corrupted binary log
+	LenientChecksum bool
+
+	// the checksum is stripped from event but not computed, e.g. the trusted pipelines for throughput.
+	// ChecksumOK is always true then
+	SkipChecksumVerify bool
+
+	// TABLE_MAP_EVENT and ROWS_EVENTs are discarded without decoding and the table maps are not tracked,
+	// e.g. auditing the DDL of QUERY_EVENT. It is applied before EventTypeFilter.
+	SkipRowsEvents bool
+
+	// QUERY_EVENTs with non-zero error code are not dispatched, they failed on the master and
+	// must not be applied by replay, see BinQueryEvent.Failed
+	SkipFailedQueries bool
+
+	// the ROWS_EVENT is an error if its row image has NULL in a column declared NOT NULL by the table map,
+	// e.g. validate the binary log. They may disagree after DDL or for generated columns, so it is off by default
+	StrictNullability bool
 }
 
-// Start return bool of if start decoding
+// Start return bool of if start decoding by StartPos and StartTime, the unset ones are met
 func (option *BinReaderOption) Start(header *BinEventHeader) bool {
 	if option == nil {
 		return true
-	} else if option.StartPos != 0 && option.StartPos <= header.LogPos-header.EventSize {
-		return true
-	} else if option.StartTime.Unix() <= time.Unix(header.Timestamp, 0).Unix() {
+	} else if option.StartPos != 0 && option.StartPos > header.LogPos-header.EventSize {
+		return false
+	} else if !option.StartTime.IsZero() && option.StartTime.Unix() > header.Timestamp {
+		return false
+	}
+	return true
+}
+
+// started return bool of if start decoding by StartPos, StartTime and StartFunc,
+// the session is started once StartFunc returns true
+func (session *decodeSession) started(option *BinReaderOption, header *BinEventHeader) bool {
+	if !option.Start(header) {
+		return false
+	}
+	if option == nil || option.StartFunc == nil || session.startFuncMet {
 		return true
 	}
+	session.startFuncMet = option.StartFunc(header)
+	return session.startFuncMet
+}
+
+// Ignore return bool of if the event should be skipped
+func (option *BinReaderOption) Ignore(header *BinEventHeader) bool {
+	if option == nil {
+		return false
+	}
+	for _, serverID := range option.IgnoreServerIDs {
+		if header.ServerID == serverID {
+			return true
+		}
+	}
 	return false
 }
 
+// skipFailed return bool of if the event is a failed QUERY_EVENT skipped by SkipFailedQueries
+func (option *BinReaderOption) skipFailed(event *BinEvent) bool {
+	if option == nil || !option.SkipFailedQueries {
+		return false
+	}
+	query, ok := event.Body.(*BinQueryEvent)
+	return ok && query.Failed()
+}
+
+// checkFileHeader return the error if the magic of binary log is not expected
+func (option *BinReaderOption) checkFileHeader(header []byte) error {
+	expected := binFileHeader
+	if option != nil {
+		if option.SkipHeaderCheck {
+			return nil
+		}
+		if option.FileHeader != nil {
+			expected = option.FileHeader
+		}
+	}
+	if len(expected) != len(binFileHeader) {
+		return fmt.Errorf("invalid FileHeader {%x}, must be %d bytes", expected, len(binFileHeader))
+	}
+	if !bytes.Equal(header, expected) {
+		return fmt.Errorf("invalid binary log header {%x}", header)
+	}
+	return nil
+}
+
+// maxEventSize return MaxEventSize, or the default if not set
+func (option *BinReaderOption) maxEventSize() int64 {
+	if option == nil || option.MaxEventSize <= 0 {
+		return defaultMaxEventSize
+	}
+	return option.MaxEventSize
+}
+
+// Filter return bool of if the event is filtered out by EventTypeFilter
+func (option *BinReaderOption) Filter(header *BinEventHeader) bool {
+	if option == nil {
+		return false
+	}
+	if option.SkipRowsEvents && header.EventType.rowsRelated() {
+		return true
+	}
+	if option.EventTypeFilter == nil {
+		return false
+	}
+	switch header.EventType {
+	case FormatDescriptionEvent, TableMapEvent:
+		return false
+	}
+	return !option.EventTypeFilter(header.EventType)
+}
+
+// limitReached return bool of if MaxEvents or MaxRows is reached
+func (option *BinReaderOption) limitReached(events, rows int64) bool {
+	if option == nil {
+		return false
+	}
+	return (option.MaxEvents > 0 && events >= option.MaxEvents) || (option.MaxRows > 0 && rows >= option.MaxRows)
+}
+
 // Stop return bool of if stop decoding
 func (option *BinReaderOption) Stop(header *BinEventHeader) bool {
 	if option == nil {
@@ -47,15 +270,41 @@ func (option *BinReaderOption) Stop(header *BinEventHeader) bool {
 	return false
 }
 
+// stopAt return bool of if stop decoding before the event, by EndPos, EndTime or StopFunc
+func (option *BinReaderOption) stopAt(event *BinEvent) bool {
+	if option == nil {
+		return false
+	}
+	return option.Stop(event.Header) || option.StopFunc != nil && option.StopFunc(event)
+}
+
 // BinaryLogInfo is the base decoder of all types
 type BinaryLogInfo struct {
 	// cause different version mapping different payload
 	// every binary log event analysis depend on descriptions
 	description *BinFmtDescEvent
 	tableInfo   map[uint64]*BinTableMapEvent
+	columnNames map[string][]string
+
+	// the checksum is stripped but not computed
+	skipChecksumVerify bool
+
+	// the NULL of a column declared NOT NULL is an error
+	strictNullability bool
+
+	// INTVAR, RAND and USER_VAR events waiting for the next QUERY_EVENT
+	statementContext *StatementContext
+
+	// timestamp of FORMAT_DESCRIPTION_EVENT header
+	descriptionTimestamp int64
+
+	// the server writes vendor-specific events, see ServerFlavor
+	vendorEvents bool
 }
 
 // BinFileDecoder will mapping a binary log file, decode binary log event
+// DecodeEvent, WalkEvent and ScanHeaders share the default decoding session of decoder,
+// use Walk for an independent walk, e.g. walk the same binary log in multiple goroutines.
 type BinFileDecoder struct {
 	Path string          // binary log path
 	prev *BinFileDecoder // prev binary log
@@ -64,12 +313,56 @@ type BinFileDecoder struct {
 	// binary log reading options
 	Option *BinReaderOption
 
-	// file object
+	// file object of default session, nil if decoding from io.Reader
 	BinFile *os.File
 
+	// default decoding session
+	*decodeSession
+}
+
+// decodeSession is the mutable state of decoding a binary log,
+// every walk owns its session, so the walks over the same binary log do not clobber each other.
+type decodeSession struct {
+	// file object
+	file *os.File
+
 	// buffer
 	buf *bufio.Reader
 
+	// whether the last decoded event is inside a transaction
+	inTransaction bool
+
+	// the microsecond time of the current transaction
+	transactionTime time.Time
+
+	// the type of the last read event, and how the binary log ends
+	lastEventType EventType
+	endReason     EndReason
+
+	// whether the events are skipped since the GTID is contained in StartGTID
+	skippingGTID bool
+
+	// whether StartFunc has returned true
+	startFuncMet bool
+
+	// file offset of the end of the last read event, and the start of the current event
+	offset     int64
+	eventStart int64
+
+	// ordinal of the current event, starts from 1
+	events int64
+
+	// number of events and rows dispatched to the walk function, for MaxEvents and MaxRows
+	dispatched     int64
+	dispatchedRows int64
+
+	// number of bytes skipped by RecoverMode
+	skipped int64
+
+	// coordinates of master, only tracked in relay log
+	masterFile string
+	masterPos  int64
+
 	*BinaryLogInfo
 }
 
@@ -93,42 +386,181 @@ func NewBinFileDecoder(path string, options ...*BinReaderOption) (*BinFileDecode
 
 // Init BinFileDecoder, binary log file validate
 func (decoder *BinFileDecoder) init() error {
+	session, err := decoder.newSession()
+	if err != nil {
+		return err
+	}
+	decoder.decodeSession = session
+	decoder.BinFile = session.file
+	return nil
+}
+
+// NewBinReaderDecoder return a BinFileDecoder reading binary log from rd, e.g. bytes.Reader or network stream.
+// rd starts with the binary log header. Path is empty, so the features need a file are not available:
+// Walk, SeekTo, RecoverMode, FollowRotate and Tail.
+func NewBinReaderDecoder(rd io.Reader, options ...*BinReaderOption) (*BinFileDecoder, error) {
+	decoder := &BinFileDecoder{}
+	if len(options) > 0 {
+		decoder.Option = options[0]
+	}
+
+	session, err := decoder.newReaderSession(rd)
+	if err != nil {
+		return nil, err
+	}
+	decoder.decodeSession = session
+	return decoder, nil
+}
+
+// errNotFile is returned by the features need a binary log file, when decoding from io.Reader
+var errNotFile = errors.New("the binary log is not a file")
+
+// newSession open binary log and validate the binary log header
+func (decoder *BinFileDecoder) newSession() (*decodeSession, error) {
+	if decoder.Path == "" {
+		return nil, errNotFile
+	}
 	// open binary log
-	if decoder.BinFile == nil {
-		binFile, err := os.Open(decoder.Path)
-		if err != nil {
-			return err
-		}
-		decoder.BinFile = binFile
-		decoder.buf = bufio.NewReader(decoder.BinFile)
+	binFile, err := os.Open(decoder.Path)
+	if err != nil {
+		return nil, err
+	}
+	session, err := decoder.newReaderSession(binFile)
+	if err != nil {
+		binFile.Close()
+		return nil, err
+	}
+	session.file = binFile
+	return session, nil
+}
+
+// newReaderSession return a session reading from rd and validate the binary log header
+func (decoder *BinFileDecoder) newReaderSession(rd io.Reader) (*decodeSession, error) {
+	session := &decodeSession{
+		buf:    bufio.NewReader(rd),
+		offset: int64(len(binFileHeader)),
+		BinaryLogInfo: &BinaryLogInfo{
+			tableInfo: make(map[uint64]*BinTableMapEvent),
+		},
+	}
+	if decoder.Option != nil {
+		session.columnNames = decoder.Option.ColumnNames
+		session.skipChecksumVerify = decoder.Option.SkipChecksumVerify
+		session.strictNullability = decoder.Option.StrictNullability
 	}
 
 	// binary log header validate
 	header := make([]byte, 4)
-	if _, err := decoder.BinFile.Read(header); err != nil {
-		return err
+	if _, err := io.ReadFull(session.buf, header); err != nil {
+		return nil, err
 	}
 
-	if !bytes.Equal(header, binFileHeader) {
-		return fmt.Errorf("invalid binary log header {%x}", header)
+	if bytes.Equal(header, encryptedBinFileHeader) && (decoder.Option == nil || !decoder.Option.SkipHeaderCheck) {
+		return nil, fmt.Errorf("%w: %s is encrypted by binlog_encryption=ON, "+
+			"read it from server by mysqlbinlog --read-from-remote-server to get the decrypted events", ErrEncryptedBinlog, decoder.Path)
+	}
+	if err := decoder.Option.checkFileHeader(header); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// SetFormatDescription set the FORMAT_DESCRIPTION_EVENT of binary log, e.g. decode a fragment of
+// binary log from SeekTo, which lacks its own FORMAT_DESCRIPTION_EVENT. desc is decoded from the
+// same binary log, or the known format of server by NewFormatDescription. The table maps are reset.
+func (decoder *BinFileDecoder) SetFormatDescription(desc *BinFmtDescEvent) {
+	decoder.tableInfo = make(map[uint64]*BinTableMapEvent)
+	if desc == nil {
+		decoder.description, decoder.vendorEvents = nil, false
+		return
 	}
+	desc.hasCheckSum = desc.ChecksumAlgorithm != BinlogChecksumAlgOff &&
+		desc.ChecksumAlgorithm != BinlogChecksumAlgUndef
+	decoder.description = desc
+	decoder.vendorEvents = desc.Flavor().vendorEvents()
+}
 
-	decoder.BinaryLogInfo = &BinaryLogInfo{
-		tableInfo: make(map[uint64]*BinTableMapEvent),
+// SeekTo move the default session to the event at pos, e.g. the position of SHOW BINLOG EVENTS,
+// the FORMAT_DESCRIPTION_EVENT should be decoded before or set by SetFormatDescription.
+func (decoder *BinFileDecoder) SeekTo(pos int64) error {
+	if pos < int64(len(binFileHeader)) {
+		return fmt.Errorf("invalid position %d, binary log header size %d", pos, len(binFileHeader))
+	}
+	if decoder.file == nil {
+		return fmt.Errorf("seek to %d: %w", pos, errNotFile)
 	}
+	if _, err := decoder.file.Seek(pos, io.SeekStart); err != nil {
+		return err
+	}
+	decoder.buf.Reset(decoder.file)
+	decoder.offset = pos
+	decoder.inTransaction, decoder.skippingGTID, decoder.startFuncMet = false, false, false
 	return nil
 }
 
-// DecodeEvent will decode a single event from binary log
+// BinlogCreateTime return the time when the binary log was opened by the server.
+// It is the create timestamp of FORMAT_DESCRIPTION_EVENT, which is zero except the first binary log
+// since the server starts, then the timestamp of FORMAT_DESCRIPTION_EVENT header is used.
+func (decoder *BinFileDecoder) BinlogCreateTime() (time.Time, error) {
+	if decoder.description == nil {
+		return time.Time{}, errors.New("FORMAT_DESCRIPTION_EVENT is not read yet")
+	}
+	if decoder.description.CreateTime != 0 {
+		return time.Unix(decoder.description.CreateTime, 0), nil
+	}
+	return time.Unix(decoder.descriptionTimestamp, 0), nil
+}
+
+// ChecksumAlgorithm return the binlog_checksum of binary log from FORMAT_DESCRIPTION_EVENT, NONE, CRC32
+// or UNKNOWN(n), e.g. verify the binlog_checksum of server. NONE before mysql 5.6.2, which has no checksum,
+// and UNKNOWN if FORMAT_DESCRIPTION_EVENT is not read yet.
+func (decoder *BinFileDecoder) ChecksumAlgorithm() string {
+	if decoder.description == nil {
+		return "UNKNOWN"
+	}
+	return ChecksumAlgorithmName(decoder.description.ChecksumAlgorithm)
+}
+
+// DecodeEvent will decode the next event from binary log, it is the low-level pull API of WalkEvent,
+// e.g. process events in chunks. The default session is kept between calls, including the format description,
+// table maps and the transaction state, so the calls could be interleaved with other work, but not with
+// WalkEvent concurrently. The events skipped by StartPos, IgnoreServerIDs, filters or SkipFailedQueries
+// are not returned, and io.EOF is returned at the end of binary log. EndPos, EndTime, StopFunc, limits,
+// StartGTID, Heartbeat, OnDDL, RecoverMode and FollowRotate are only applied by WalkEvent.
 func (decoder *BinFileDecoder) DecodeEvent() (*BinEvent, error) {
+	session := decoder.decodeSession
+	for {
+		event, err := decoder.decodeEvent(session)
+		if err != nil {
+			return event, err
+		}
+		if event == nil {
+			continue
+		}
+		session.trackTransaction(event)
+		session.trackTransactionTime(event)
+		if decoder.Option != nil && decoder.Option.RelayLog {
+			session.trackMaster(event)
+		}
+		if decoder.Option.skipFailed(event) {
+			continue
+		}
+		return event, nil
+	}
+}
+
+// decodeEvent decode a single event in session
+func (decoder *BinFileDecoder) decodeEvent(session *decodeSession) (*BinEvent, error) {
 	event := &BinEvent{}
-	rd := decoder.buf
+	rd := session.buf
+	session.eventStart = session.offset
+	session.events++
 
 	// event header固定为19字节
 	// 这里是为了兼容不同的binlog版本
 	eventHeaderLength := defaultEventHeaderSize
-	if decoder.description != nil {
-		eventHeaderLength = decoder.description.EventHeaderLength
+	if session.description != nil {
+		eventHeaderLength = session.description.EventHeaderLength
 	}
 
 	// read binlog event header
@@ -143,95 +575,367 @@ func (decoder *BinFileDecoder) DecodeEvent() (*BinEvent, error) {
 		return nil, err
 	}
 
-	if _, ok := EventType2Str[event.Header.EventType]; !ok {
-		return nil, fmt.Errorf("got unknown event type {%x}", event.Header.EventType)
+	skipUnsupported := (decoder.Option != nil && decoder.Option.SkipUnsupported) ||
+		session.vendorEvents
+	if _, ok := EventType2Str[event.Header.EventType]; !ok && !skipUnsupported {
+		return nil, fmt.Errorf("got unknown event type {%x}", uint8(event.Header.EventType))
+	}
+
+	if event.Header.EventSize < eventHeaderLength || event.Header.EventSize > decoder.Option.maxEventSize() {
+		return nil, fmt.Errorf("invalid event size %d of %s at %d, max event size %d",
+			event.Header.EventSize, event.Header.Type(), event.Header.LogPos, decoder.Option.maxEventSize())
 	}
 
 	readDataLength := event.Header.EventSize - eventHeaderLength
+
+	// skip data if not start, ignored or filtered, the body is discarded without reading into memory
+	// 如果没有跳过,第一个event必须是FormatDescriptionEvent
+	if event.Header.EventType != FormatDescriptionEvent &&
+		(!session.started(decoder.Option, event.Header) || decoder.Option.Ignore(event.Header) || decoder.Option.Filter(event.Header)) {
+		if _, err = io.CopyN(io.Discard, rd, readDataLength); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		session.offset += event.Header.EventSize
+		session.lastEventType = event.Header.EventType
+		decoder.logger().Debugf("skip %s at %d", event.Header.EventType, session.eventStart)
+
+		metrics := decoder.metrics()
+		metrics.EventSkipped(event.Header.EventType, event.Header.EventSize)
+		metrics.Progress(event.Header.LogPos, time.Since(time.Unix(event.Header.Timestamp, 0)))
+		return nil, nil
+	}
+
 	// read binlog event body
 	var data []byte
 	data, err = ReadNBytes(rd, readDataLength)
+	if err == io.EOF {
+		// the header is read, but the body is missing
+		err = io.ErrUnexpectedEOF
+	}
 	if err != nil {
 		return nil, err
 	}
+	session.offset += event.Header.EventSize
+	session.lastEventType = event.Header.EventType
+	event.endPos = session.offset
 
-	// skip data if not start
-	// 如果没有跳过,第一个event必须是FormatDescriptionEvent
-	if event.Header.EventType != FormatDescriptionEvent && !decoder.Option.Start(event.Header) {
+	metrics := decoder.metrics()
+	data, err = event.Validation(session.BinaryLogInfo, headerData, data)
+	if err != nil {
+		if errors.Is(err, ErrChecksumFailed) {
+			metrics.ChecksumFailed(event.Header.EventType)
+			decoder.logger().Warnf("checksum failed of %s at %d: %v", event.Header.EventType, session.eventStart, err)
+		}
+		if !errors.Is(err, ErrChecksumFailed) || decoder.Option == nil || !decoder.Option.LenientChecksum {
+			return event, err
+		}
+	}
+
+	// decode binlog event body
+	if decoder.Option != nil && decoder.Option.BeforeDecode != nil {
+		decoder.Option.BeforeDecode(event.Header)
+	}
+	var startTime time.Time
+	if decoder.Option != nil && decoder.Option.AfterDecode != nil {
+		startTime = time.Now()
+	}
+
+	event.Body, err = session.BinaryLogInfo.decodeEventBody(event.Header, data)
+	if _, ok := event.Body.(*BinEventUnParsed); ok || errors.Is(err, ErrUnsupportedEvent) {
+		metrics.UnsupportedEvent(event.Header.EventType)
+	}
+	if _, ok := event.Body.(*BinEventUnParsed); ok || err != nil {
+		decoder.hexDump(session, event.Header, headerData, data)
+	}
+	if errors.Is(err, ErrUnsupportedEvent) && skipUnsupported {
+		decoder.logger().Warnf("unsupported %s at %d is returned unparsed", event.Header.EventType, session.eventStart)
+		event.Body, err = decodeUnSupportEvent(data)
+	}
+	if err != nil {
 		return nil, err
 	}
 
-	data, err = event.Validation(decoder.BinaryLogInfo, headerData, data)
+	if decoder.Option != nil && decoder.Option.AfterDecode != nil {
+		decoder.Option.AfterDecode(event.Header, time.Since(startTime))
+	}
+
+	metrics.EventDecoded(event.Header.EventType, event.Header.EventSize)
+	metrics.Progress(event.Header.LogPos, time.Since(time.Unix(event.Header.Timestamp, 0)))
+
+	return event, nil
+}
+
+// hexDump will dump the raw bytes of event into HexDump
+func (decoder *BinFileDecoder) hexDump(session *decodeSession, header *BinEventHeader, headerData, data []byte) {
+	if decoder.Option == nil || decoder.Option.HexDump == nil {
+		return
+	}
+	raw := make([]byte, 0, len(headerData)+len(data))
+	raw = append(append(raw, headerData...), data...)
+	fmt.Fprintf(decoder.Option.HexDump, "%s at %d, size %d\n%s", header.EventType, session.eventStart, header.EventSize, hex.Dump(raw))
+}
+
+// DecodeEventBytes will decode a single event from bytes, e.g. the event of replication protocol packet.
+// data is the whole event, with header and checksum. desc is the FORMAT_DESCRIPTION_EVENT of binary log,
+// which could be nil if data is a FORMAT_DESCRIPTION_EVENT. TABLE_MAP_EVENT is stored into tableInfo,
+// which is used to decode the following ROWS_EVENTs.
+func DecodeEventBytes(data []byte, desc *BinFmtDescEvent, tableInfo map[uint64]*BinTableMapEvent) (*BinEvent, error) {
+	if tableInfo == nil {
+		tableInfo = make(map[uint64]*BinTableMapEvent)
+	}
+	info := &BinaryLogInfo{description: desc, tableInfo: tableInfo}
+	return info.decodeEventBytes(data)
+}
+
+// decodeEventBytes decode an event from bytes with the format description and table maps of info
+func (info *BinaryLogInfo) decodeEventBytes(data []byte) (*BinEvent, error) {
+	desc := info.description
+	eventHeaderLength := defaultEventHeaderSize
+	if desc != nil {
+		eventHeaderLength = desc.EventHeaderLength
+	}
+	if int64(len(data)) < eventHeaderLength {
+		return nil, fmt.Errorf("invalid event size %d, header size %d", len(data), eventHeaderLength)
+	}
+
+	event := &BinEvent{}
+	var err error
+	event.Header, err = decodeEventHeader(data, eventHeaderLength)
+	if err != nil {
+		return nil, err
+	}
+	if event.Header.EventSize != int64(len(data)) {
+		return nil, fmt.Errorf("invalid event size %d, header event size %d", len(data), event.Header.EventSize)
+	}
+	if desc == nil && event.Header.EventType != FormatDescriptionEvent {
+		return nil, fmt.Errorf("decode %s: %w", event.Header.Type(), ErrNoFormatDescription)
+	}
+
+	body, err := event.Validation(info, data[:eventHeaderLength], data[eventHeaderLength:])
 	if err != nil {
 		return event, err
 	}
+	event.Body, err = info.decodeEventBody(event.Header, body)
+	if err != nil {
+		return nil, err
+	}
+	return event, nil
+}
 
-	// decode binlog event body
+// metrics return the Metrics of option, or a no-op Metrics if not set
+func (decoder *BinFileDecoder) metrics() Metrics {
+	if decoder.Option == nil || decoder.Option.Metrics == nil {
+		return noopMetrics{}
+	}
+	return decoder.Option.Metrics
+}
+
+// logger return the Logger of option, or a no-op Logger if not set
+func (decoder *BinFileDecoder) logger() Logger {
+	if decoder.Option == nil || decoder.Option.Logger == nil {
+		return noopLogger{}
+	}
+	return decoder.Option.Logger
+}
+
+// decodeEventBody decode binlog event body by event type
+func (info *BinaryLogInfo) decodeEventBody(header *BinEventHeader, data []byte) (BinEventBody, error) {
+	var err error
 	var eventBody BinEventBody
-	switch event.Header.EventType {
+	if info.description == nil {
+		switch header.EventType {
+		case StartEventV3:
+			return nil, decodeStartEventV3(header, data)
+		case QueryEvent, RotateEvent, TableMapEvent,
+			WriteRowsEventV0, UpdateRowsEventV0, DeleteRowsEventV0,
+			WriteRowsEventV1, UpdateRowsEventV1, DeleteRowsEventV1,
+			WriteRowsEventV2, UpdateRowsEventV2, DeleteRowsEventV2:
+			return nil, fmt.Errorf("decode %s: %w", header.Type(), ErrNoFormatDescription)
+		}
+	}
+
+	switch header.EventType {
 	case FormatDescriptionEvent:
-		decoder.description, err = decodeFmtDescEvent(data)
-		eventBody = decoder.description
+		// a new FORMAT_DESCRIPTION_EVENT starts a new binary log (e.g. concatenated streams),
+		// the checksum, header length and table maps of previous binary log are all stale
+		info.description, err = decodeFmtDescEvent(data)
+		info.tableInfo = make(map[uint64]*BinTableMapEvent)
+		info.statementContext = nil
+		info.descriptionTimestamp = header.Timestamp
+		if err == nil {
+			info.vendorEvents = info.description.Flavor().vendorEvents()
+		}
+		eventBody = info.description
 
 	case QueryEvent:
-		eventBody, err = decodeQueryEvent(data, decoder.description.BinlogVersion)
+		var query *BinQueryEvent
+		query, err = decodeQueryEvent(data, info.description.BinlogVersion)
+		if err == nil {
+			query.Context, info.statementContext = info.statementContext, nil
+			info.invalidateColumnNames(query)
+		}
+		eventBody = query
 
 	case XIDEvent:
 		eventBody, err = decodeXIDEvent(data)
 
-	case IntvarEvent:
-		eventBody, err = decodeIntvarEvent(data)
+	case IntvarEvent, RandEvent, UserVarEvent:
+		eventBody, err = decodeStatementContextEvent(header.EventType, data)
+		if err == nil {
+			if info.statementContext == nil {
+				info.statementContext = &StatementContext{}
+			}
+			info.statementContext.add(eventBody)
+		}
+
+	case XAPrepareLogEvent:
+		eventBody, err = decodeXAPrepareEvent(data)
 
 	case RotateEvent:
-		eventBody, err = decodeRotateEvent(data, decoder.description.BinlogVersion)
+		eventBody, err = decodeRotateEvent(data, info.description.BinlogVersion)
+
+	case StopEvent:
+		eventBody = &BinStopEvent{}
 
 	case TableMapEvent:
-		eventBody, err = decodeTableMapEvent(data, decoder.description)
+		eventBody, err = decodeTableMapEvent(data, info.description)
 		if err != nil {
 			return nil, err
 		}
-		decoder.tableInfo[eventBody.(*BinTableMapEvent).TableID] = eventBody.(*BinTableMapEvent)
+		applyColumnNames(eventBody.(*BinTableMapEvent), info.columnNames)
+		info.tableInfo[eventBody.(*BinTableMapEvent).TableID] = eventBody.(*BinTableMapEvent)
 
 	case WriteRowsEventV0, UpdateRowsEventV0, DeleteRowsEventV0,
 		WriteRowsEventV1, UpdateRowsEventV1, DeleteRowsEventV1,
 		WriteRowsEventV2, UpdateRowsEventV2, DeleteRowsEventV2:
 		// ROWS_EVENT
-		eventBody, err = decodeRowsEvent(data, decoder.description, event.Header.EventType)
+		eventBody, err = decodeRowsEvent(data, info.description, header.EventType, info.tableInfo, info.strictNullability)
 
-	case PreviousGTIDEvent, AnonymousGTIDEvent:
-		// decode ignore event.
-		// TODO: decode AnonymousGTIDEvent
+	case AppendBlockEvent, BeginLoadQueryEvent:
+		eventBody, err = decodeAppendBlockEvent(data)
+
+	case DeleteFileEvent, ExecLoadEvent:
+		eventBody, err = decodeFileIDEvent(data)
+
+	case LoadEvent, NewLoadEvent, CreateFileEvent:
+		// legacy LOAD DATA INFILE events (pre-5.1)
 		eventBody, err = decodeUnSupportEvent(data)
 
+	case HeartbeatEvent:
+		eventBody, err = decodeHeartbeatEvent(header, data)
+
+	case HeartbeatLogEventV2:
+		eventBody, err = decodeHeartbeatEventV2(data)
+
+	case TransactionContextEvent:
+		eventBody, err = decodeTransactionContextEvent(data)
+
+	case ViewChangeEvent:
+		eventBody, err = decodeViewChangeEvent(data)
+
+	case GTIDEvent, AnonymousGTIDEvent:
+		eventBody, err = decodeGTIDEvent(data)
+
+	case PreviousGTIDEvent:
+		eventBody, err = decodePreGTIDsEvent(data)
+
 	case UnknownEvent:
-		return nil, fmt.Errorf("got unknown event")
+		err = fmt.Errorf("%w: got unknown event", ErrUnsupportedEvent)
 
 	default:
 		// TODO more decoders for more events
-		err = errors.New("not support event: " + event.Header.Type())
+		err = fmt.Errorf("%w: %s", ErrUnsupportedEvent, header.Type())
 	}
 
 	if err != nil {
 		return nil, err
 	}
-
-	// set event body
-	event.Body = eventBody
-
-	return event, nil
+	return eventBody, nil
 }
 
 // WalkEvent will walk all events for binary log which in io.Reader
 // This function will return isFinish bool and err error.
 func (decoder *BinFileDecoder) WalkEvent(f func(event *BinEvent) (isContinue bool, err error)) error {
+	return decoder.walkEvent(decoder.decodeSession, f)
+}
+
+// Stream will walk all events in a goroutine and deliver them over the event channel,
+// both channels are closed when the walk finishes, fails or ctx is canceled.
+// The error channel receives at most one error, ctx.Err() if canceled.
+func (decoder *BinFileDecoder) Stream(ctx context.Context) (<-chan *BinEvent, <-chan error) {
+	events := make(chan *BinEvent)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(errs)
+		defer close(events)
+
+		err := decoder.WalkEvent(func(event *BinEvent) (isContinue bool, err error) {
+			select {
+			case events <- event:
+				return true, nil
+			case <-ctx.Done():
+				return false, ctx.Err()
+			}
+		})
+		if err != nil {
+			errs <- err
+		}
+	}()
+	return events, errs
+}
+
+// Walk will walk all events for binary log from the beginning in a new decoding session,
+// which does not share the file offset, format description and table maps with other walks.
+// It is safe to call Walk concurrently on the same decoder, if the callbacks of Option are safe.
+func (decoder *BinFileDecoder) Walk(f func(event *BinEvent) (isContinue bool, err error)) error {
+	session, err := decoder.newSession()
+	if err != nil {
+		return err
+	}
+	// the file of session is closed by walkEvent
+	return decoder.walkEvent(session, f)
+}
+
+// walkEvent walk all events in session. The binary logs followed by ROTATE_EVENT continue in their
+// default sessions, the file of previous binary log is closed. The files of the sessions opened by Walk
+// are closed on return, while the default sessions keep the file open, e.g. WalkEvent again and Tail.
+func (decoder *BinFileDecoder) walkEvent(session *decodeSession, f func(event *BinEvent) (isContinue bool, err error)) error {
+	owned := session != decoder.decodeSession
+	defer func() {
+		if owned {
+			session.file.Close()
+		}
+	}()
+
+	stopping := false
 	for {
-		// if rd is nil, BinFileDecoder.DecodeEvent() will set rd to BinFileDecoder.BinFile
-		event, err := decoder.DecodeEvent()
+		event, err := decoder.decodeEvent(session)
+		if err != nil && err != io.EOF && decoder.Option != nil && decoder.Option.RecoverMode {
+			decoder.logger().Warnf("decode error at %d: %v", session.eventStart, err)
+			recoverErr := decoder.recover(session)
+			if recoverErr == nil {
+				continue
+			}
+			if err != io.ErrUnexpectedEOF || recoverErr != io.EOF {
+				err = recoverErr
+			}
+		}
 		if err != nil {
-			if err == io.EOF {
+			switch err {
+			case io.EOF:
+				session.endReason = endReasonOf(session.lastEventType)
+				return nil
+			case io.ErrUnexpectedEOF:
+				// the last event is incomplete, e.g. crashed or still being written
+				decoder.logger().Warnf("truncated event at %d", session.eventStart)
+				session.endReason = EndTruncated
 				return nil
 			}
-			return err
+			return decoder.walkError(session, err)
 		}
 
 		// will receive a nil event if decoding not start yet
@@ -239,14 +943,320 @@ func (decoder *BinFileDecoder) WalkEvent(f func(event *BinEvent) (isContinue boo
 			continue
 		}
 
-		// if stop decoding
-		if decoder.Option.Stop(event.Header) {
+		// if stop decoding, the transaction in progress will be finished if StopAtTransactionEnd
+		if !stopping && decoder.Option.stopAt(event) {
+			if !decoder.Option.StopAtTransactionEnd || !session.inTransaction {
+				return nil
+			}
+			stopping = true
+		}
+
+		session.trackTransaction(event)
+		session.trackTransactionTime(event)
+		if decoder.Option != nil && decoder.Option.RelayLog {
+			session.trackMaster(event)
+		}
+
+		if heartbeat, ok := event.Body.(*BinHeartbeatEvent); ok && decoder.Option != nil && decoder.Option.Heartbeat != nil {
+			decoder.Option.Heartbeat(heartbeat.FileName, heartbeat.Position)
+		}
+
+		if !session.skipGTID(event, decoder.Option) && !decoder.Option.skipFailed(event) {
+			if query, ok := event.Body.(*BinQueryEvent); ok && decoder.Option != nil && decoder.Option.OnDDL != nil && IsDDL(query.Query) {
+				decoder.Option.OnDDL(query.Schema, query.Query)
+			}
+			isContinue, err := f(event)
+			if !isContinue || err != nil {
+				return err
+			}
+
+			session.dispatched++
+			if rows, ok := event.Body.(*BinRowsEvent); ok {
+				session.dispatchedRows += int64(len(rows.Rows))
+			}
+			if decoder.Option.limitReached(session.dispatched, session.dispatchedRows) {
+				return nil
+			}
+		}
+
+		if stopping && !session.inTransaction {
 			return nil
 		}
 
-		isContinue, err := f(event)
-		if !isContinue || err != nil {
+		// continue with the next binary log in its default session
+		next, err := decoder.followRotate(session, event)
+		if err != nil {
+			return decoder.walkError(session, err)
+		}
+		if next != nil {
+			session.endReason = EndRotate
+			session.file.Close()
+			decoder, session = next, next.decodeSession
+		}
+	}
+}
+
+// walkError wrap the error of walking with the binary log path, the event ordinal
+// and the last good position, which is the start of the failed event
+func (decoder *BinFileDecoder) walkError(session *decodeSession, err error) error {
+	path := decoder.Path
+	if path == "" {
+		path = "binary log"
+	}
+	return fmt.Errorf("%s: event #%d, last good position %d: %w", path, session.events, session.eventStart, err)
+}
+
+// ScanHeaders will walk all event headers for binary log without decoding event bodies,
+// bodies are discarded from the buffer, it is much faster to summarize a binary log.
+// Only FORMAT_DESCRIPTION_EVENT is decoded, since it describes the header length.
+func (decoder *BinFileDecoder) ScanHeaders(f func(header *BinEventHeader) bool) error {
+	session := decoder.decodeSession
+	headerData := make([]byte, defaultEventHeaderSize)
+	for {
+		eventHeaderLength := defaultEventHeaderSize
+		if session.description != nil {
+			eventHeaderLength = session.description.EventHeaderLength
+		}
+		if int64(len(headerData)) != eventHeaderLength {
+			headerData = make([]byte, eventHeaderLength)
+		}
+
+		if _, err := io.ReadFull(session.buf, headerData); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		header, err := decodeEventHeader(headerData, eventHeaderLength)
+		if err != nil {
+			return err
+		}
+
+		bodyLength := header.EventSize - eventHeaderLength
+		if header.EventType == FormatDescriptionEvent {
+			data, err := ReadNBytes(session.buf, bodyLength)
+			if err != nil {
+				return err
+			}
+			event := &BinEvent{Header: header}
+			if data, err = event.Validation(session.BinaryLogInfo, headerData, data); err != nil {
+				return err
+			}
+			if session.description, err = decodeFmtDescEvent(data); err != nil {
+				return err
+			}
+		} else if _, err = session.buf.Discard(int(bodyLength)); err != nil {
+			return err
+		}
+		session.offset += header.EventSize
+
+		if !f(header) {
+			return nil
+		}
+	}
+}
+
+// SkippedBytes return the number of bytes skipped by RecoverMode of the default session
+func (decoder *BinFileDecoder) SkippedBytes() int64 {
+	return decoder.skipped
+}
+
+// recover scan forward from the start of the failed event byte by byte for a plausible event header,
+// which has a known event type, a sane size and the log position continuity, then move the session to it.
+// Return io.EOF if not found.
+func (decoder *BinFileDecoder) recover(session *decodeSession) error {
+	eventHeaderLength := defaultEventHeaderSize
+	if session.description != nil {
+		eventHeaderLength = session.description.EventHeaderLength
+	}
+
+	if session.file == nil {
+		return errNotFile
+	}
+
+	const chunkSize = 64 * 1024
+	chunk := make([]byte, chunkSize+eventHeaderLength)
+	for start := session.eventStart + 1; ; start += chunkSize {
+		n, err := session.file.ReadAt(chunk, start)
+		if n < int(eventHeaderLength) {
+			if err == nil || err == io.EOF {
+				err = io.EOF
+			}
 			return err
 		}
+
+		for i := 0; i+int(eventHeaderLength) <= n && i < chunkSize; i++ {
+			header, err := decodeEventHeader(chunk[i:i+int(eventHeaderLength)], eventHeaderLength)
+			if err != nil || !decoder.plausibleHeader(header, start+int64(i), eventHeaderLength) {
+				continue
+			}
+
+			pos := start + int64(i)
+			if _, err := session.file.Seek(pos, io.SeekStart); err != nil {
+				return err
+			}
+			session.buf.Reset(session.file)
+			decoder.logger().Warnf("skip %d bytes from %d to recover at %s at %d", pos-session.eventStart, session.eventStart, header.EventType, pos)
+			session.skipped += pos - session.eventStart
+			session.offset = pos
+			return nil
+		}
+	}
+}
+
+// plausibleHeader return bool of if the header at offset looks like a valid event header
+func (decoder *BinFileDecoder) plausibleHeader(header *BinEventHeader, offset int64, eventHeaderLength int64) bool {
+	if _, ok := EventType2Str[header.EventType]; !ok || header.EventType == UnknownEvent {
+		return false
+	}
+	if header.EventSize < eventHeaderLength || header.EventSize > decoder.Option.maxEventSize() {
+		return false
+	}
+	return header.LogPos == offset+header.EventSize
+}
+
+// followRotate open the next binary log if FollowRotate and the event is a genuine ROTATE_EVENT.
+// The ROTATE_EVENT with LOG_EVENT_ARTIFICIAL_F flag is sent by master at connection, it is not a rotation.
+func (decoder *BinFileDecoder) followRotate(session *decodeSession, event *BinEvent) (*BinFileDecoder, error) {
+	rotate, ok := event.Body.(*BinRotateEvent)
+	if !ok || decoder.Option == nil || !decoder.Option.FollowRotate || event.Header.Flags().Artificial || session.file == nil {
+		return nil, nil
+	}
+	// the ROTATE_EVENT of master in relay log points to binary log of master, which is not on disk
+	if decoder.Option.RelayLog && !event.Header.Flags().RelayLog {
+		return nil, nil
+	}
+
+	next, err := decoder.openNext(session, filepath.Join(filepath.Dir(decoder.Path), rotate.FileName))
+	if err != nil {
+		return nil, err
+	}
+	decoder.logger().Debugf("follow rotate to %s", next.Path)
+	return next, nil
+}
+
+// openNext open the next binary log at path with the same options, which continues the walk of session,
+// e.g. FollowRotate and Tail. The next is linked after decoder if session is the default session,
+// the caller closes the file of session.
+func (decoder *BinFileDecoder) openNext(session *decodeSession, path string) (*BinFileDecoder, error) {
+	var options []*BinReaderOption
+	if decoder.Option != nil {
+		// StartPos and EndPos only apply to the first binary log
+		option := *decoder.Option
+		option.StartPos, option.EndPos = 0, 0
+		if session.startFuncMet {
+			option.StartFunc = nil
+		}
+		options = append(options, &option)
+	}
+	next, err := NewBinFileDecoder(path, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	next.masterFile, next.masterPos = session.masterFile, session.masterPos
+	next.dispatched, next.dispatchedRows = session.dispatched, session.dispatchedRows
+	// the column names invalidated by DDL of the previous binary logs
+	next.columnNames = session.columnNames
+
+	// only the default session links the binary logs
+	if session == decoder.decodeSession {
+		decoder.next = next
+		next.prev = decoder
+	}
+	return next, nil
+}
+
+// skipGTID return bool of if the event belongs to a transaction contained in StartGTID.
+// the transaction lasts from the GTID_EVENT to XID_EVENT or COMMIT, or a single DDL QUERY_EVENT.
+func (session *decodeSession) skipGTID(event *BinEvent, option *BinReaderOption) bool {
+	if option == nil || option.StartGTID == nil {
+		return false
+	}
+	if gtid, ok := event.Body.(*BinGTIDEvent); ok {
+		session.skippingGTID = option.StartGTID.Contains(gtid.SID, gtid.GNO)
+		return session.skippingGTID
+	}
+
+	skip := session.skippingGTID
+	if !session.inTransaction {
+		session.skippingGTID = false
+	}
+	return skip
+}
+
+// trackTransactionTime remember the microsecond time of transaction, original_commit_timestamp of GTID_EVENT,
+// which is overridden by Q_MICROSECONDS of BEGIN, and attach it to the events of transaction
+func (session *decodeSession) trackTransactionTime(event *BinEvent) {
+	switch body := event.Body.(type) {
+	case *BinGTIDEvent:
+		session.transactionTime = body.OriginalCommitTime
+	case *BinQueryEvent:
+		if us := body.microseconds(); us != 0 {
+			session.transactionTime = time.Unix(event.Header.Timestamp, int64(us)*int64(time.Microsecond))
+		}
+	}
+	event.transactionTime = session.transactionTime
+	if _, ok := event.TransactionEnd(); ok {
+		session.transactionTime = time.Time{}
+	}
+}
+
+// TableMaps return a copy of the TABLE_MAP_EVENTs seen so far by the default session, table id => table map
+func (decoder *BinFileDecoder) TableMaps() map[uint64]*BinTableMapEvent {
+	tableMaps := make(map[uint64]*BinTableMapEvent, len(decoder.tableInfo))
+	for id, table := range decoder.tableInfo {
+		tableMaps[id] = table
+	}
+	return tableMaps
+}
+
+// EndReason return how the binary log ends after a walk completes, EndUnknown if the walk stopped before the end.
+// It is the end of the last followed binary log if FollowRotate.
+func (decoder *BinFileDecoder) EndReason() EndReason {
+	for decoder.next != nil {
+		decoder = decoder.next
+	}
+	return decoder.endReason
+}
+
+// MasterPosition return the master coordinates of the last walked event in relay log
+func (decoder *BinFileDecoder) MasterPosition() (string, int64) {
+	// the last followed relay log
+	for decoder.next != nil {
+		decoder = decoder.next
+	}
+	return decoder.masterFile, decoder.masterPos
+}
+
+// trackMaster update the master coordinates by the events copied from master
+func (session *decodeSession) trackMaster(event *BinEvent) {
+	flags := event.Header.Flags()
+	if flags.RelayLog {
+		return
+	}
+	if rotate, ok := event.Body.(*BinRotateEvent); ok {
+		session.masterFile, session.masterPos = rotate.FileName, int64(rotate.Position)
+		return
+	}
+	// FORMAT_DESCRIPTION_EVENT may be written by slave itself without flag
+	if !flags.Artificial && event.Header.LogPos != 0 && event.Header.EventType != FormatDescriptionEvent {
+		session.masterPos = event.Header.LogPos
+	}
+}
+
+// trackTransaction update whether the session is inside a transaction
+func (session *decodeSession) trackTransaction(event *BinEvent) {
+	switch body := event.Body.(type) {
+	case *BinXIDEvent, *BinXAPrepareEvent:
+		session.inTransaction = false
+	case *BinQueryEvent:
+		query := strings.ToUpper(strings.TrimSpace(body.Query))
+		switch {
+		case query == "BEGIN", strings.HasPrefix(query, "XA START"):
+			session.inTransaction = true
+		case query == "COMMIT", query == "ROLLBACK":
+			session.inTransaction = false
+		}
 	}
 }
@@ -0,0 +1,67 @@
+package binlog
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// commands of replication protocol
+// https://dev.mysql.com/doc/internals/en/com-binlog-dump.html
+// https://dev.mysql.com/doc/internals/en/com-binlog-dump-gtid.html
+const (
+	ComBinlogDump     = 0x12
+	ComBinlogDumpGTID = 0x1e
+)
+
+// flags of COM_BINLOG_DUMP and COM_BINLOG_DUMP_GTID
+const (
+	BinlogDumpNonBlock    uint16 = 0x01
+	BinlogThroughPosition uint16 = 0x02
+	BinlogThroughGTID     uint16 = 0x04
+)
+
+// BinlogDump describe where the master starts to send binary log,
+// by file and position, or by GTID set if GTIDSet is not nil (auto-positioning),
+// then the master skips the transactions in GTIDSet and picks the starting file itself.
+// Only the command is built here, the connection and authentication are up to the client.
+type BinlogDump struct {
+	ServerID uint32
+	Flags    uint16
+
+	// file/pos mode, ignored by the master in GTID mode
+	FileName string
+	Position uint32
+
+	// GTID mode
+	GTIDSet *GTIDSet
+}
+
+// Command return the payload of COM_BINLOG_DUMP, or COM_BINLOG_DUMP_GTID if GTIDSet is not nil,
+// without the packet header
+func (dump *BinlogDump) Command() ([]byte, error) {
+	if dump.GTIDSet == nil {
+		// command(1), pos(4), flags(2), server_id(4), file name(string.EOF)
+		data := make([]byte, 11, 11+len(dump.FileName))
+		data[0] = ComBinlogDump
+		binary.LittleEndian.PutUint32(data[1:], dump.Position)
+		binary.LittleEndian.PutUint16(data[5:], dump.Flags)
+		binary.LittleEndian.PutUint32(data[7:], dump.ServerID)
+		return append(data, dump.FileName...), nil
+	}
+
+	gtids, err := dump.GTIDSet.Encode()
+	if err != nil {
+		return nil, fmt.Errorf("encode gtid set: %w", err)
+	}
+
+	// command(1), flags(2), server_id(4), file name length(4), file name, pos(8), data size(4), data
+	flags := dump.Flags | BinlogThroughGTID
+	data := make([]byte, 0, 23+len(dump.FileName)+len(gtids))
+	data = append(data, ComBinlogDumpGTID, byte(flags), byte(flags>>8))
+	data = appendUint32(data, dump.ServerID)
+	data = appendUint32(data, uint32(len(dump.FileName)))
+	data = append(data, dump.FileName...)
+	data = appendUint64(data, uint64(dump.Position))
+	data = appendUint32(data, uint32(len(gtids)))
+	return append(data, gtids...), nil
+}
@@ -0,0 +1,47 @@
+package binlog
+
+// EndReason describe how a binary log ends
+type EndReason int
+
+const (
+	// EndUnknown means the walk is not finished, or stopped before the end
+	EndUnknown EndReason = iota
+	// EndRotate means the binary log ends with ROTATE_EVENT, a normal rotation
+	EndRotate
+	// EndStop means the binary log ends with STOP_EVENT, the server shut down cleanly
+	EndStop
+	// EndAbrupt means the last event is complete, but neither ROTATE_EVENT nor STOP_EVENT,
+	// e.g. the server crashed or the binary log is still being written
+	EndAbrupt
+	// EndTruncated means the last event is incomplete
+	EndTruncated
+)
+
+var endReason2Str = map[EndReason]string{
+	EndUnknown:   "unknown",
+	EndRotate:    "rotate",
+	EndStop:      "stop",
+	EndAbrupt:    "abrupt",
+	EndTruncated: "truncated",
+}
+
+// String return the name of end reason
+func (r EndReason) String() string {
+	return endReason2Str[r]
+}
+
+// Clean return bool of if the binary log ends cleanly, with ROTATE_EVENT or STOP_EVENT
+func (r EndReason) Clean() bool {
+	return r == EndRotate || r == EndStop
+}
+
+// endReasonOf return the end reason of binary log by the type of the last event
+func endReasonOf(lastEventType EventType) EndReason {
+	switch lastEventType {
+	case RotateEvent:
+		return EndRotate
+	case StopEvent:
+		return EndStop
+	}
+	return EndAbrupt
+}
@@ -0,0 +1,39 @@
+package binlog
+
+import "fmt"
+
+// QueryErrorMessages is the messages of common server error codes, which may be logged in the
+// error_code of QUERY_EVENT, e.g. the statement killed after modifying a non-transactional table.
+// https://dev.mysql.com/doc/mysql-errors/8.0/en/server-error-reference.html
+var QueryErrorMessages = map[uint16]string{
+	1007: "ER_DB_CREATE_EXISTS: Can't create database; database exists",
+	1008: "ER_DB_DROP_EXISTS: Can't drop database; database doesn't exist",
+	1032: "ER_KEY_NOT_FOUND: Can't find record",
+	1050: "ER_TABLE_EXISTS_ERROR: Table already exists",
+	1051: "ER_BAD_TABLE_ERROR: Unknown table",
+	1053: "ER_SERVER_SHUTDOWN: Server shutdown in progress",
+	1054: "ER_BAD_FIELD_ERROR: Unknown column",
+	1060: "ER_DUP_FIELDNAME: Duplicate column name",
+	1061: "ER_DUP_KEYNAME: Duplicate key name",
+	1062: "ER_DUP_ENTRY: Duplicate entry for key",
+	1091: "ER_CANT_DROP_FIELD_OR_KEY: Can't drop field or key; check that it exists",
+	1146: "ER_NO_SUCH_TABLE: Table doesn't exist",
+	1205: "ER_LOCK_WAIT_TIMEOUT: Lock wait timeout exceeded",
+	1213: "ER_LOCK_DEADLOCK: Deadlock found when trying to get lock",
+	1317: "ER_QUERY_INTERRUPTED: Query execution was interrupted",
+	1364: "ER_NO_DEFAULT_FOR_FIELD: Field doesn't have a default value",
+	1406: "ER_DATA_TOO_LONG: Data too long for column",
+	1451: "ER_ROW_IS_REFERENCED_2: Cannot delete or update a parent row: a foreign key constraint fails",
+	1452: "ER_NO_REFERENCED_ROW_2: Cannot add or update a child row: a foreign key constraint fails",
+}
+
+// QueryErrorMessage return the message of server error code, empty if code is 0
+func QueryErrorMessage(code uint16) string {
+	if code == 0 {
+		return ""
+	}
+	if message, ok := QueryErrorMessages[code]; ok {
+		return message
+	}
+	return fmt.Sprintf("unknown error %d", code)
+}
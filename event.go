@@ -4,7 +4,8 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
-	"strconv"
+	"io"
+	"math"
 	"strings"
 	"time"
 )
@@ -20,6 +21,45 @@ type BinEvent struct {
 	Body         BinEventBody
 	ChecksumType byte
 	ChecksumVal  []byte
+
+	// false if the checksum of event is mismatched, true if matched or the event has no checksum.
+	// the events failed checksum are only returned if LenientChecksum
+	ChecksumOK bool
+
+	// the microsecond time of the transaction the event belongs to, tracked while walking
+	transactionTime time.Time
+
+	// file offset of the end of event, 0 if unknown
+	endPos int64
+}
+
+// EndPosition return the position just after the event, which is passed to SeekTo or StartPos
+// to resume after it, e.g. checkpoint. It is the file offset where the event is read, including
+// the stripped checksum, or Header.LogPos if the offset is unknown (e.g. DecodeEventBytes).
+// Header.LogPos may differ from the file offset, e.g. the events copied from master into relay log.
+func (event *BinEvent) EndPosition() int64 {
+	if event.endPos > 0 {
+		return event.endPos
+	}
+	return event.Header.LogPos
+}
+
+// Time return the time of event, with microseconds if QUERY_EVENT has Q_MICROSECONDS status var.
+// ROWS_EVENT takes the microseconds of its transaction, from Q_MICROSECONDS of BEGIN
+// or original_commit_timestamp of GTID_EVENT, if they are in the same second as the header.
+func (event *BinEvent) Time() time.Time {
+	t := event.Header.Time()
+	switch body := event.Body.(type) {
+	case *BinQueryEvent:
+		if us := body.microseconds(); us != 0 {
+			t = t.Add(time.Duration(us) * time.Microsecond)
+		}
+	case *BinRowsEvent:
+		if !event.transactionTime.IsZero() && event.transactionTime.Unix() == event.Header.Timestamp {
+			t = t.Add(time.Duration(event.transactionTime.Nanosecond()))
+		}
+	}
+	return t
 }
 
 func (event *BinEvent) GetType() (string, bool) {
@@ -41,20 +81,69 @@ func (event *BinEvent) Validation(bin *BinaryLogInfo, header, body []byte) ([]by
 		return body, fmt.Errorf("event size got %d need %d", l, event.Header.EventSize)
 	}
 
-	if bin.description != nil && bin.description.hasCheckSum {
-		index := len(body) - binlogChecksumLength - 1
-		event.ChecksumType = body[index]
-		event.ChecksumVal = body[index+1:]
-		body = body[:index+1]
+	if checksumType, ok := event.checksumType(bin, body); ok {
+		// FORMAT_DESCRIPTION_EVENT always reserves the CRC32 size after the algorithm byte, even if NONE
+		checksumLength := binlogChecksumLength
+		if event.Header.EventType != FormatDescriptionEvent {
+			length, known := ChecksumLength(checksumType)
+			if !known && !bin.skipChecksumVerify {
+				return body, fmt.Errorf("unsupported binlog checksum algorithm %d", checksumType)
+			}
+			// the trailer of unknown algorithm is assumed to be CRC32 size if the checksum is not verified
+			if known {
+				checksumLength = length
+			}
+		}
+		if len(body) < checksumLength {
+			return body, fmt.Errorf("%s body size %d is smaller than checksum size %d",
+				event.Header.Type(), len(body), checksumLength)
+		}
+		index := len(body) - checksumLength
+		event.ChecksumType = checksumType
+		event.ChecksumVal = body[index:]
+		body = body[:index]
+
+		if bin.skipChecksumVerify {
+			event.ChecksumOK = true
+			return body, nil
+		}
+
+		data := append(append(make([]byte, 0, len(header)+len(body)), header...), body...)
+		// the LOG_EVENT_BINLOG_IN_USE_F flag of FORMAT_DESCRIPTION_EVENT is cleared
+		// after the binlog file is closed, it is not included in the checksum
+		if event.Header.EventType == FormatDescriptionEvent && len(header) > eventFlagOffset {
+			data[eventFlagOffset] &^= byte(LogEventBinlogInUseF)
+		}
 
-		if !ChecksumValidate(event.ChecksumType, event.ChecksumVal, append(header, body...)) || len(event.ChecksumVal) != 4 {
-			return body, fmt.Errorf("binlog checksum validation failed")
+		if err := VerifyChecksum(event.ChecksumType, event.ChecksumVal, data); err != nil {
+			return body, err
 		}
 	}
 
+	event.ChecksumOK = true
 	return body, nil
 }
 
+// checksumType return the checksum algorithm of event and whether the event has a checksum trailer
+func (event *BinEvent) checksumType(bin *BinaryLogInfo, body []byte) (byte, bool) {
+	// FORMAT_DESCRIPTION_EVENT describes its own checksum algorithm,
+	// which is the byte before the checksum value
+	if event.Header.EventType == FormatDescriptionEvent {
+		if len(body) < fmtDescPostHeaderLength+binlogChecksumLength+1 {
+			return BinlogChecksumAlgOff, false
+		}
+		if !hasChecksum(serverVersion(body[2 : 2+serverVersionLength])) {
+			return BinlogChecksumAlgOff, false
+		}
+		return body[len(body)-binlogChecksumLength-1], true
+	}
+
+	if bin.description == nil || !bin.description.hasCheckSum {
+		return BinlogChecksumAlgOff, false
+	}
+	return bin.description.ChecksumAlgorithm, true
+}
+
 // BaseEventBody is base off all events
 type BaseEventBody struct{}
 
@@ -75,11 +164,17 @@ func decodeUnSupportEvent(data []byte) (*BinEventUnParsed, error) {
 // mysql binlog version > 1 (version > mysql 4.0.0), size = 19
 var defaultEventHeaderSize int64 = 19
 
+// eventSizeOffset is the offset of event_size in event header
+const eventSizeOffset = 9
+
+// eventFlagOffset is the offset of flags in event header
+const eventFlagOffset = 17
+
 // BinEventHeader binary log header definition
 // https://dev.mysql.com/doc/internals/en/binlog-event-header.html
 type BinEventHeader struct {
 	Timestamp int64
-	EventType uint8
+	EventType EventType
 	ServerID  int64
 	EventSize int64
 	LogPos    int64
@@ -91,11 +186,16 @@ func (header *BinEventHeader) Type() string {
 	return EventType2Str[header.EventType]
 }
 
+// Time return the timestamp of event header, in seconds
+func (header *BinEventHeader) Time() time.Time {
+	return time.Unix(header.Timestamp, 0)
+}
+
 // String interface implement
 func (header *BinEventHeader) String() string {
 	return fmt.Sprintf("Type:%s, Time:%s, ServerID:%d, EventSize:%d, EventEndPos:%d, Flag:0x%x",
 		header.Type(),
-		time.Unix(header.Timestamp, 0),
+		header.Time(),
 		header.Timestamp,
 		header.EventSize,
 		header.LogPos,
@@ -103,6 +203,36 @@ func (header *BinEventHeader) String() string {
 	)
 }
 
+// EventFlags is the decoded flags of event header
+type EventFlags struct {
+	BinlogInUse           bool // binlog file is not closed properly, only in FORMAT_DESCRIPTION_EVENT
+	ForcedRotate          bool
+	ThreadSpecific        bool // query depends on thread, e.g. temporary table
+	SuppressUse           bool // suppress generation of USE statement
+	UpdateTableMapVersion bool
+	Artificial            bool // event is created by server, e.g. fake ROTATE_EVENT at connection
+	RelayLog              bool // event is created by slave, written in relay log
+	Ignorable             bool // event can be ignored if the type is unknown
+	NoFilter              bool // event should not be filtered, e.g. by replicate-do-db
+	MTSIsolate            bool // event should be isolated in multi-threaded slave
+}
+
+// Flags return the decoded flags of event header
+func (header *BinEventHeader) Flags() EventFlags {
+	return EventFlags{
+		BinlogInUse:           header.Flag&LogEventBinlogInUseF != 0,
+		ForcedRotate:          header.Flag&LogEventForcedRotateF != 0,
+		ThreadSpecific:        header.Flag&LogEventThreadSpecificF != 0,
+		SuppressUse:           header.Flag&LogEventSuppressUseF != 0,
+		UpdateTableMapVersion: header.Flag&LogEventUpdateTableMapVersionF != 0,
+		Artificial:            header.Flag&LogEventArtificialF != 0,
+		RelayLog:              header.Flag&LogEventRelayLogF != 0,
+		Ignorable:             header.Flag&LogEventIgnorableF != 0,
+		NoFilter:              header.Flag&LogEventNoFilterF != 0,
+		MTSIsolate:            header.Flag&LogEventMTSIsolateF != 0,
+	}
+}
+
 func decodeEventHeader(data []byte, size int64) (*BinEventHeader, error) {
 	if l := len(data); int64(l) < size {
 		return nil, fmt.Errorf("invalid event header size %d, should be %d", l, size)
@@ -117,7 +247,7 @@ func decodeEventHeader(data []byte, size int64) (*BinEventHeader, error) {
 	pos += 4
 
 	// event_type
-	eventHeader.EventType = data[pos]
+	eventHeader.EventType = EventType(data[pos])
 	pos++
 
 	// serverId
@@ -140,6 +270,33 @@ func decodeEventHeader(data []byte, size int64) (*BinEventHeader, error) {
 	return eventHeader, nil
 }
 
+// serverVersionLength is the length of mysql-server version in FORMAT_DESCRIPTION_EVENT
+const serverVersionLength = 50
+
+// fmtDescPostHeaderLength is the length before event type header lengths in FORMAT_DESCRIPTION_EVENT
+const fmtDescPostHeaderLength = 2 + serverVersionLength + 4 + 1
+
+func serverVersion(data []byte) string {
+	return string(bytes.TrimRight(data, "\x00"))
+}
+
+// decodeStartEventV3 return the error of START_EVENT_V3, which starts the binary logs of binlog_version 1 and 3
+// (mysql 3.23 and 4.x) instead of FORMAT_DESCRIPTION_EVENT. Their event layouts are different and not supported.
+// https://dev.mysql.com/doc/internals/en/start-event-v3.html
+func decodeStartEventV3(header *BinEventHeader, data []byte) error {
+	// binlog_version(2), server_version(50), create_timestamp(4)
+	const startEventV3Length = 2 + serverVersionLength + 4
+	if header.EventSize == 13+startEventV3Length {
+		// the event header of binlog_version 1 is 13 bytes, the body is partially read as the 19 bytes header
+		return fmt.Errorf("%w: binlog_version 1 of START_EVENT_V3, only binlog_version 4 (mysql 5.0+) is supported", ErrUnsupportedBinlogVersion)
+	}
+	if len(data) < startEventV3Length {
+		return fmt.Errorf("%w: START_EVENT_V3", ErrUnsupportedBinlogVersion)
+	}
+	return fmt.Errorf("%w: binlog_version %d of START_EVENT_V3 (server %s), only binlog_version 4 (mysql 5.0+) is supported",
+		ErrUnsupportedBinlogVersion, binary.LittleEndian.Uint16(data), serverVersion(data[2:2+serverVersionLength]))
+}
+
 // BinFmtDescEvent is the definition of FORMAT_DESCRIPTION_EVENT
 // https://dev.mysql.com/doc/internals/en/format-description-event.html
 type BinFmtDescEvent struct {
@@ -149,23 +306,64 @@ type BinFmtDescEvent struct {
 	CreateTime        int64
 	EventHeaderLength int64
 	EventTypeHeader   []byte
+	ChecksumAlgorithm byte
 
 	// cache the result of hasCheckSum()
 	hasCheckSum bool
 }
 
+// defaultEventTypeHeader is the post header lengths of event types (from START_EVENT_V3)
+// written by mysql 5.7 and 8.0, the types unknown to 5.7 are never written by it
+var defaultEventTypeHeader = []byte{
+	56, 13, 0, 8, 0, 18, 0, 4, 4, 4, 4, 18, 0, 0, 95, 0, 4, 26, 8, 0,
+	0, 0, 8, 8, 8, 2, 0, 0, 0, 10, 10, 10, 42, 42, 0, 18, 52, 0, 10, 40,
+	0,
+}
+
+// NewFormatDescription return the FORMAT_DESCRIPTION_EVENT of mysql 5.0+ server, with the standard
+// 19 bytes event header and the post header lengths of mysql 5.7 and 8.0, e.g. decode a fragment of
+// binary log without FORMAT_DESCRIPTION_EVENT by SetFormatDescription. checksumAlgorithm is
+// binlog_checksum of server, which is ignored before mysql 5.6.2.
+func NewFormatDescription(serverVersion string, checksumAlgorithm byte) *BinFmtDescEvent {
+	desc := &BinFmtDescEvent{
+		BinlogVersion:     4,
+		MySQLVersion:      serverVersion,
+		EventHeaderLength: defaultEventHeaderSize,
+		EventTypeHeader:   append([]byte(nil), defaultEventTypeHeader...),
+	}
+	if hasChecksum(serverVersion) {
+		desc.ChecksumAlgorithm = checksumAlgorithm
+	}
+	desc.hasCheckSum = desc.ChecksumAlgorithm != BinlogChecksumAlgOff &&
+		desc.ChecksumAlgorithm != BinlogChecksumAlgUndef
+	return desc
+}
+
+// postHeaderLength return the post header length of event type, 0 if the event type is not described
+func (desc *BinFmtDescEvent) postHeaderLength(eventType EventType) int {
+	if eventType < 1 || int(eventType) > len(desc.EventTypeHeader) {
+		return 0
+	}
+	return int(desc.EventTypeHeader[eventType-1])
+}
+
 func decodeFmtDescEvent(data []byte) (*BinFmtDescEvent, error) {
+	if len(data) < fmtDescPostHeaderLength {
+		return nil, io.ErrUnexpectedEOF
+	}
 	var pos int
 	desc := &BinFmtDescEvent{}
 
 	// binlog-version
 	desc.BinlogVersion = int(binary.LittleEndian.Uint16(data))
 	pos += 2
+	if desc.BinlogVersion != 4 {
+		return nil, fmt.Errorf("%w: binlog_version %d of FORMAT_DESCRIPTION_EVENT", ErrUnsupportedBinlogVersion, desc.BinlogVersion)
+	}
 
 	// mysql-server version
-	desc.MySQLVersion = string(bytes.Trim(data[pos:pos+50], strconv.Itoa(0x00)))
-	desc.hasCheckSum = hasChecksum(desc.MySQLVersion)
-	pos += 50
+	desc.MySQLVersion = serverVersion(data[pos : pos+serverVersionLength])
+	pos += serverVersionLength
 
 	// create timestamp
 	desc.CreateTime = int64(binary.LittleEndian.Uint32(data[pos:]))
@@ -174,10 +372,24 @@ func decodeFmtDescEvent(data []byte) (*BinFmtDescEvent, error) {
 	// event header length
 	desc.EventHeaderLength = int64(data[pos])
 	pos++
+	if desc.EventHeaderLength < defaultEventHeaderSize {
+		return nil, fmt.Errorf("invalid event header length %d of FORMAT_DESCRIPTION_EVENT", desc.EventHeaderLength)
+	}
 
 	// event type header lengths
 	desc.EventTypeHeader = data[pos:]
 
+	// checksum algorithm, the checksum value has been stripped in Validation
+	if hasChecksum(desc.MySQLVersion) {
+		if len(data) <= pos {
+			return nil, fmt.Errorf("invalid format description event size %d", len(data))
+		}
+		desc.ChecksumAlgorithm = data[len(data)-1]
+		desc.EventTypeHeader = data[pos : len(data)-1]
+	}
+	desc.hasCheckSum = desc.ChecksumAlgorithm != BinlogChecksumAlgOff &&
+		desc.ChecksumAlgorithm != BinlogChecksumAlgUndef
+
 	return desc, nil
 }
 
@@ -191,13 +403,55 @@ type BinQueryEvent struct {
 	statusVarsLength int
 	StatusVars       []byte
 	Schema           string
-	Query            string
+	Query            string // best-effort UTF-8 rendering, the invalid bytes are replaced by U+FFFD
+
+	// raw query in the connection charset, which is ClientCharset of DecodeStatusVars, e.g. latin1, gbk
+	QueryBytes []byte
+
+	// INTVAR, RAND and USER_VAR events logged before the statement, nil if none
+	Context *StatementContext
+
+	// resolved from status_vars to interpret Query, e.g. the identifiers and literals of DDL.
+	// Charset is the name of character_set_client, empty if unknown
+	Charset            string
+	ANSIQuotes         bool // sql_mode ANSI_QUOTES, double quotes enclose identifiers rather than strings
+	NoBackslashEscapes bool // sql_mode NO_BACKSLASH_ESCAPES, backslash is an ordinary character in strings
+}
+
+// Failed return bool of if the statement failed on the master, i.e. ErrorCode is not 0.
+// The failed statement is logged since it has partially changed the data, e.g. a non-transactional table,
+// it must not be applied again by replay.
+func (e *BinQueryEvent) Failed() bool {
+	return e.ErrorCode != 0
+}
+
+// ErrorMessage return the message of ErrorCode, empty if not failed
+func (e *BinQueryEvent) ErrorMessage() string {
+	return QueryErrorMessage(e.ErrorCode)
+}
+
+// String interface implement
+func (e *BinQueryEvent) String() string {
+	s := fmt.Sprintf("Schema:%s, Query:%s, ExecutionTime:%d, ErrorCode:%d", e.Schema, e.Query, e.ExecutionTime, e.ErrorCode)
+	if e.Failed() {
+		s += fmt.Sprintf(" (%s)", e.ErrorMessage())
+	}
+	return s
 }
 
 func decodeQueryEvent(data []byte, binlogVersion int) (*BinQueryEvent, error) {
 	var pos int
 	event := &BinQueryEvent{}
 
+	// slave_proxy_id(4), execution_time(4), schema_length(1), error_code(2), status_vars_length(2, since v4)
+	fixedLength := 11
+	if binlogVersion >= 4 {
+		fixedLength += 2
+	}
+	if len(data) < fixedLength {
+		return nil, fmt.Errorf("invalid query event size %d", len(data))
+	}
+
 	// slave_proxy_id
 	event.SlaveProxyID = int64(binary.LittleEndian.Uint32(data[pos:]))
 	pos += 4
@@ -218,6 +472,9 @@ func decodeQueryEvent(data []byte, binlogVersion int) (*BinQueryEvent, error) {
 		// status-vars length
 		event.statusVarsLength = int(binary.LittleEndian.Uint16(data[pos:]))
 		pos += 2
+		if len(data) < pos+event.statusVarsLength {
+			return nil, fmt.Errorf("invalid query event size %d, status vars length %d", len(data), event.statusVarsLength)
+		}
 
 		// status-vars
 		event.StatusVars = data[pos : pos+event.statusVarsLength]
@@ -225,6 +482,9 @@ func decodeQueryEvent(data []byte, binlogVersion int) (*BinQueryEvent, error) {
 	}
 
 	// schema
+	if len(data) < pos+schemaLength+1 {
+		return nil, fmt.Errorf("invalid query event size %d, schema length %d", len(data), schemaLength)
+	}
 	event.Schema = string(data[pos : pos+schemaLength])
 	pos += schemaLength
 
@@ -232,100 +492,218 @@ func decodeQueryEvent(data []byte, binlogVersion int) (*BinQueryEvent, error) {
 	pos++
 
 	// query
-	event.Query = string(data[pos:])
+	event.QueryBytes = data[pos:]
+	event.Query = strings.ToValidUTF8(string(event.QueryBytes), "\uFFFD")
+
+	if vars, err := event.DecodeStatusVars(); err == nil {
+		event.Charset = CharsetName(vars.ClientCharset)
+		event.ANSIQuotes = vars.SQLMode&SQLModeANSIQuotes != 0
+		event.NoBackslashEscapes = vars.SQLMode&SQLModeNoBackslashEscapes != 0
+	}
 	return event, nil
 }
 
-// Statue will format status_vars of QUERY_EVENT
-// TODO decode QUERY_EVENT status_var
-func (event *BinQueryEvent) Statue() error {
-	fmt.Println(event.statusVarsLength)
-	for i := 0; i < event.statusVarsLength; {
+// AutoIncrement is the Q_AUTO_INCREMENT status var, auto_increment_increment and auto_increment_offset
+type AutoIncrement struct {
+	Increment uint16
+	Offset    uint16
+}
+
+// QueryStatusVars is the decoded status_vars of QUERY_EVENT
+// https://dev.mysql.com/doc/internals/en/query-event.html
+type QueryStatusVars struct {
+	Flags2              uint32
+	SQLMode             uint64
+	Catalog             string
+	AutoIncrement       *AutoIncrement
+	ClientCharset       uint16
+	CollationConnection uint16
+	CollationServer     uint16
+	TimeZone            string
+	LCTimeNames         uint16
+	CharsetDatabase     uint16
+	TableMapForUpdate   uint64
+	MasterDataWritten   uint32
+	InvokerUser         string
+	InvokerHost         string
+	UpdatedDBNames      []string
+	Microseconds        uint32
+
+	// since mysql 8.0, the boolean session variables are nil if absent
+	ExplicitDefaultsForTimestamp *bool
+	DDLXID                       uint64
+	DefaultCollationForUTF8MB4   uint16
+	SQLRequirePrimaryKey         *bool
+	DefaultTableEncryption       *bool
+}
+
+// microseconds return Q_MICROSECONDS status var, 0 if absent
+func (event *BinQueryEvent) microseconds() uint32 {
+	if vars, err := event.DecodeStatusVars(); err == nil {
+		return vars.Microseconds
+	}
+	return 0
+}
+
+// Statue will format status_vars of QUERY_EVENT into logger
+func (event *BinQueryEvent) Statue(logger Logger) error {
+	vars, err := event.DecodeStatusVars()
+	if err != nil {
+		return err
+	}
+	if logger != nil {
+		logger.Debugf("%+v", vars)
+	}
+	return nil
+}
+
+// DecodeStatusVars will decode status_vars of QUERY_EVENT
+func (event *BinQueryEvent) DecodeStatusVars() (*QueryStatusVars, error) {
+	vars := &QueryStatusVars{}
+	data := event.StatusVars
+	for i := 0; i < len(data); {
 		// got status_vars key
-		k := event.StatusVars[i]
+		k := data[i]
 		i++
 
+		// length of the value, -1 if it is a length prefixed string
+		var n int
+		switch k {
+		case QFlags2Code, QMasterDataWrittenCode:
+			n = 4
+		case QSQLModeCode, QTableMapForUpdateCode:
+			n = 8
+		case QAutoIncrement:
+			n = 4
+		case QCharsetCode:
+			n = 6
+		case QLCTimeNamesCode, QCharsetDatabaseCode:
+			n = 2
+		case QMicroseconds:
+			n = 3
+		case QExplicitDefaultsForTimestamp, QSQLRequirePrimaryKey, QDefaultTableEncryption:
+			n = 1
+		case QDDLLoggedWithXID:
+			n = 8
+		case QDefaultCollationForUTF8MB4:
+			n = 2
+		case QCatalog, QTimeZoneCode, QCatalogNZCode, QInvokers, QUpdatedDBNames:
+			n = -1
+		default:
+			return nil, fmt.Errorf("unknown status var %x", k)
+		}
+		if n > 0 && i+n > len(data) {
+			return nil, fmt.Errorf("invalid status var %s, %w", QStatusKey2Str[k], io.ErrUnexpectedEOF)
+		}
+
 		// decode values
+		var err error
 		switch k {
 		case QFlags2Code:
-			v := event.StatusVars[i : i+4]
-			i += 4
-
-			// TODO
-			fmt.Println("QFlags2Code", v)
+			vars.Flags2 = binary.LittleEndian.Uint32(data[i:])
 		case QSQLModeCode:
-			v := event.StatusVars[i : i+8]
-			i += 8
-
-			// TODO
-			fmt.Println("QSQLModeCode", v)
-		case QCatalog:
-			n := int(event.StatusVars[i])
-			v := string(event.StatusVars[i+1 : i+1+n])
-			i += 1 + n + 1
-
-			// TODO
-			fmt.Println("QCatalog", v)
+			vars.SQLMode = binary.LittleEndian.Uint64(data[i:])
 		case QAutoIncrement:
-			increment := binary.LittleEndian.Uint32(event.StatusVars[i:])
-			offset := binary.LittleEndian.Uint32(event.StatusVars[i+2:])
-
-			// TODO
-			fmt.Printf("QAutoIncrement %d, %d\n", increment, offset)
+			vars.AutoIncrement = &AutoIncrement{
+				Increment: binary.LittleEndian.Uint16(data[i:]),
+				Offset:    binary.LittleEndian.Uint16(data[i+2:]),
+			}
 		case QCharsetCode:
-			clientCharSet := event.StatusVars[i : i+2]
-			i += 2
-			collationConnection := event.StatusVars[i : i+2]
-			i += 2
-			collationServer := event.StatusVars[i : i+2]
-			i += 2
-
-			// TODO
-			fmt.Println("QCharsetCode", clientCharSet, collationConnection, collationServer)
-		case QTimeZoneCode:
-			n := int(event.StatusVars[i])
-			v := string(event.StatusVars[i+1 : i+1+n])
-			i += 1 + n
-
-			// TODO
-			fmt.Println("QTimeZoneCode", v)
-		case QCatalogNZCode:
-			n := int(event.StatusVars[i])
-			v := string(event.StatusVars[i+1 : i+1+n])
-			i += 1 + n
-
-			// TODO
-			fmt.Printf("QCatalogNZCode %s\n", v)
+			vars.ClientCharset = binary.LittleEndian.Uint16(data[i:])
+			vars.CollationConnection = binary.LittleEndian.Uint16(data[i+2:])
+			vars.CollationServer = binary.LittleEndian.Uint16(data[i+4:])
 		case QLCTimeNamesCode:
-			// TODO
-			fmt.Println("QLCTimeNamesCode")
+			vars.LCTimeNames = binary.LittleEndian.Uint16(data[i:])
 		case QCharsetDatabaseCode:
-			// TODO
-			fmt.Println("QCharsetDatabaseCode")
+			vars.CharsetDatabase = binary.LittleEndian.Uint16(data[i:])
 		case QTableMapForUpdateCode:
-			// TODO
-			fmt.Println("QTableMapForUpdateCode")
+			vars.TableMapForUpdate = binary.LittleEndian.Uint64(data[i:])
 		case QMasterDataWrittenCode:
-			// TODO
-			fmt.Println("QMasterDataWrittenCode")
+			vars.MasterDataWritten = binary.LittleEndian.Uint32(data[i:])
+		case QMicroseconds:
+			vars.Microseconds = uint32(FixedLengthInt(data[i : i+3]))
+		case QExplicitDefaultsForTimestamp:
+			vars.ExplicitDefaultsForTimestamp = statusVarBool(data[i])
+		case QDDLLoggedWithXID:
+			vars.DDLXID = binary.LittleEndian.Uint64(data[i:])
+		case QDefaultCollationForUTF8MB4:
+			vars.DefaultCollationForUTF8MB4 = binary.LittleEndian.Uint16(data[i:])
+		case QSQLRequirePrimaryKey:
+			vars.SQLRequirePrimaryKey = statusVarBool(data[i])
+		case QDefaultTableEncryption:
+			vars.DefaultTableEncryption = statusVarBool(data[i])
+		case QCatalog:
+			// length, catalog, 0x00
+			if vars.Catalog, i, err = statusVarString(data, i); err != nil {
+				return nil, err
+			}
+			i++
+		case QTimeZoneCode:
+			if vars.TimeZone, i, err = statusVarString(data, i); err != nil {
+				return nil, err
+			}
+		case QCatalogNZCode:
+			if vars.Catalog, i, err = statusVarString(data, i); err != nil {
+				return nil, err
+			}
 		case QInvokers:
-			// TODO
-			fmt.Println("QInvokers")
+			if vars.InvokerUser, i, err = statusVarString(data, i); err != nil {
+				return nil, err
+			}
+			if vars.InvokerHost, i, err = statusVarString(data, i); err != nil {
+				return nil, err
+			}
 		case QUpdatedDBNames:
-			// TODO
-			fmt.Println("QUpdatedDBNames")
-		case QMicroseconds:
-			microseconds := binary.LittleEndian.Uint32(event.StatusVars[i:])
-			i += 3
-
-			// TODO
-			fmt.Printf("QMicroseconds %d\n", microseconds)
-		default:
-			return fmt.Errorf("unknown status var %x", k)
+			if vars.UpdatedDBNames, i, err = statusVarDBNames(data, i); err != nil {
+				return nil, err
+			}
+		}
+		if n > 0 {
+			i += n
 		}
 	}
+	return vars, nil
+}
 
-	return nil
+// statusVarBool decode a boolean status var
+func statusVarBool(b byte) *bool {
+	v := b != 0
+	return &v
+}
+
+// statusVarString decode a string with 1 byte length, return the string and the next position
+func statusVarString(data []byte, pos int) (string, int, error) {
+	if pos >= len(data) || pos+1+int(data[pos]) > len(data) {
+		return "", pos, io.ErrUnexpectedEOF
+	}
+	n := int(data[pos])
+	return string(data[pos+1 : pos+1+n]), pos + 1 + n, nil
+}
+
+// statusVarDBNames decode Q_UPDATED_DB_NAMES, count and null terminated names
+func statusVarDBNames(data []byte, pos int) ([]string, int, error) {
+	// OVER_MAX_DBS_IN_EVENT_MTS, the names are not written
+	const overMaxDBs = 254
+
+	if pos >= len(data) {
+		return nil, pos, io.ErrUnexpectedEOF
+	}
+	count := int(data[pos])
+	pos++
+	if count == overMaxDBs {
+		return nil, pos, nil
+	}
+
+	names := make([]string, 0, count)
+	for j := 0; j < count; j++ {
+		end := bytes.IndexByte(data[pos:], 0)
+		if end < 0 {
+			return nil, pos, io.ErrUnexpectedEOF
+		}
+		names = append(names, string(data[pos:pos+end]))
+		pos += end + 1
+	}
+	return names, pos, nil
 }
 
 // BinXIDEvent is the definition of XID_EVENT
@@ -337,13 +715,54 @@ type BinXIDEvent struct {
 }
 
 func decodeXIDEvent(data []byte) (*BinXIDEvent, error) {
+	// xid(8)
+	if len(data) < 8 {
+		return nil, fmt.Errorf("invalid xid event size %d", len(data))
+	}
 	e := &BinXIDEvent{XID: binary.LittleEndian.Uint64(data)}
 	return e, nil
 }
 
+// BinXAPrepareEvent is the definition of XA_PREPARE_LOG_EVENT
+// https://github.com/mysql/mysql-server/blob/8.0/libbinlogevents/include/control_events.h (XA_prepare_event)
+// It is written by XA PREPARE or XA COMMIT ... ONE PHASE, ends the events of XA transaction.
+type BinXAPrepareEvent struct {
+	BaseEventBody
+	OnePhase bool
+	FormatID int32
+	GTRID    []byte // global transaction identifier
+	BQUAL    []byte // branch qualifier
+}
+
+func decodeXAPrepareEvent(data []byte) (*BinXAPrepareEvent, error) {
+	// one_phase(1), format_id(4), gtrid_length(4), bqual_length(4), gtrid, bqual
+	if len(data) < 13 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	event := &BinXAPrepareEvent{
+		OnePhase: data[0] != 0,
+		FormatID: int32(binary.LittleEndian.Uint32(data[1:])),
+	}
+	gtridLength := int(binary.LittleEndian.Uint32(data[5:]))
+	bqualLength := int(binary.LittleEndian.Uint32(data[9:]))
+	if gtridLength < 0 || bqualLength < 0 || len(data) < 13+gtridLength+bqualLength {
+		return nil, io.ErrUnexpectedEOF
+	}
+	event.GTRID = data[13 : 13+gtridLength]
+	event.BQUAL = data[13+gtridLength : 13+gtridLength+bqualLength]
+	return event, nil
+}
+
+// types of INTVAR_EVENT
+const (
+	IntvarInvalid      uint8 = 0x00
+	IntvarLastInsertID uint8 = 0x01 // LAST_INSERT_ID()
+	IntvarInsertID     uint8 = 0x02 // INSERT_ID, the first auto-increment value of statement
+)
+
 // BinIntvarEvent is the definition of INTVAR_EVENT
-// https://dev.mysql.com/doc/internals/en/xid-event.html
-// Transaction ID for 2PC, written whenever a COMMIT is expected.
+// https://dev.mysql.com/doc/internals/en/intvar-event.html
+// Written before the statement-based QUERY_EVENT which uses LAST_INSERT_ID() or auto-increment column.
 type BinIntvarEvent struct {
 	BaseEventBody
 	Type  uint8
@@ -351,13 +770,133 @@ type BinIntvarEvent struct {
 }
 
 func decodeIntvarEvent(data []byte) (*BinIntvarEvent, error) {
+	// type(1), value(8)
+	if len(data) < 9 {
+		return nil, io.ErrUnexpectedEOF
+	}
 	return &BinIntvarEvent{
 		Type:  data[0],
 		Value: binary.LittleEndian.Uint64(data[1:]),
 	}, nil
 }
 
-// TODO: BinIntvarEvent.Type format
+// BinRandEvent is the definition of RAND_EVENT
+// https://dev.mysql.com/doc/internals/en/rand-event.html
+// Written before the statement-based QUERY_EVENT which uses RAND(), the seeds of random number generator.
+type BinRandEvent struct {
+	BaseEventBody
+	Seed1 uint64
+	Seed2 uint64
+}
+
+func decodeRandEvent(data []byte) (*BinRandEvent, error) {
+	// seed1(8), seed2(8)
+	if len(data) < 16 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return &BinRandEvent{
+		Seed1: binary.LittleEndian.Uint64(data),
+		Seed2: binary.LittleEndian.Uint64(data[8:]),
+	}, nil
+}
+
+// value types of USER_VAR_EVENT, Item_result of MySQL
+const (
+	UserVarString  uint8 = 0x00
+	UserVarReal    uint8 = 0x01
+	UserVarInt     uint8 = 0x02
+	UserVarRow     uint8 = 0x03
+	UserVarDecimal uint8 = 0x04
+)
+
+// BinUserVarEvent is the definition of USER_VAR_EVENT
+// https://dev.mysql.com/doc/internals/en/user-var-event.html
+// Written before the statement-based QUERY_EVENT for each user variable it uses.
+type BinUserVarEvent struct {
+	BaseEventBody
+	Name     string
+	IsNull   bool
+	Type     uint8
+	Charset  uint32
+	Unsigned bool
+
+	// string for UserVarString and UserVarDecimal (the raw bytes in Charset for string),
+	// int64 or uint64 for UserVarInt, float64 for UserVarReal, nil if IsNull
+	Value interface{}
+	Raw   []byte
+}
+
+func decodeUserVarEvent(data []byte) (*BinUserVarEvent, error) {
+	// name_length(4), name, is_null(1)
+	if len(data) < 5 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	nameLength := int(binary.LittleEndian.Uint32(data))
+	if nameLength < 0 || len(data) < 4+nameLength+1 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	pos := 4
+	event := &BinUserVarEvent{Name: string(data[pos : pos+nameLength])}
+	pos += nameLength
+	event.IsNull = data[pos] != 0
+	pos++
+	if event.IsNull {
+		return event, nil
+	}
+
+	// type(1), charset(4), value_length(4), value, flags(1, since 5.6)
+	if len(data) < pos+9 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	event.Type = data[pos]
+	event.Charset = binary.LittleEndian.Uint32(data[pos+1:])
+	valueLength := int(binary.LittleEndian.Uint32(data[pos+5:]))
+	pos += 9
+	if valueLength < 0 || len(data) < pos+valueLength {
+		return nil, io.ErrUnexpectedEOF
+	}
+	event.Raw = data[pos : pos+valueLength]
+	pos += valueLength
+	const unsignedFlag = 0x01
+	if len(data) > pos {
+		event.Unsigned = data[pos]&unsignedFlag != 0
+	}
+
+	switch event.Type {
+	case UserVarString:
+		event.Value = string(event.Raw)
+	case UserVarReal:
+		if len(event.Raw) < 8 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		event.Value = math.Float64frombits(binary.LittleEndian.Uint64(event.Raw))
+	case UserVarInt:
+		if len(event.Raw) < 8 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		if event.Unsigned {
+			event.Value = binary.LittleEndian.Uint64(event.Raw)
+		} else {
+			event.Value = int64(binary.LittleEndian.Uint64(event.Raw))
+		}
+	case UserVarDecimal:
+		// precision(1), scale(1), binary decimal
+		if len(event.Raw) < 2 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		precision, scale := int(event.Raw[0]), int(event.Raw[1])
+		if !validDecimal(precision, scale) {
+			return nil, fmt.Errorf("invalid user variable DECIMAL(%d,%d)", precision, scale)
+		}
+		if len(event.Raw) < 2+decimalByteSize(precision, scale) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		event.Value = decodeDecimal(event.Raw[2:], precision, scale)
+	default:
+		return nil, fmt.Errorf("invalid user variable type %d", event.Type)
+	}
+	return event, nil
+}
 
 // BinRotateEvent is the definition of ROTATE_EVENT
 // https://dev.mysql.com/doc/internals/en/rotate-event.html
@@ -373,6 +912,9 @@ func decodeRotateEvent(data []byte, binlogVersion int) (*BinRotateEvent, error)
 	var pos int
 	if binlogVersion > 1 {
 		// next_binlog_po
+		if len(data) < 8 {
+			return nil, fmt.Errorf("invalid rotate event size %d", len(data))
+		}
 		event.Position = binary.LittleEndian.Uint64(data)
 		pos += 8
 	}
@@ -382,6 +924,349 @@ func decodeRotateEvent(data []byte, binlogVersion int) (*BinRotateEvent, error)
 	return event, nil
 }
 
+// BinStopEvent is the definition of STOP_EVENT
+// https://dev.mysql.com/doc/internals/en/stop-event.html
+// It is written as the last event of binary log when the server shuts down cleanly, with empty body.
+type BinStopEvent struct {
+	BaseEventBody
+}
+
+// BinGTIDEvent is the definition of GTID_EVENT and ANONYMOUS_GTID_EVENT
+// https://dev.mysql.com/doc/internals/en/gtid-event.html
+// It is written before each transaction, the SID and GNO of ANONYMOUS_GTID_EVENT are zero.
+type BinGTIDEvent struct {
+	BaseEventBody
+	CommitFlag bool
+	SID        string
+	GNO        int64
+
+	// logical clock of multi-threaded slave, since 5.7
+	LastCommitted  int64
+	SequenceNumber int64
+
+	// commit time on the original master and the immediate master, since 8.0.1, zero if absent
+	OriginalCommitTime  time.Time
+	ImmediateCommitTime time.Time
+}
+
+// GTID return the GTID string uuid:gno
+func (e *BinGTIDEvent) GTID() string {
+	return fmt.Sprintf("%s:%d", e.SID, e.GNO)
+}
+
+func decodeGTIDEvent(data []byte) (*BinGTIDEvent, error) {
+	// commit_flag(1), sid(16), gno(8)
+	if len(data) < 25 {
+		return nil, fmt.Errorf("invalid gtid event size %d", len(data))
+	}
+	event := &BinGTIDEvent{
+		CommitFlag: data[0] == 1,
+		SID:        formatUUID(data[1:17]),
+		GNO:        int64(binary.LittleEndian.Uint64(data[17:])),
+	}
+
+	// lt_type(1), last_committed(8), sequence_number(8)
+	const logicalTimestampTypeCode = 2
+	if len(data) >= 42 && data[25] == logicalTimestampTypeCode {
+		event.LastCommitted = int64(binary.LittleEndian.Uint64(data[26:]))
+		event.SequenceNumber = int64(binary.LittleEndian.Uint64(data[34:]))
+	}
+
+	// immediate_commit_timestamp(7), the highest bit indicates original_commit_timestamp(7) follows,
+	// otherwise the original is the same as the immediate. microseconds since epoch
+	const commitTimestampLength = 7
+	const originalCommitTimestampBit = 1 << 55
+	pos := 42
+	if len(data) >= pos+commitTimestampLength {
+		immediate := FixedLengthInt(data[pos : pos+commitTimestampLength])
+		pos += commitTimestampLength
+		original := immediate
+		if immediate&originalCommitTimestampBit != 0 {
+			immediate &^= originalCommitTimestampBit
+			original = immediate
+			if len(data) >= pos+commitTimestampLength {
+				original = FixedLengthInt(data[pos : pos+commitTimestampLength])
+			}
+		}
+		event.ImmediateCommitTime = time.Unix(0, int64(immediate)*int64(time.Microsecond))
+		event.OriginalCommitTime = time.Unix(0, int64(original)*int64(time.Microsecond))
+	}
+	return event, nil
+}
+
 // BinPreGTIDsEvent is the definition of PREVIOUS_GTIDS_EVENT
-// TODO: PREVIOUS_GTIDS_EVENT
-type BinPreGTIDsEvent struct{ BaseEventBody }
+// https://dev.mysql.com/doc/internals/en/previous-gtids-event.html
+// It is written at the beginning of each binary log, contains the GTIDs of all previous binary logs.
+type BinPreGTIDsEvent struct {
+	BaseEventBody
+	GTIDSet *GTIDSet
+}
+
+func decodePreGTIDsEvent(data []byte) (*BinPreGTIDsEvent, error) {
+	if len(data) < 8 {
+		return nil, fmt.Errorf("invalid previous gtids event size %d", len(data))
+	}
+	event := &BinPreGTIDsEvent{GTIDSet: NewGTIDSet()}
+
+	// n_sids(8), then for each sid: sid(16), n_intervals(8), intervals [start(8), end(8))
+	sids := binary.LittleEndian.Uint64(data)
+	pos := 8
+	for i := uint64(0); i < sids; i++ {
+		if len(data) < pos+24 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		uuid := formatUUID(data[pos : pos+16])
+		intervals := binary.LittleEndian.Uint64(data[pos+16:])
+		pos += 24
+		for j := uint64(0); j < intervals; j++ {
+			if len(data) < pos+16 {
+				return nil, io.ErrUnexpectedEOF
+			}
+			event.GTIDSet.AddInterval(uuid, GTIDInterval{
+				Start: int64(binary.LittleEndian.Uint64(data[pos:])),
+				Stop:  int64(binary.LittleEndian.Uint64(data[pos+8:])),
+			})
+			pos += 16
+		}
+	}
+	return event, nil
+}
+
+// BinAppendBlockEvent is the definition of APPEND_BLOCK_EVENT and BEGIN_LOAD_QUERY_EVENT
+// https://dev.mysql.com/doc/internals/en/append-block-event.html
+// It contains a block of the file to be loaded by LOAD DATA INFILE.
+type BinAppendBlockEvent struct {
+	BaseEventBody
+	FileID    uint32
+	BlockData []byte
+}
+
+func decodeAppendBlockEvent(data []byte) (*BinAppendBlockEvent, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("invalid append block event size %d", len(data))
+	}
+	return &BinAppendBlockEvent{
+		FileID:    binary.LittleEndian.Uint32(data),
+		BlockData: data[4:],
+	}, nil
+}
+
+// BinFileIDEvent is the definition of DELETE_FILE_EVENT and EXEC_LOAD_EVENT
+// https://dev.mysql.com/doc/internals/en/delete-file-event.html
+// https://dev.mysql.com/doc/internals/en/exec-load-event.html
+type BinFileIDEvent struct {
+	BaseEventBody
+	FileID uint32
+}
+
+func decodeFileIDEvent(data []byte) (*BinFileIDEvent, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("invalid file id event size %d", len(data))
+	}
+	return &BinFileIDEvent{FileID: binary.LittleEndian.Uint32(data)}, nil
+}
+
+// BinHeartbeatEvent is the definition of HEARTBEAT_EVENT and HEARTBEAT_LOG_EVENT_V2
+// https://dev.mysql.com/doc/internals/en/heartbeat-event.html
+// It is sent by master when there is no event for replication, the position is the LogPos of header
+// for HEARTBEAT_EVENT, or the log position field for HEARTBEAT_LOG_EVENT_V2.
+type BinHeartbeatEvent struct {
+	BaseEventBody
+	FileName string
+	Position int64
+}
+
+func decodeHeartbeatEvent(header *BinEventHeader, data []byte) (*BinHeartbeatEvent, error) {
+	return &BinHeartbeatEvent{
+		FileName: string(bytes.TrimRight(data, "\x00")),
+		Position: header.LogPos,
+	}, nil
+}
+
+// fields of HEARTBEAT_LOG_EVENT_V2
+const (
+	heartbeatHeaderEndMark = 0
+	heartbeatLogFilename   = 1
+	heartbeatLogPosition   = 2
+)
+
+// decodeHeartbeatEventV2 decode HEARTBEAT_LOG_EVENT_V2 (mysql 8.0.26), the position is not limited to 4GB.
+// https://github.com/mysql/mysql-server/blob/8.0/libbinlogevents/include/control_events.h (Heartbeat_event_v2)
+// The body is a list of fields: type(lenenc), length(lenenc), value, ended by OTW_HB_HEADER_END_MARK.
+func decodeHeartbeatEventV2(data []byte) (*BinHeartbeatEvent, error) {
+	event := &BinHeartbeatEvent{}
+	pos := 0
+	for pos < len(data) {
+		fieldType, n, err := readLengthEncodedInt(data, pos)
+		if err != nil {
+			return nil, err
+		}
+		pos += n
+		if fieldType == heartbeatHeaderEndMark {
+			break
+		}
+		length, n, err := readLengthEncodedInt(data, pos)
+		if err != nil {
+			return nil, err
+		}
+		pos += n
+		if uint64(len(data)-pos) < length {
+			return nil, io.ErrUnexpectedEOF
+		}
+		value := data[pos : pos+int(length)]
+		pos += int(length)
+
+		switch fieldType {
+		case heartbeatLogFilename:
+			event.FileName = string(value)
+		case heartbeatLogPosition:
+			position, _, err := readLengthEncodedInt(value, 0)
+			if err != nil {
+				return nil, err
+			}
+			event.Position = int64(position)
+		}
+		// the unknown fields of newer versions are skipped
+	}
+	return event, nil
+}
+
+// readLengthEncodedInt read the length encoded integer at pos of data with bounds check
+func readLengthEncodedInt(data []byte, pos int) (uint64, int, error) {
+	if pos >= len(data) {
+		return 0, 0, io.ErrUnexpectedEOF
+	}
+	size := 1
+	switch data[pos] {
+	case 0xfc:
+		size = 3
+	case 0xfd:
+		size = 4
+	case 0xfe:
+		size = 9
+	}
+	if len(data)-pos < size {
+		return 0, 0, io.ErrUnexpectedEOF
+	}
+	num, _, n := LengthEncodedInt(data[pos:])
+	return num, n, nil
+}
+
+// BinTransactionContextEvent is the definition of TRANSACTION_CONTEXT_EVENT
+// https://github.com/mysql/mysql-server/blob/5.7/libbinlogevents/include/control_events.h
+// It is written by Group Replication to carry the write set of a transaction for certification.
+type BinTransactionContextEvent struct {
+	BaseEventBody
+	ServerUUID      string
+	ThreadID        uint32
+	GTIDSpecified   bool
+	SnapshotVersion []byte
+	WriteSet        []string
+	ReadSet         []string
+}
+
+func decodeTransactionContextEvent(data []byte) (*BinTransactionContextEvent, error) {
+	// post header: server_uuid_len(1), thread_id(4), gtid_specified(1),
+	// snapshot_version_len(4), write_set_items(4), read_set_items(4)
+	if len(data) < 18 {
+		return nil, fmt.Errorf("invalid transaction context event size %d", len(data))
+	}
+	event := &BinTransactionContextEvent{}
+	uuidLength := int(data[0])
+	event.ThreadID = binary.LittleEndian.Uint32(data[1:])
+	event.GTIDSpecified = data[5] == 1
+	snapshotLength := int(binary.LittleEndian.Uint32(data[6:]))
+	writeSetItems := int(binary.LittleEndian.Uint32(data[10:]))
+	readSetItems := int(binary.LittleEndian.Uint32(data[14:]))
+	pos := 18
+
+	if len(data) < pos+uuidLength+snapshotLength {
+		return nil, io.ErrUnexpectedEOF
+	}
+	event.ServerUUID = string(data[pos : pos+uuidLength])
+	pos += uuidLength
+	event.SnapshotVersion = data[pos : pos+snapshotLength]
+	pos += snapshotLength
+
+	var err error
+	if event.WriteSet, pos, err = decodeReadWriteSet(data, pos, writeSetItems); err != nil {
+		return nil, err
+	}
+	if event.ReadSet, _, err = decodeReadWriteSet(data, pos, readSetItems); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// decodeReadWriteSet decode the items of read set or write set, each item has 2 bytes length
+func decodeReadWriteSet(data []byte, pos int, items int) ([]string, int, error) {
+	// the count is untrusted, every item takes 2 bytes at least
+	if items < 0 || items > (len(data)-pos)/2 {
+		return nil, pos, fmt.Errorf("invalid read write set items %d, %d bytes left", items, len(data)-pos)
+	}
+	set := make([]string, 0, items)
+	for i := 0; i < items; i++ {
+		if len(data) < pos+2 {
+			return nil, pos, io.ErrUnexpectedEOF
+		}
+		n := int(binary.LittleEndian.Uint16(data[pos:]))
+		pos += 2
+		if len(data) < pos+n {
+			return nil, pos, io.ErrUnexpectedEOF
+		}
+		set = append(set, string(data[pos:pos+n]))
+		pos += n
+	}
+	return set, pos, nil
+}
+
+// BinViewChangeEvent is the definition of VIEW_CHANGE_EVENT
+// https://github.com/mysql/mysql-server/blob/5.7/libbinlogevents/include/control_events.h
+// It is written by Group Replication when the group membership changes.
+type BinViewChangeEvent struct {
+	BaseEventBody
+	ViewID       string
+	SeqNumber    int64
+	CertInfoSize uint32
+	CertInfo     map[string][]byte
+}
+
+func decodeViewChangeEvent(data []byte) (*BinViewChangeEvent, error) {
+	// post header: view_id(40), seq_number(8), cert_info_size(4)
+	if len(data) < 52 {
+		return nil, fmt.Errorf("invalid view change event size %d", len(data))
+	}
+	event := &BinViewChangeEvent{
+		ViewID:       string(bytes.TrimRight(data[:40], "\x00")),
+		SeqNumber:    int64(binary.LittleEndian.Uint64(data[40:])),
+		CertInfoSize: binary.LittleEndian.Uint32(data[48:]),
+	}
+	pos := 52
+
+	// cert_info: key_len(2), key, value_len(4), value
+	// the size is untrusted, every entry takes 6 bytes at least
+	if int64(event.CertInfoSize) > int64(len(data)-pos)/6 {
+		return nil, fmt.Errorf("invalid view change event cert info size %d, %d bytes left", event.CertInfoSize, len(data)-pos)
+	}
+	event.CertInfo = make(map[string][]byte, event.CertInfoSize)
+	for i := 0; i < int(event.CertInfoSize); i++ {
+		if len(data) < pos+2 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		keyLength := int(binary.LittleEndian.Uint16(data[pos:]))
+		pos += 2
+		if len(data) < pos+keyLength+4 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		key := string(data[pos : pos+keyLength])
+		pos += keyLength
+		valueLength := int(binary.LittleEndian.Uint32(data[pos:]))
+		pos += 4
+		if len(data) < pos+valueLength {
+			return nil, io.ErrUnexpectedEOF
+		}
+		event.CertInfo[key] = data[pos : pos+valueLength]
+		pos += valueLength
+	}
+	return event, nil
+}
@@ -0,0 +1,48 @@
+package binlog
+
+import "strings"
+
+// ServerFlavor is the vendor of server which writes the binary log
+type ServerFlavor int
+
+// server flavors, detected from the server version of FORMAT_DESCRIPTION_EVENT
+const (
+	FlavorMySQL ServerFlavor = iota
+	FlavorMariaDB
+	FlavorAurora // Amazon Aurora MySQL
+	FlavorRDS    // Amazon RDS for MySQL
+)
+
+// String return the name of flavor
+func (f ServerFlavor) String() string {
+	switch f {
+	case FlavorMariaDB:
+		return "MariaDB"
+	case FlavorAurora:
+		return "Aurora"
+	case FlavorRDS:
+		return "RDS"
+	}
+	return "MySQL"
+}
+
+// Flavor detect the vendor of server by the server version, e.g. 10.3.9-MariaDB-log, 5.7.12-aurora,
+// 5.7.44-RDS.20240408. It is best-effort, the managed servers may report the plain MySQL version.
+func (desc *BinFmtDescEvent) Flavor() ServerFlavor {
+	version := strings.ToLower(desc.MySQLVersion)
+	switch {
+	case strings.Contains(version, "mariadb"):
+		return FlavorMariaDB
+	case strings.Contains(version, "aurora"):
+		return FlavorAurora
+	case strings.Contains(version, "rds"):
+		return FlavorRDS
+	}
+	return FlavorMySQL
+}
+
+// vendorEvents return bool of if the server writes vendor-specific events,
+// which are returned as BinEventUnParsed rather than error, as if SkipUnsupported
+func (f ServerFlavor) vendorEvents() bool {
+	return f != FlavorMySQL
+}
@@ -0,0 +1,82 @@
+package binlog
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// GeometryType is the declared type of spatial column, the same as WKB geometry type
+type GeometryType uint32
+
+// https://dev.mysql.com/doc/refman/8.0/en/gis-data-formats.html#gis-wkb-format
+const (
+	GeometryGeometry           GeometryType = 0
+	GeometryPoint              GeometryType = 1
+	GeometryLineString         GeometryType = 2
+	GeometryPolygon            GeometryType = 3
+	GeometryMultiPoint         GeometryType = 4
+	GeometryMultiLineString    GeometryType = 5
+	GeometryMultiPolygon       GeometryType = 6
+	GeometryGeometryCollection GeometryType = 7
+)
+
+// GeometryType2Str mapping the name of geometry type
+var GeometryType2Str = map[GeometryType]string{
+	GeometryGeometry:           "GEOMETRY",
+	GeometryPoint:              "POINT",
+	GeometryLineString:         "LINESTRING",
+	GeometryPolygon:            "POLYGON",
+	GeometryMultiPoint:         "MULTIPOINT",
+	GeometryMultiLineString:    "MULTILINESTRING",
+	GeometryMultiPolygon:       "MULTIPOLYGON",
+	GeometryGeometryCollection: "GEOMETRYCOLLECTION",
+}
+
+// String return the name of geometry type, e.g. POINT
+func (t GeometryType) String() string {
+	if name, ok := GeometryType2Str[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("GEOMETRY(%d)", uint32(t))
+}
+
+// Geometry is the value of spatial column, MySQL stores it as SRID(4) + WKB
+type Geometry struct {
+	SRID uint32
+	Type GeometryType
+	WKB  []byte
+
+	// coordinates, only if Type is POINT
+	X, Y float64
+}
+
+// DecodeGeometry decode the value of spatial column, the []byte of row image
+func DecodeGeometry(data []byte) (*Geometry, error) {
+	// SRID(4), byte order(1), type(4)
+	if len(data) < 9 {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	g := &Geometry{SRID: binary.LittleEndian.Uint32(data), WKB: data[4:]}
+	var order binary.ByteOrder
+	switch g.WKB[0] {
+	case 0:
+		order = binary.BigEndian
+	case 1:
+		order = binary.LittleEndian
+	default:
+		return nil, fmt.Errorf("invalid WKB byte order %d", g.WKB[0])
+	}
+	g.Type = GeometryType(order.Uint32(g.WKB[1:]))
+
+	if g.Type == GeometryPoint {
+		if len(g.WKB) < 21 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		g.X = math.Float64frombits(order.Uint64(g.WKB[5:]))
+		g.Y = math.Float64frombits(order.Uint64(g.WKB[13:]))
+	}
+	return g, nil
+}
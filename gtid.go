@@ -0,0 +1,281 @@
+package binlog
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// GTIDInterval is the interval of transaction numbers [Start, Stop)
+type GTIDInterval struct {
+	Start int64
+	Stop  int64
+}
+
+// GTIDSet is a set of GTIDs, server uuid => sorted and not overlapped intervals
+// the same as MySQL gtid_executed
+type GTIDSet struct {
+	Sets map[string][]GTIDInterval
+}
+
+// NewGTIDSet return an empty GTIDSet
+func NewGTIDSet() *GTIDSet {
+	return &GTIDSet{Sets: make(map[string][]GTIDInterval)}
+}
+
+// Add will add the GTID uuid:gno into set
+func (s *GTIDSet) Add(uuid string, gno int64) {
+	s.AddInterval(uuid, GTIDInterval{Start: gno, Stop: gno + 1})
+}
+
+// AddInterval will add the interval of uuid into set, overlapped and adjacent intervals are merged
+func (s *GTIDSet) AddInterval(uuid string, interval GTIDInterval) {
+	if interval.Start >= interval.Stop {
+		return
+	}
+	if s.Sets == nil {
+		s.Sets = make(map[string][]GTIDInterval)
+	}
+	uuid = strings.ToLower(uuid)
+	intervals := append(s.Sets[uuid], interval)
+	sort.Slice(intervals, func(i, j int) bool { return intervals[i].Start < intervals[j].Start })
+
+	merged := intervals[:1]
+	for _, in := range intervals[1:] {
+		last := &merged[len(merged)-1]
+		if in.Start <= last.Stop {
+			if in.Stop > last.Stop {
+				last.Stop = in.Stop
+			}
+			continue
+		}
+		merged = append(merged, in)
+	}
+	s.Sets[uuid] = merged
+}
+
+// Contains return bool of if the GTID uuid:gno is in set
+func (s *GTIDSet) Contains(uuid string, gno int64) bool {
+	if s == nil {
+		return false
+	}
+	for _, interval := range s.Sets[strings.ToLower(uuid)] {
+		if interval.Start <= gno && gno < interval.Stop {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseGTIDSet parse the canonical form of GTID set, e.g. 3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5:7,
+// the sets of uuids are separated by comma, and whitespaces are ignored like the output of gtid_executed
+func ParseGTIDSet(str string) (*GTIDSet, error) {
+	set := NewGTIDSet()
+	str = strings.Join(strings.Fields(str), "")
+	if str == "" {
+		return set, nil
+	}
+	for _, part := range strings.Split(str, ",") {
+		fields := strings.Split(part, ":")
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("invalid gtid set %q", part)
+		}
+		uuid := fields[0]
+		if _, err := parseUUID(uuid); err != nil {
+			return nil, err
+		}
+		for _, field := range fields[1:] {
+			interval, err := parseGTIDInterval(field)
+			if err != nil {
+				return nil, fmt.Errorf("invalid gtid set %q: %w", part, err)
+			}
+			set.AddInterval(uuid, interval)
+		}
+	}
+	return set, nil
+}
+
+// parseGTIDInterval parse the interval n or n-m (both inclusive) into [n, m+1)
+func parseGTIDInterval(str string) (GTIDInterval, error) {
+	start, stop := str, str
+	if i := strings.Index(str, "-"); i >= 0 {
+		start, stop = str[:i], str[i+1:]
+	}
+	n, err := strconv.ParseInt(start, 10, 64)
+	if err != nil {
+		return GTIDInterval{}, err
+	}
+	m, err := strconv.ParseInt(stop, 10, 64)
+	if err != nil {
+		return GTIDInterval{}, err
+	}
+	if n < 1 || m < n {
+		return GTIDInterval{}, fmt.Errorf("invalid interval %q", str)
+	}
+	return GTIDInterval{Start: n, Stop: m + 1}, nil
+}
+
+// Clone return a copy of set
+func (s *GTIDSet) Clone() *GTIDSet {
+	clone := NewGTIDSet()
+	if s == nil {
+		return clone
+	}
+	for uuid, intervals := range s.Sets {
+		clone.Sets[uuid] = append([]GTIDInterval(nil), intervals...)
+	}
+	return clone
+}
+
+// IsEmpty return bool of if set has no GTID
+func (s *GTIDSet) IsEmpty() bool {
+	if s == nil {
+		return true
+	}
+	for _, intervals := range s.Sets {
+		if len(intervals) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Union return a new set of the GTIDs in s or other
+func (s *GTIDSet) Union(other *GTIDSet) *GTIDSet {
+	result := s.Clone()
+	if other == nil {
+		return result
+	}
+	for uuid, intervals := range other.Sets {
+		for _, interval := range intervals {
+			result.AddInterval(uuid, interval)
+		}
+	}
+	return result
+}
+
+// ContainsSet return bool of if all GTIDs of other are in s, like GTID_SUBSET(other, s)
+func (s *GTIDSet) ContainsSet(other *GTIDSet) bool {
+	return other.Subtract(s).IsEmpty()
+}
+
+// Equal return bool of if s and other have the same GTIDs
+func (s *GTIDSet) Equal(other *GTIDSet) bool {
+	return s.ContainsSet(other) && other.ContainsSet(s)
+}
+
+// Subtract return a new set of the GTIDs in s but not in other, like GTID_SUBTRACT(s, other)
+func (s *GTIDSet) Subtract(other *GTIDSet) *GTIDSet {
+	result := NewGTIDSet()
+	if s == nil {
+		return result
+	}
+	for uuid, intervals := range s.Sets {
+		var removed []GTIDInterval
+		if other != nil {
+			removed = other.Sets[uuid]
+		}
+		var rest []GTIDInterval
+		for _, interval := range intervals {
+			rest = append(rest, subtractInterval(interval, removed)...)
+		}
+		if len(rest) > 0 {
+			result.Sets[uuid] = rest
+		}
+	}
+	return result
+}
+
+// subtractInterval return the parts of interval not covered by the sorted intervals of removed
+func subtractInterval(interval GTIDInterval, removed []GTIDInterval) []GTIDInterval {
+	var rest []GTIDInterval
+	start := interval.Start
+	for _, r := range removed {
+		if r.Stop <= start {
+			continue
+		}
+		if r.Start >= interval.Stop {
+			break
+		}
+		if r.Start > start {
+			rest = append(rest, GTIDInterval{Start: start, Stop: r.Start})
+		}
+		start = r.Stop
+		if start >= interval.Stop {
+			return rest
+		}
+	}
+	return append(rest, GTIDInterval{Start: start, Stop: interval.Stop})
+}
+
+// String return the canonical form, e.g. 3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5:7
+func (s *GTIDSet) String() string {
+	if s == nil {
+		return ""
+	}
+	uuids := make([]string, 0, len(s.Sets))
+	for uuid := range s.Sets {
+		uuids = append(uuids, uuid)
+	}
+	sort.Strings(uuids)
+
+	parts := make([]string, 0, len(uuids))
+	for _, uuid := range uuids {
+		part := uuid
+		for _, interval := range s.Sets[uuid] {
+			if interval.Stop-interval.Start == 1 {
+				part += fmt.Sprintf(":%d", interval.Start)
+			} else {
+				part += fmt.Sprintf(":%d-%d", interval.Start, interval.Stop-1)
+			}
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ",")
+}
+
+// Encode return the binary format of GTID set, the same as the body of PREVIOUS_GTIDS_EVENT,
+// n_sids(8), then for each sid: sid(16), n_intervals(8), intervals [start(8), end(8))
+func (s *GTIDSet) Encode() ([]byte, error) {
+	if s == nil {
+		return make([]byte, 8), nil
+	}
+	uuids := make([]string, 0, len(s.Sets))
+	for uuid := range s.Sets {
+		uuids = append(uuids, uuid)
+	}
+	sort.Strings(uuids)
+
+	data := make([]byte, 8)
+	binary.LittleEndian.PutUint64(data, uint64(len(uuids)))
+	for _, uuid := range uuids {
+		sid, err := parseUUID(uuid)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, sid...)
+		data = appendUint64(data, uint64(len(s.Sets[uuid])))
+		for _, interval := range s.Sets[uuid] {
+			data = appendUint64(data, uint64(interval.Start))
+			data = appendUint64(data, uint64(interval.Stop))
+		}
+	}
+	return data, nil
+}
+
+// parseUUID parse uuid string into 16 bytes
+func parseUUID(uuid string) ([]byte, error) {
+	sid, err := hex.DecodeString(strings.Replace(uuid, "-", "", -1))
+	if err != nil || len(sid) != 16 {
+		return nil, fmt.Errorf("invalid uuid %q", uuid)
+	}
+	return sid, nil
+}
+
+// formatUUID format 16 bytes into uuid string
+func formatUUID(data []byte) string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", data[0:4], data[4:6], data[6:8], data[8:10], data[10:16])
+}
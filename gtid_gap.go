@@ -0,0 +1,104 @@
+package binlog
+
+// BinlogGTIDs is the GTIDs of a binary log
+type BinlogGTIDs struct {
+	Path string
+	// Previous is the GTID set of PREVIOUS_GTIDS_EVENT, the GTIDs claimed to be in the previous binary logs
+	Previous *GTIDSet
+	// Contained is the GTIDs of the GTID_EVENTs in the binary log
+	Contained *GTIDSet
+}
+
+// Executed return the GTIDs executed at the end of binary log, the union of Previous and Contained
+func (g *BinlogGTIDs) Executed() *GTIDSet {
+	return g.Previous.Union(g.Contained)
+}
+
+// GTIDGap is the mismatch between the PREVIOUS_GTIDS_EVENT of a binary log and what the prior binary log contains
+type GTIDGap struct {
+	PrevPath string
+	Path     string
+	// Missing is the GTIDs claimed by the PREVIOUS_GTIDS_EVENT of Path, but not executed at the end of PrevPath,
+	// e.g. a binary log between them is lost, or gtid_purged is set manually
+	Missing *GTIDSet
+	// Unclaimed is the GTIDs executed at the end of PrevPath, but not claimed by the PREVIOUS_GTIDS_EVENT of Path,
+	// e.g. RESET MASTER is executed, or the binary logs are not from the same server
+	Unclaimed *GTIDSet
+}
+
+// GTIDReport is the GTID analysis of a binary log series
+type GTIDReport struct {
+	Binlogs []*BinlogGTIDs
+	// Purged is the PREVIOUS_GTIDS of the first binary log, the transactions which are not in the series
+	Purged *GTIDSet
+	// Gaps is the mismatches between the adjacent binary logs, empty if the series is continuous
+	Gaps []*GTIDGap
+}
+
+// AnalyzeGTIDs walk the ordered binary logs, e.g. the paths of ReadBinlogIndex, and compare the PREVIOUS_GTIDS_EVENT
+// of each binary log with the GTIDs executed at the end of the prior one, to find the purged or missing transactions.
+// The binary logs without PREVIOUS_GTIDS_EVENT (e.g. MySQL 5.5) have empty Previous. Only the options of reading
+// the binary log apply: FileHeader, SkipHeaderCheck, LenientChecksum, SkipChecksumVerify, MaxEventSize and Logger.
+func AnalyzeGTIDs(paths []string, options ...*BinReaderOption) (*GTIDReport, error) {
+	report := &GTIDReport{Purged: NewGTIDSet()}
+	for _, path := range paths {
+		gtids, err := scanBinlogGTIDs(path, options...)
+		if err != nil {
+			return nil, err
+		}
+		report.Binlogs = append(report.Binlogs, gtids)
+	}
+	if len(report.Binlogs) == 0 {
+		return report, nil
+	}
+
+	report.Purged = report.Binlogs[0].Previous.Clone()
+	for i := 1; i < len(report.Binlogs); i++ {
+		prev, cur := report.Binlogs[i-1], report.Binlogs[i]
+		executed := prev.Executed()
+		gap := &GTIDGap{
+			PrevPath:  prev.Path,
+			Path:      cur.Path,
+			Missing:   cur.Previous.Subtract(executed),
+			Unclaimed: executed.Subtract(cur.Previous),
+		}
+		if !gap.Missing.IsEmpty() || !gap.Unclaimed.IsEmpty() {
+			report.Gaps = append(report.Gaps, gap)
+		}
+	}
+	return report, nil
+}
+
+// scanBinlogGTIDs walk a binary log for its PREVIOUS_GTIDS_EVENT and GTID_EVENTs
+func scanBinlogGTIDs(path string, options ...*BinReaderOption) (*BinlogGTIDs, error) {
+	// only the options reading the binary log itself are carried over, the rows are never used
+	option := &BinReaderOption{SkipRowsEvents: true}
+	if len(options) > 0 && options[0] != nil {
+		option.FileHeader, option.SkipHeaderCheck = options[0].FileHeader, options[0].SkipHeaderCheck
+		option.LenientChecksum, option.SkipChecksumVerify = options[0].LenientChecksum, options[0].SkipChecksumVerify
+		option.MaxEventSize = options[0].MaxEventSize
+		option.Logger = options[0].Logger
+	}
+
+	decoder, err := NewBinFileDecoder(path, option)
+	if err != nil {
+		return nil, err
+	}
+
+	gtids := &BinlogGTIDs{Path: path, Previous: NewGTIDSet(), Contained: NewGTIDSet()}
+	err = decoder.WalkEvent(func(event *BinEvent) (isContinue bool, err error) {
+		switch body := event.Body.(type) {
+		case *BinPreGTIDsEvent:
+			if body.GTIDSet != nil {
+				gtids.Previous = body.GTIDSet.Clone()
+			}
+		case *BinGTIDEvent:
+			gtids.Contained.Add(body.SID, body.GNO)
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return gtids, nil
+}
@@ -0,0 +1,30 @@
+package binlog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ReadBinlogIndex read the binary log index file, e.g. mysql-bin.index, return the ordered binary log paths.
+// the relative paths are resolved relative to the directory of index file.
+func ReadBinlogIndex(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Dir(path)
+	var paths []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if !filepath.IsAbs(line) {
+			line = filepath.Join(dir, line)
+		}
+		paths = append(paths, line)
+	}
+	return paths, nil
+}
@@ -0,0 +1,485 @@
+package binlog
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// JSONB is the binary format of MySQL JSON type
+// https://github.com/mysql/mysql-server/blob/8.0/sql/json_binary.h
+const (
+	jsonbSmallObject = 0x00
+	jsonbLargeObject = 0x01
+	jsonbSmallArray  = 0x02
+	jsonbLargeArray  = 0x03
+	jsonbLiteral     = 0x04
+	jsonbInt16       = 0x05
+	jsonbUint16      = 0x06
+	jsonbInt32       = 0x07
+	jsonbUint32      = 0x08
+	jsonbInt64       = 0x09
+	jsonbUint64      = 0x0a
+	jsonbDouble      = 0x0b
+	jsonbString      = 0x0c
+	jsonbOpaque      = 0x0f
+)
+
+// JSONB literal
+const (
+	jsonbLiteralNull  = 0x00
+	jsonbLiteralTrue  = 0x01
+	jsonbLiteralFalse = 0x02
+)
+
+// decodeJSONB decode MySQL binary JSON into go value.
+// object is map[string]interface{}, array is []interface{}, JSON null is nil,
+// integer is int64 or uint64, double is float64, opaque DECIMAL and temporal are string.
+func decodeJSONB(data []byte) (interface{}, error) {
+	// empty value is JSON null
+	if len(data) == 0 {
+		return nil, nil
+	}
+	return decodeJSONBValue(data[0], data[1:])
+}
+
+func decodeJSONBValue(t byte, data []byte) (interface{}, error) {
+	switch t {
+	case jsonbSmallObject:
+		return decodeJSONBComposite(data, false, true)
+	case jsonbLargeObject:
+		return decodeJSONBComposite(data, true, true)
+	case jsonbSmallArray:
+		return decodeJSONBComposite(data, false, false)
+	case jsonbLargeArray:
+		return decodeJSONBComposite(data, true, false)
+	case jsonbLiteral:
+		if len(data) < 1 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return decodeJSONBLiteral(data[0])
+	case jsonbInt16, jsonbUint16:
+		if len(data) < 2 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		v := binary.LittleEndian.Uint16(data)
+		if t == jsonbInt16 {
+			return int64(int16(v)), nil
+		}
+		return uint64(v), nil
+	case jsonbInt32, jsonbUint32:
+		if len(data) < 4 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		v := binary.LittleEndian.Uint32(data)
+		if t == jsonbInt32 {
+			return int64(int32(v)), nil
+		}
+		return uint64(v), nil
+	case jsonbInt64, jsonbUint64, jsonbDouble:
+		if len(data) < 8 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		v := binary.LittleEndian.Uint64(data)
+		switch t {
+		case jsonbInt64:
+			return int64(v), nil
+		case jsonbUint64:
+			return v, nil
+		}
+		return math.Float64frombits(v), nil
+	case jsonbString:
+		length, n, err := decodeJSONBVariableLength(data)
+		if err != nil {
+			return nil, err
+		}
+		if len(data) < n+length {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return string(data[n : n+length]), nil
+	case jsonbOpaque:
+		return decodeJSONBOpaque(data)
+	}
+	return nil, fmt.Errorf("unknown JSONB type %d", t)
+}
+
+func decodeJSONBLiteral(b byte) (interface{}, error) {
+	switch b {
+	case jsonbLiteralNull:
+		return nil, nil
+	case jsonbLiteralTrue:
+		return true, nil
+	case jsonbLiteralFalse:
+		return false, nil
+	}
+	return nil, fmt.Errorf("unknown JSONB literal %d", b)
+}
+
+// decodeJSONBComposite decode object or array
+// element-count, size, key-entries (object only), value-entries, keys, values
+func decodeJSONBComposite(data []byte, large bool, isObject bool) (interface{}, error) {
+	offsetSize := 2
+	if large {
+		offsetSize = 4
+	}
+	readOffset := func(b []byte) int {
+		if large {
+			return int(binary.LittleEndian.Uint32(b))
+		}
+		return int(binary.LittleEndian.Uint16(b))
+	}
+
+	if len(data) < 2*offsetSize {
+		return nil, io.ErrUnexpectedEOF
+	}
+	count := readOffset(data)
+	size := readOffset(data[offsetSize:])
+	if len(data) < size {
+		return nil, io.ErrUnexpectedEOF
+	}
+	data = data[:size]
+
+	keyEntrySize := offsetSize + 2
+	valueEntrySize := 1 + offsetSize
+	headerSize := 2*offsetSize + count*valueEntrySize
+	if isObject {
+		headerSize += count * keyEntrySize
+	}
+	if headerSize > size {
+		return nil, fmt.Errorf("invalid JSONB header size %d, composite size %d", headerSize, size)
+	}
+
+	var keys []string
+	if isObject {
+		keys = make([]string, count)
+		for i := 0; i < count; i++ {
+			entry := 2*offsetSize + i*keyEntrySize
+			keyOffset := readOffset(data[entry:])
+			keyLength := int(binary.LittleEndian.Uint16(data[entry+offsetSize:]))
+			if keyOffset+keyLength > size {
+				return nil, io.ErrUnexpectedEOF
+			}
+			keys[i] = string(data[keyOffset : keyOffset+keyLength])
+		}
+	}
+
+	values := make([]interface{}, count)
+	for i := 0; i < count; i++ {
+		entry := 2*offsetSize + i*valueEntrySize
+		if isObject {
+			entry += count * keyEntrySize
+		}
+		t := data[entry]
+
+		// small values are inlined into value entry
+		if isJSONBInlined(t, large) {
+			v, err := decodeJSONBValue(t, data[entry+1:entry+valueEntrySize])
+			if err != nil {
+				return nil, err
+			}
+			values[i] = v
+			continue
+		}
+
+		// the values follow the header, which also stops the value from pointing back to the composite
+		valueOffset := readOffset(data[entry+1:])
+		if valueOffset < headerSize || valueOffset >= size {
+			return nil, fmt.Errorf("invalid JSONB value offset %d, composite size %d", valueOffset, size)
+		}
+		v, err := decodeJSONBValue(t, data[valueOffset:])
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+
+	if !isObject {
+		return values, nil
+	}
+	object := make(map[string]interface{}, count)
+	for i, key := range keys {
+		object[key] = values[i]
+	}
+	return object, nil
+}
+
+func isJSONBInlined(t byte, large bool) bool {
+	switch t {
+	case jsonbLiteral, jsonbInt16, jsonbUint16:
+		return true
+	case jsonbInt32, jsonbUint32:
+		return large
+	}
+	return false
+}
+
+// decodeJSONBVariableLength decode the length of string and opaque, 7 bits per byte,
+// the highest bit indicates whether there are more bytes
+func decodeJSONBVariableLength(data []byte) (int, int, error) {
+	var length uint64
+	for i := 0; i < 5 && i < len(data); i++ {
+		length |= uint64(data[i]&0x7f) << (7 * uint(i))
+		if data[i]&0x80 == 0 {
+			return int(length), i + 1, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("invalid JSONB variable length")
+}
+
+// decodeJSONBOpaque decode opaque value: field type, length, data
+func decodeJSONBOpaque(data []byte) (interface{}, error) {
+	if len(data) < 1 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	t := FieldType(data[0])
+	length, n, err := decodeJSONBVariableLength(data[1:])
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 1+n+length {
+		return nil, io.ErrUnexpectedEOF
+	}
+	data = data[1+n : 1+n+length]
+
+	switch t {
+	case MySQLTypeNewDecimal:
+		// precision, scale, binary decimal
+		if len(data) < 2 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		precision, decimals := int(data[0]), int(data[1])
+		if !validDecimal(precision, decimals) {
+			return nil, fmt.Errorf("invalid JSONB DECIMAL(%d,%d)", precision, decimals)
+		}
+		if len(data) < 2+decimalByteSize(precision, decimals) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return decodeDecimal(data[2:2+decimalByteSize(precision, decimals)], precision, decimals), nil
+	case MySQLTypeDate, MySQLTypeDatetime, MySQLTypeTimestamp, MySQLTypeTime:
+		if len(data) < 8 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return formatPackedTime(int64(binary.LittleEndian.Uint64(data)), t), nil
+	}
+	return data, nil
+}
+
+// formatPackedTime format the packed temporal value of JSONB opaque
+func formatPackedTime(packed int64, t FieldType) string {
+	sign := ""
+	if packed < 0 {
+		sign, packed = "-", -packed
+	}
+	intPart := packed >> 24
+	usec := packed % (1 << 24)
+
+	if t == MySQLTypeTime {
+		hms := intPart
+		return fmt.Sprintf("%s%02d:%02d:%02d.%06d", sign, (hms>>12)%(1<<10), (hms>>6)%(1<<6), hms%(1<<6), usec)
+	}
+
+	ymd := intPart >> 17
+	ym := ymd >> 5
+	hms := intPart % (1 << 17)
+	if t == MySQLTypeDate {
+		return fmt.Sprintf("%04d-%02d-%02d", ym/13, ym%13, ymd%(1<<5))
+	}
+	return fmt.Sprintf("%04d-%02d-%02d %02d:%02d:%02d.%06d",
+		ym/13, ym%13, ymd%(1<<5), hms>>12, (hms>>6)%(1<<6), hms%(1<<6), usec)
+}
+
+// encodeJSONBValue encode the value into data in the layout of the original value of type t, the reverse of
+// decodeJSONBValue, e.g. re-encode the masked JSON. The objects and arrays must keep their keys and element
+// counts, the strings must keep their lengths, and the numbers are encoded in their original types.
+func encodeJSONBValue(t byte, data []byte, value interface{}) error {
+	// the bounds of layout are validated by decoding, the values not changed are kept as is
+	original, err := decodeJSONBValue(t, data)
+	if err != nil || reflect.DeepEqual(original, value) {
+		return err
+	}
+
+	switch t {
+	case jsonbSmallObject, jsonbLargeObject, jsonbSmallArray, jsonbLargeArray:
+		isObject := t == jsonbSmallObject || t == jsonbLargeObject
+		return encodeJSONBComposite(data, t == jsonbLargeObject || t == jsonbLargeArray, isObject, value)
+	case jsonbLiteral:
+		switch value {
+		case nil:
+			data[0] = jsonbLiteralNull
+		case true:
+			data[0] = jsonbLiteralTrue
+		case false:
+			data[0] = jsonbLiteralFalse
+		default:
+			return fmt.Errorf("invalid JSONB literal %v", value)
+		}
+		return nil
+	case jsonbInt16, jsonbUint16, jsonbInt32, jsonbUint32, jsonbInt64, jsonbUint64:
+		v, ok := integerValue(value)
+		if !ok {
+			return fmt.Errorf("invalid JSONB integer %v (%T)", value, value)
+		}
+		size := 8
+		switch t {
+		case jsonbInt16, jsonbUint16:
+			size = 2
+		case jsonbInt32, jsonbUint32:
+			size = 4
+		}
+		putFixedLengthInt(data[:size], uint64(v))
+		return nil
+	case jsonbDouble:
+		v, ok := floatValue(value)
+		if !ok {
+			return fmt.Errorf("invalid JSONB double %v (%T)", value, value)
+		}
+		binary.LittleEndian.PutUint64(data, math.Float64bits(v))
+		return nil
+	case jsonbString:
+		length, n, _ := decodeJSONBVariableLength(data)
+		return encodeBytes(data[n:n+length], value)
+	case jsonbOpaque:
+		return encodeJSONBOpaque(data, value)
+	}
+	return fmt.Errorf("unknown JSONB type %d", t)
+}
+
+// encodeJSONBComposite encode the object or array into the layout of data, see decodeJSONBComposite
+func encodeJSONBComposite(data []byte, large bool, isObject bool, value interface{}) error {
+	offsetSize := 2
+	if large {
+		offsetSize = 4
+	}
+	readOffset := func(b []byte) int {
+		if large {
+			return int(binary.LittleEndian.Uint32(b))
+		}
+		return int(binary.LittleEndian.Uint16(b))
+	}
+	count := readOffset(data)
+	keyEntrySize := offsetSize + 2
+	valueEntrySize := 1 + offsetSize
+
+	// the values in the order of value entries
+	var values []interface{}
+	if isObject {
+		object, ok := value.(map[string]interface{})
+		if !ok || len(object) != count {
+			return fmt.Errorf("invalid JSONB object %v, want %d keys", value, count)
+		}
+		for i := 0; i < count; i++ {
+			entry := 2*offsetSize + i*keyEntrySize
+			keyOffset := readOffset(data[entry:])
+			key := string(data[keyOffset : keyOffset+int(binary.LittleEndian.Uint16(data[entry+offsetSize:]))])
+			v, ok := object[key]
+			if !ok {
+				return fmt.Errorf("invalid JSONB object, key %q is missing", key)
+			}
+			values = append(values, v)
+		}
+	} else {
+		array, ok := value.([]interface{})
+		if !ok || len(array) != count {
+			return fmt.Errorf("invalid JSONB array %v, want %d elements", value, count)
+		}
+		values = array
+	}
+
+	for i, v := range values {
+		entry := 2*offsetSize + i*valueEntrySize
+		if isObject {
+			entry += count * keyEntrySize
+		}
+		t := data[entry]
+		var err error
+		if isJSONBInlined(t, large) {
+			err = encodeJSONBValue(t, data[entry+1:entry+valueEntrySize], v)
+		} else {
+			err = encodeJSONBValue(t, data[readOffset(data[entry+1:]):], v)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeJSONBOpaque encode the opaque value into the layout of data, see decodeJSONBOpaque.
+// The changed DECIMAL becomes 0 if it is not a number, e.g. masked by 'X', and the changed temporal value becomes zero.
+func encodeJSONBOpaque(data []byte, value interface{}) error {
+	t := FieldType(data[0])
+	length, n, _ := decodeJSONBVariableLength(data[1:])
+	data = data[1+n : 1+n+length]
+
+	switch t {
+	case MySQLTypeNewDecimal:
+		precision, decimals := int(data[0]), int(data[1])
+		b, err := encodeDecimal(decimalString(value, decimals), precision, decimals)
+		if err != nil {
+			b, _ = encodeDecimal("0", precision, decimals)
+		}
+		copy(data[2:], b)
+		return nil
+	case MySQLTypeDate, MySQLTypeDatetime, MySQLTypeTimestamp, MySQLTypeTime:
+		binary.LittleEndian.PutUint64(data, 0)
+		return nil
+	}
+	return encodeBytes(data, value)
+}
+
+// JSONGet return the value at path of decoded JSON, e.g. $.a.b[0] or $."key with space"[1].
+// It supports member and array index only, no wildcard.
+func JSONGet(value interface{}, path string) (interface{}, bool) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, false
+	}
+	path = path[1:]
+
+	for path != "" {
+		switch path[0] {
+		case '.':
+			var key string
+			if strings.HasPrefix(path, `."`) {
+				end := strings.IndexByte(path[2:], '"')
+				if end < 0 {
+					return nil, false
+				}
+				key, path = path[2:2+end], path[3+end:]
+			} else {
+				end := strings.IndexAny(path[1:], ".[")
+				if end < 0 {
+					end = len(path) - 1
+				}
+				key, path = path[1:1+end], path[1+end:]
+			}
+
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			if value, ok = object[key]; !ok {
+				return nil, false
+			}
+		case '[':
+			end := strings.IndexByte(path, ']')
+			if end < 0 {
+				return nil, false
+			}
+			index, err := strconv.Atoi(path[1:end])
+			path = path[end+1:]
+
+			array, ok := value.([]interface{})
+			if err != nil || !ok || index < 0 || index >= len(array) {
+				return nil, false
+			}
+			value = array[index]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
@@ -0,0 +1,14 @@
+package binlog
+
+// Logger receive the diagnostics of decoding, e.g. skipped events, checksum warnings and recovery skips,
+// it can be bridged to any logging library.
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+}
+
+// noopLogger is the default Logger which does nothing
+type noopLogger struct{}
+
+func (noopLogger) Debugf(format string, args ...interface{}) {}
+func (noopLogger) Warnf(format string, args ...interface{})  {}
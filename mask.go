@@ -0,0 +1,293 @@
+package binlog
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Masker return the masked value of a column, value is never nil. fieldType is the actual type of column,
+// e.g. ENUM rather than STRING. The masked value is re-encoded into the binary log by MaskEventBytes
+// with the layout of original value, e.g. the string must keep its length, see DefaultMasker.
+type Masker func(value interface{}, fieldType FieldType) interface{}
+
+// Anonymizer will mask the values of sensitive columns of ROWS_EVENT, e.g. share the binary log
+// or the decoded changes with others without leaking personal data. Mask and Transform mask the
+// decoded rows, MaskEventBytes and MaskBinlog re-encode the masked values into the binary log,
+// the sizes of events are kept and the checksums are recomputed.
+type Anonymizer struct {
+	// sensitive columns, 'db.table.column'
+	columns map[string]bool
+
+	// maskers by column type, DefaultMasker is used if not set
+	Maskers map[FieldType]Masker
+
+	// column names of tables without COLUMN_NAME metadata for MaskEventBytes and MaskBinlog,
+	// 'db.table' => ordered column names, see BinReaderOption.ColumnNames
+	ColumnNames map[string][]string
+}
+
+// NewAnonymizer return an Anonymizer with sensitive columns, 'db.table.column'
+func NewAnonymizer(columns []string) *Anonymizer {
+	a := &Anonymizer{
+		columns: make(map[string]bool, len(columns)),
+		Maskers: make(map[FieldType]Masker),
+	}
+	for _, column := range columns {
+		a.columns[column] = true
+	}
+	return a
+}
+
+// Mask will replace the values of sensitive columns in rows, NULL is kept as NULL
+func (a *Anonymizer) Mask(event *BinRowsEvent) {
+	for _, row := range event.Rows {
+		a.maskImage(event.TableMap(), row)
+	}
+}
+
+// Transform is a RowTransform which masks the sensitive columns of row change, see WalkRowChanges
+func (a *Anonymizer) Transform(change RowChange) (RowChange, bool) {
+	a.maskImage(change.TableMap, change.Before)
+	a.maskImage(change.TableMap, change.After)
+	return change, true
+}
+
+// sensitive return bool of if the column i of table is sensitive
+func (a *Anonymizer) sensitive(table *BinTableMapEvent, i int) bool {
+	return a.columns[table.Schema+"."+table.Table+"."+table.ColumnName(i)]
+}
+
+// mask return the masked value of column i of table
+func (a *Anonymizer) mask(table *BinTableMapEvent, i int, value interface{}) interface{} {
+	fieldType := table.ColumnTypeDef[i]
+	if i < len(table.ColumnMetaDef) {
+		fieldType = table.ColumnMetaDef[i].realType(fieldType)
+	}
+	masker, ok := a.Maskers[fieldType]
+	if !ok {
+		masker = DefaultMasker
+	}
+	return masker(value, fieldType)
+}
+
+// maskImage will replace the values of sensitive columns in a row image
+func (a *Anonymizer) maskImage(table *BinTableMapEvent, row map[string]interface{}) {
+	if table == nil || row == nil {
+		return
+	}
+
+	for i := 0; i < int(table.ColumnCount); i++ {
+		if !a.sensitive(table, i) {
+			continue
+		}
+		name := table.ColumnName(i)
+		v, ok := row[name]
+		if !ok || v == nil {
+			continue
+		}
+		row[name] = a.mask(table, i, v)
+	}
+}
+
+// MaskEventBytes return a copy of event bytes (header, body and checksum) in which the values of
+// sensitive columns of ROWS_EVENT are masked and re-encoded, and the checksum is recomputed.
+// The other events are copied as is. desc and tableInfo are used like DecodeEventBytes,
+// the returned event is decoded from data with the sensitive columns masked by Mask.
+func (a *Anonymizer) MaskEventBytes(data []byte, desc *BinFmtDescEvent, tableInfo map[uint64]*BinTableMapEvent) ([]byte, *BinEvent, error) {
+	if tableInfo == nil {
+		tableInfo = make(map[uint64]*BinTableMapEvent)
+	}
+	info := &BinaryLogInfo{description: desc, tableInfo: tableInfo, columnNames: a.ColumnNames}
+	return a.maskEventBytes(info, data)
+}
+
+// MaskBinlog copy the binary log from r to w, in which the values of sensitive columns of
+// ROWS_EVENTs are masked, see MaskEventBytes
+func (a *Anonymizer) MaskBinlog(r io.Reader, w io.Writer) error {
+	magic := make([]byte, len(binFileHeader))
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return fmt.Errorf("read binary log header: %w", err)
+	}
+	var option *BinReaderOption
+	if err := option.checkFileHeader(magic); err != nil {
+		return err
+	}
+	if _, err := w.Write(magic); err != nil {
+		return err
+	}
+
+	info := &BinaryLogInfo{tableInfo: make(map[uint64]*BinTableMapEvent), columnNames: a.ColumnNames}
+	header := make([]byte, defaultEventHeaderSize)
+	for {
+		if _, err := io.ReadFull(r, header); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("read event header: %w", err)
+		}
+		size := int64(binary.LittleEndian.Uint32(header[eventSizeOffset:]))
+		if size < defaultEventHeaderSize || size > option.maxEventSize() {
+			return fmt.Errorf("invalid event size %d", size)
+		}
+		data := make([]byte, size)
+		copy(data, header)
+		if _, err := io.ReadFull(r, data[len(header):]); err != nil {
+			return fmt.Errorf("read event of size %d: %w", size, err)
+		}
+
+		masked, _, err := a.maskEventBytes(info, data)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(masked); err != nil {
+			return err
+		}
+	}
+}
+
+// maskEventBytes mask the event bytes with the format description and table maps of info
+func (a *Anonymizer) maskEventBytes(info *BinaryLogInfo, data []byte) ([]byte, *BinEvent, error) {
+	desc := info.description
+	event, err := info.decodeEventBytes(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	masked := append([]byte(nil), data...)
+	rows, ok := event.Body.(*BinRowsEvent)
+	if !ok {
+		return masked, event, nil
+	}
+
+	checksumLength := len(event.ChecksumVal)
+	images := masked[desc.EventHeaderLength : len(masked)-checksumLength][rows.imagePos:]
+	for pos := 0; pos < len(images); {
+		n, err := a.maskRawImage(rows, images[pos:], rows.ColumnsBitmap1)
+		if err != nil {
+			return nil, nil, err
+		}
+		pos += n
+		if rows.Action() == RowsActionUpdate {
+			if n, err = a.maskRawImage(rows, images[pos:], rows.ColumnsBitmap2); err != nil {
+				return nil, nil, err
+			}
+			pos += n
+		}
+	}
+
+	// the checksum is recomputed over the masked header and body
+	if checksumLength > 0 {
+		if event.ChecksumType != BinlogChecksumAlgCRC32 {
+			return nil, nil, fmt.Errorf("recompute the checksum of algorithm %s", ChecksumAlgorithmName(event.ChecksumType))
+		}
+		trailer := masked[len(masked)-checksumLength:]
+		binary.LittleEndian.PutUint32(trailer, crc32.ChecksumIEEE(masked[:len(masked)-checksumLength]))
+		event.ChecksumVal = trailer
+	}
+	a.Mask(rows)
+	return masked, event, nil
+}
+
+// maskRawImage will re-encode the masked values of sensitive columns into the row image at data,
+// return the size of row image, see decodeImage
+func (a *Anonymizer) maskRawImage(e *BinRowsEvent, data []byte, present Bitfield) (int, error) {
+	table := e.tableMap
+	columnCount := int(e.ColumnCount)
+	presentCount := 0
+	for i := 0; i < columnCount; i++ {
+		if present.isSet(uint(i)) {
+			presentCount++
+		}
+	}
+
+	pos := bitmapByteSize(presentCount)
+	nullBitmap := Bitfield(data[:pos])
+	nullIndex := uint(0)
+	for i := 0; i < columnCount; i++ {
+		if !present.isSet(uint(i)) {
+			continue
+		}
+		isNull := nullBitmap.isSet(nullIndex)
+		nullIndex++
+		if isNull {
+			continue
+		}
+
+		t, meta := table.ColumnTypeDef[i], &table.ColumnMetaDef[i]
+		v, n, err := decodeValue(data[pos:], t, meta)
+		if err != nil {
+			return 0, err
+		}
+		if a.sensitive(table, i) {
+			if err := encodeValue(data[pos:pos+n], a.mask(table, i, v), t, meta); err != nil {
+				return 0, fmt.Errorf("mask column %s of %s.%s: %w", table.ColumnName(i), table.Schema, table.Table, err)
+			}
+		}
+		pos += n
+	}
+	return pos, nil
+}
+
+// DefaultMasker will mask the value by column type, keeping the size of value in binary log.
+// Strings and bytes become the same length of 'X', numbers become 0, temporal values become zero,
+// e.g. '0000-00-00', ENUM and SET become the empty value, and the strings and numbers in JSON
+// are masked in the same way.
+func DefaultMasker(value interface{}, fieldType FieldType) interface{} {
+	switch fieldType {
+	case MySQLTypeVarchar, MySQLTypeVarString, MySQLTypeString, MySQLTypeBlob, MySQLTypeTinyBlob,
+		MySQLTypeMediumBlob, MySQLTypeLongBlob, MySQLTypeGeometry:
+		return maskString(value)
+	case MySQLTypeJSON:
+		return maskJSON(value)
+	case MySQLTypeNewDecimal:
+		return "0"
+	case MySQLTypeDate, MySQLTypeNewDate:
+		return "0000-00-00"
+	case MySQLTypeDatetime, MySQLTypeDatetime2:
+		return "0000-00-00 00:00:00"
+	case MySQLTypeTimestamp, MySQLTypeTimestamp2:
+		return time.Unix(0, 0).UTC()
+	}
+	// integers, floats, BIT, YEAR, TIME, and the indexes of ENUM and SET
+	if _, ok := value.(string); ok {
+		return ""
+	}
+	return reflect.Zero(reflect.TypeOf(value)).Interface()
+}
+
+// maskString replace strings and bytes with the same length of 'X'
+func maskString(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return strings.Repeat("X", len(v))
+	case []byte:
+		return []byte(strings.Repeat("X", len(v)))
+	}
+	return value
+}
+
+// maskJSON mask the strings and numbers of decoded JSON, the keys are kept
+func maskJSON(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		object := make(map[string]interface{}, len(v))
+		for key, element := range v {
+			object[key] = maskJSON(element)
+		}
+		return object
+	case []interface{}:
+		array := make([]interface{}, len(v))
+		for i, element := range v {
+			array[i] = maskJSON(element)
+		}
+		return array
+	case string, []byte:
+		return maskString(v)
+	case int64, uint64, float64:
+		return reflect.Zero(reflect.TypeOf(value)).Interface()
+	}
+	return value
+}
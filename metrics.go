@@ -0,0 +1,27 @@
+package binlog
+
+import "time"
+
+// Metrics receive the statistics of decoding, it can be bridged to Prometheus or any other collector.
+type Metrics interface {
+	// EventDecoded is called when an event is decoded, size is the bytes of the event
+	EventDecoded(eventType EventType, size int64)
+	// EventSkipped is called when the body of an event is discarded without decoding, e.g. before StartPos,
+	// ignored or filtered. The bytes read are the sizes of EventDecoded and EventSkipped
+	EventSkipped(eventType EventType, size int64)
+	// ChecksumFailed is called when the checksum validation of an event failed
+	ChecksumFailed(eventType EventType)
+	// UnsupportedEvent is called when the event type is not supported to decode
+	UnsupportedEvent(eventType EventType)
+	// Progress is called with the end position of the decoded or skipped event and the lag (now - event timestamp)
+	Progress(pos int64, lag time.Duration)
+}
+
+// noopMetrics is the default Metrics which does nothing
+type noopMetrics struct{}
+
+func (noopMetrics) EventDecoded(eventType EventType, size int64) {}
+func (noopMetrics) EventSkipped(eventType EventType, size int64) {}
+func (noopMetrics) ChecksumFailed(eventType EventType)           {}
+func (noopMetrics) UnsupportedEvent(eventType EventType)         {}
+func (noopMetrics) Progress(pos int64, lag time.Duration)        {}
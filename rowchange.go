@@ -0,0 +1,106 @@
+package binlog
+
+// RowChange is a row changed by ROWS_EVENT, Before is nil for INSERT and After is nil for DELETE.
+// The images are keyed by column names like BinRowsEvent.Rows, the absent columns are omitted.
+type RowChange struct {
+	Schema string
+	Table  string
+	Action RowsAction
+	Before map[string]interface{}
+	After  map[string]interface{}
+
+	// the table map to resolve the columns, and the ROWS_EVENT of the row
+	TableMap *BinTableMapEvent
+	Event    *BinEvent
+}
+
+// RowTransform modify the row change, or drop it by returning false, e.g. ProjectColumns, FilterTables
+type RowTransform func(change RowChange) (RowChange, bool)
+
+// RowChanges return the row changes of ROWS_EVENT in order, the before and after images of
+// UPDATE_ROWS_EVENT are paired into one change. nil if the event is not ROWS_EVENT.
+func (event *BinEvent) RowChanges() []RowChange {
+	rows, ok := event.Body.(*BinRowsEvent)
+	if !ok {
+		return nil
+	}
+	base := RowChange{Action: rows.Action(), TableMap: rows.TableMap(), Event: event}
+	if base.TableMap != nil {
+		base.Schema, base.Table = base.TableMap.Schema, base.TableMap.Table
+	}
+
+	changes := make([]RowChange, 0, len(rows.Rows))
+	for i := 0; i < len(rows.Rows); i++ {
+		change := base
+		switch base.Action {
+		case RowsActionInsert:
+			change.After = rows.Rows[i]
+		case RowsActionDelete:
+			change.Before = rows.Rows[i]
+		case RowsActionUpdate:
+			change.Before = rows.Rows[i]
+			if i+1 < len(rows.Rows) {
+				change.After = rows.Rows[i+1]
+				i++
+			}
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// WalkRowChanges will walk the row changes of ROWS_EVENTs, see WalkEvent. Every change goes through
+// the transforms in order before f, and is dropped once a transform returns false, e.g. the column
+// projection, value masking and row filtering of ETL. The other events are not passed to f.
+// The images are the maps of BinRowsEvent.Rows, a transform could modify them in place.
+func (decoder *BinFileDecoder) WalkRowChanges(f func(change RowChange) (isContinue bool, err error), transforms ...RowTransform) error {
+	return decoder.WalkEvent(func(event *BinEvent) (isContinue bool, err error) {
+	changes:
+		for _, change := range event.RowChanges() {
+			for _, transform := range transforms {
+				var ok bool
+				if change, ok = transform(change); !ok {
+					continue changes
+				}
+			}
+			if isContinue, err = f(change); !isContinue || err != nil {
+				return isContinue, err
+			}
+		}
+		return true, nil
+	})
+}
+
+// ProjectColumns return a RowTransform keeping only the columns of images, the other columns are removed
+func ProjectColumns(columns ...string) RowTransform {
+	return func(change RowChange) (RowChange, bool) {
+		change.Before = projectImage(change.Before, columns)
+		change.After = projectImage(change.After, columns)
+		return change, true
+	}
+}
+
+// projectImage return a new image of the columns, nil if image is nil
+func projectImage(image map[string]interface{}, columns []string) map[string]interface{} {
+	if image == nil {
+		return nil
+	}
+	projected := make(map[string]interface{}, len(columns))
+	for _, column := range columns {
+		if v, ok := image[column]; ok {
+			projected[column] = v
+		}
+	}
+	return projected
+}
+
+// FilterTables return a RowTransform keeping only the changes of tables, 'db.table'
+func FilterTables(tables ...string) RowTransform {
+	keep := make(map[string]bool, len(tables))
+	for _, table := range tables {
+		keep[table] = true
+	}
+	return func(change RowChange) (RowChange, bool) {
+		return change, keep[change.Schema+"."+change.Table]
+	}
+}
@@ -0,0 +1,328 @@
+package binlog
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// encodeValue encode the value of column into dst, which holds the encoded value of column in row image,
+// e.g. re-encode the masked value. The layout of row image is kept, so the value must be encoded
+// in the size of dst, e.g. the string of the same length.
+func encodeValue(dst []byte, value interface{}, t FieldType, meta *ColumnType) error {
+	var err error
+	switch realType := meta.realType(t); realType {
+	case MySQLTypeTiny, MySQLTypeShort, MySQLTypeInt24, MySQLTypeLong, MySQLTypeLonglong:
+		v, ok := integerValue(value)
+		if !ok {
+			return fmt.Errorf("invalid value %v (%T) of FieldType %s", value, value, fmt.Sprint(t))
+		}
+		putFixedLengthInt(dst, uint64(v))
+	case MySQLTypeYear:
+		v, ok := integerValue(value)
+		if !ok || v != 0 && (v < 1901 || v > 2155) {
+			return fmt.Errorf("invalid YEAR %v", value)
+		}
+		if v != 0 {
+			v -= 1900
+		}
+		dst[0] = byte(v)
+	case MySQLTypeFloat, MySQLTypeDouble:
+		v, ok := floatValue(value)
+		if !ok {
+			return fmt.Errorf("invalid value %v (%T) of FieldType %s", value, value, fmt.Sprint(t))
+		}
+		if realType == MySQLTypeFloat {
+			putFixedLengthInt(dst, uint64(math.Float32bits(float32(v))))
+		} else {
+			putFixedLengthInt(dst, math.Float64bits(v))
+		}
+	case MySQLTypeNewDecimal:
+		var b []byte
+		if b, err = encodeDecimal(decimalString(value, meta.decimals), meta.precision, meta.decimals); err == nil {
+			copy(dst, b)
+		}
+	case MySQLTypeBit:
+		v, ok := integerValue(value)
+		if !ok {
+			return fmt.Errorf("invalid BIT %v", value)
+		}
+		putBFixedLengthInt(dst, uint64(v))
+	case MySQLTypeEnum, MySQLTypeSet:
+		var v uint64
+		if v, err = enumValue(value, realType, meta.enumValues); err == nil {
+			putFixedLengthInt(dst, v)
+		}
+	case MySQLTypeDate, MySQLTypeNewDate:
+		var year, month, day int
+		if _, err = fmt.Sscanf(fmt.Sprint(value), "%d-%d-%d", &year, &month, &day); err == nil {
+			putFixedLengthInt(dst, uint64(year<<9|month<<5|day))
+		}
+	case MySQLTypeDatetime, MySQLTypeDatetime2:
+		var date, clock, usec int64
+		if date, clock, usec, err = parseDatetime(fmt.Sprint(value)); err != nil {
+			break
+		}
+		if realType == MySQLTypeDatetime {
+			putFixedLengthInt(dst, uint64(date*1000000+clock))
+			break
+		}
+		year, month, day := date/10000, date%10000/100, date%100
+		hour, minute, second := clock/10000, clock%10000/100, clock%100
+		intPart := ((year*13+month)<<5|day)<<17 | hour<<12 | minute<<6 | second
+		putBFixedLengthInt(dst[:5], uint64(intPart+datetimeIntOffset))
+		encodeFrac(dst[5:], usec)
+	case MySQLTypeTimestamp, MySQLTypeTimestamp2:
+		v, ok := value.(time.Time)
+		if !ok {
+			return fmt.Errorf("invalid TIMESTAMP %v", value)
+		}
+		if realType == MySQLTypeTimestamp {
+			putFixedLengthInt(dst, uint64(v.Unix()))
+			break
+		}
+		putBFixedLengthInt(dst[:4], uint64(v.Unix()))
+		encodeFrac(dst[4:], int64(v.Nanosecond())/int64(time.Microsecond))
+	case MySQLTypeTime, MySQLTypeTime2:
+		v, ok := value.(time.Duration)
+		if !ok || realType == MySQLTypeTime && v < 0 {
+			return fmt.Errorf("invalid TIME %v", value)
+		}
+		if realType == MySQLTypeTime {
+			hms := int64(v / time.Second)
+			putFixedLengthInt(dst, uint64(hms/3600*10000+hms/60%60*100+hms%60))
+			break
+		}
+		encodeTime2(dst, v, meta.fsp)
+	case MySQLTypeVarchar, MySQLTypeVarString, MySQLTypeString:
+		lengthSize := 1
+		if meta.maxLength >= 256 {
+			lengthSize = 2
+		}
+		err = encodeBytes(dst[lengthSize:], value)
+	case MySQLTypeBlob, MySQLTypeTinyBlob, MySQLTypeMediumBlob, MySQLTypeLongBlob, MySQLTypeGeometry:
+		err = encodeBytes(dst[meta.lengthSize:], value)
+	case MySQLTypeJSON:
+		if data := dst[meta.lengthSize:]; len(data) > 0 {
+			err = encodeJSONBValue(data[0], data[1:], value)
+		} else if value != nil {
+			err = fmt.Errorf("invalid JSON %v, the original is JSON null", value)
+		}
+	case MySQLTypeTypedArray:
+		err = encodeBytes(dst[4:], value)
+	default:
+		return fmt.Errorf("unsupported FieldType %s", fmt.Sprint(t))
+	}
+	return err
+}
+
+// encodeBytes copy the string or bytes value into dst, which must be the same length
+func encodeBytes(dst []byte, value interface{}) error {
+	var b []byte
+	switch v := value.(type) {
+	case string:
+		b = []byte(v)
+	case []byte:
+		b = v
+	default:
+		return fmt.Errorf("invalid value %v (%T), want string or []byte", value, value)
+	}
+	if len(b) != len(dst) {
+		return fmt.Errorf("invalid value of %d bytes, the original is %d bytes", len(b), len(dst))
+	}
+	copy(dst, b)
+	return nil
+}
+
+// integerValue return the value of integer types
+func integerValue(value interface{}) (int64, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(v.Uint()), true
+	}
+	return 0, false
+}
+
+// floatValue return the value of float and integer types
+func floatValue(value interface{}) (float64, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	i, ok := integerValue(value)
+	return float64(i), ok
+}
+
+// decimalString return the string form of DECIMAL value, which is string, integer or float
+func decimalString(value interface{}, decimals int) string {
+	if f, ok := value.(float64); ok {
+		return strconv.FormatFloat(f, 'f', decimals, 64)
+	}
+	if f, ok := value.(float32); ok {
+		return strconv.FormatFloat(float64(f), 'f', decimals, 32)
+	}
+	return fmt.Sprint(value)
+}
+
+// enumValue return the index of ENUM or the bits of SET, the value is the label(s) or the number
+func enumValue(value interface{}, t FieldType, labels []string) (uint64, error) {
+	if v, ok := integerValue(value); ok {
+		return uint64(v), nil
+	}
+	str, ok := value.(string)
+	if !ok || labels == nil && str != "" {
+		return 0, fmt.Errorf("invalid value %v of FieldType %s", value, fmt.Sprint(t))
+	}
+	if str == "" {
+		return 0, nil
+	}
+
+	var v uint64
+	names := []string{str}
+	if t == MySQLTypeSet {
+		names = strings.Split(str, ",")
+	}
+	for _, name := range names {
+		index := -1
+		for i, label := range labels {
+			if label == name {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			return 0, fmt.Errorf("unknown label %q of FieldType %s", name, fmt.Sprint(t))
+		}
+		if t == MySQLTypeEnum {
+			return uint64(index + 1), nil
+		}
+		v |= 1 << uint(index)
+	}
+	return v, nil
+}
+
+// parseDatetime parse 'YYYY-MM-DD hh:mm:ss[.ffffff]', return YYYYMMDD, hhmmss and the microseconds
+func parseDatetime(str string) (int64, int64, int64, error) {
+	var year, month, day, hour, minute, second, usec int64
+	str, frac, _ := strings.Cut(str, ".")
+	if _, err := fmt.Sscanf(str, "%d-%d-%d %d:%d:%d", &year, &month, &day, &hour, &minute, &second); err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid DATETIME %s: %w", str, err)
+	}
+	if frac != "" {
+		var err error
+		if len(frac) > 6 {
+			return 0, 0, 0, fmt.Errorf("invalid DATETIME fraction %s", frac)
+		}
+		if usec, err = strconv.ParseInt(frac+strings.Repeat("0", 6-len(frac)), 10, 64); err != nil {
+			return 0, 0, 0, fmt.Errorf("invalid DATETIME fraction %s: %w", frac, err)
+		}
+	}
+	return year*10000 + month*100 + day, hour*10000 + minute*100 + second, usec, nil
+}
+
+// encodeFrac encode the microseconds of fractional seconds part in the size of dst, the reverse of decodeFrac
+func encodeFrac(dst []byte, usec int64) {
+	switch len(dst) {
+	case 1:
+		dst[0] = byte(usec / 10000)
+	case 2:
+		putBFixedLengthInt(dst, uint64(usec/100))
+	case 3:
+		putBFixedLengthInt(dst, uint64(usec))
+	}
+}
+
+// encodeTime2 encode TIME2, the reverse of decodeTime2
+// https://github.com/mysql/mysql-server/blob/8.0/mysys/my_time.cc (my_time_packed_to_binary)
+func encodeTime2(dst []byte, d time.Duration, fsp uint8) {
+	sign := int64(1)
+	if d < 0 {
+		sign, d = -1, -d
+	}
+	hms := int64(d / time.Second)
+	usec := int64(d % time.Second / time.Microsecond)
+	packed := sign * ((hms/3600<<12|hms/60%60<<6|hms%60)<<24 + usec)
+
+	intPart, frac := packed>>24, packed%(1<<24)
+	switch fsp {
+	case 1, 2:
+		putBFixedLengthInt(dst[:3], uint64(intPart+timeIntOffset))
+		dst[3] = byte(int8(frac / 10000))
+	case 3, 4:
+		putBFixedLengthInt(dst[:3], uint64(intPart+timeIntOffset))
+		putBFixedLengthInt(dst[3:5], uint64(uint16(int16(frac/100))))
+	case 5, 6:
+		putBFixedLengthInt(dst[:6], uint64(packed+timeOffset))
+	default:
+		putBFixedLengthInt(dst[:3], uint64(intPart+timeIntOffset))
+	}
+}
+
+// encodeDecimal encode the string form of DECIMAL into binary, the reverse of decodeDecimal
+func encodeDecimal(str string, precision, decimals int) ([]byte, error) {
+	if !validDecimal(precision, decimals) {
+		return nil, fmt.Errorf("invalid DECIMAL(%d,%d)", precision, decimals)
+	}
+	negative := strings.HasPrefix(str, "-")
+	intPart, fracPart, _ := strings.Cut(strings.TrimPrefix(str, "-"), ".")
+	intPart = strings.TrimLeft(intPart, "0")
+	integral := precision - decimals
+	if len(intPart) > integral || len(fracPart) > decimals || strings.Trim(intPart+fracPart, "0123456789") != "" {
+		return nil, fmt.Errorf("invalid value %s of DECIMAL(%d,%d)", str, precision, decimals)
+	}
+	intPart = strings.Repeat("0", integral-len(intPart)) + intPart
+	fracPart += strings.Repeat("0", decimals-len(fracPart))
+
+	// the leftover digits of integral part are the first, those of fractional part are the last
+	leading, trailing := integral%digitsPerInteger, decimals%digitsPerInteger
+	buf := make([]byte, 0, decimalByteSize(precision, decimals))
+	buf = appendDecimalDigits(buf, intPart[:leading], compressedBytes[leading])
+	for i := leading; i < integral; i += digitsPerInteger {
+		buf = appendDecimalDigits(buf, intPart[i:i+digitsPerInteger], 4)
+	}
+	for i := 0; i+digitsPerInteger <= decimals; i += digitsPerInteger {
+		buf = appendDecimalDigits(buf, fracPart[i:i+digitsPerInteger], 4)
+	}
+	buf = appendDecimalDigits(buf, fracPart[decimals-trailing:], compressedBytes[trailing])
+
+	// negative numbers are stored inverted, the highest bit is the sign
+	if negative {
+		for i := range buf {
+			buf[i] ^= 0xff
+		}
+	}
+	buf[0] ^= 0x80
+	return buf, nil
+}
+
+// appendDecimalDigits append the digits in size bytes of big-endian
+func appendDecimalDigits(buf []byte, digits string, size int) []byte {
+	if size == 0 {
+		return buf
+	}
+	v, _ := strconv.ParseUint(digits, 10, 32)
+	b := make([]byte, size)
+	putBFixedLengthInt(b, v)
+	return append(buf, b...)
+}
+
+// putFixedLengthInt put the little-endian integer in the size of dst, the reverse of FixedLengthInt
+func putFixedLengthInt(dst []byte, v uint64) {
+	for i := range dst {
+		dst[i] = byte(v >> (uint(i) * 8))
+	}
+}
+
+// putBFixedLengthInt put the big-endian integer in the size of dst, the reverse of BFixedLengthInt
+func putBFixedLengthInt(dst []byte, v uint64) {
+	for i := range dst {
+		dst[i] = byte(v >> (uint(len(dst)-i-1) * 8))
+	}
+}
@@ -22,7 +22,21 @@ type BinTableMapEvent struct {
 	ColumnCount   uint64       // 对应表中的字段数量
 	ColumnTypeDef []FieldType  // 字段类型
 	ColumnMetaDef []ColumnType // 每个字段的元数据信息，比如 varchar 字段需要记录最长长度
-	NullBitmap    Bitfield     // 一个 bit 表示一个字段是否可以为 NULL，顺序是：第一个字节的最低位开始向最高位增长，之后第二个字节的最低位开始向最高位增长，以此类推
+	// NullBitmap is the schema of table, which columns are declared nullable. It is not whether the value
+	// of a row is NULL, which is the NULL-bitmap of each row image, see BinReaderOption.StrictNullability.
+	NullBitmap Bitfield // 一个 bit 表示一个字段是否可以为 NULL，顺序是：第一个字节的最低位开始向最高位增长，之后第二个字节的最低位开始向最高位增长，以此类推
+
+	// optional metadata, binlog_row_metadata (mysql 8.0.1)
+	PrimaryKey       []int // 主键字段的序号
+	PrimaryKeyPrefix []int // 主键字段的前缀长度，0 表示整个字段
+}
+
+// Nullable return bool of if the column is declared nullable, true if unknown
+func (e *BinTableMapEvent) Nullable(i int) bool {
+	if i < 0 || i/8 >= len(e.NullBitmap) {
+		return true
+	}
+	return e.NullBitmap.isSet(uint(i))
 }
 
 type Bitfield []byte
@@ -45,11 +59,59 @@ type ColumnType struct {
 	bytes      int
 	bits       byte
 	fsp        uint8
+
+	// optional metadata, binlog_row_metadata (mysql 8.0.1)
+	geometryType GeometryType
+	enumValues   []string // labels of ENUM or SET
+	invisible    bool     // mysql 8.0.23
+	collation    uint16   // collation id of string, ENUM or SET column
+
+	// element type of MYSQL_TYPE_TYPED_ARRAY
+	elementType FieldType
+}
+
+// ElementType return the element type of typed array (multi-valued index) column, e.g. MySQLTypeLonglong
+func (c *ColumnType) ElementType() FieldType {
+	return c.elementType
+}
+
+// Invisible return bool of if the column is invisible, false if the visibility is unknown
+func (c *ColumnType) Invisible() bool {
+	return c.invisible
+}
+
+// Collation return the collation id of string (including BLOB, which is binary), ENUM or SET column,
+// 0 if unknown
+func (c *ColumnType) Collation() uint16 {
+	return c.collation
+}
+
+// Charset return the charset name of column collation, e.g. utf8mb4, latin1, empty if unknown
+func (c *ColumnType) Charset() string {
+	return CharsetName(c.collation)
+}
+
+// EnumValues return the labels of ENUM or SET column, nil if unknown
+func (c *ColumnType) EnumValues() []string {
+	return c.enumValues
+}
+
+// GeometryType return the declared type of spatial column, GEOMETRY if unknown
+func (c *ColumnType) GeometryType() GeometryType {
+	return c.geometryType
+}
+
+// ColumnName return the name of column i, the name is positional '@N' when the column name is unknown
+func (e *BinTableMapEvent) ColumnName(i int) string {
+	if i < len(e.ColumnMetaDef) && e.ColumnMetaDef[i].name != "" {
+		return e.ColumnMetaDef[i].name
+	}
+	return fmt.Sprintf("@%d", i+1)
 }
 
 // Init BinTableMapEvent tableIDLen
 func (e *BinTableMapEvent) Init(h *BinFmtDescEvent) *BinTableMapEvent {
-	if int(h.EventTypeHeader[TableMapEvent-1]) == 6 {
+	if h.postHeaderLength(TableMapEvent) == 6 {
 		e.tableIDLen = 4
 	} else {
 		e.tableIDLen = 6
@@ -63,27 +125,40 @@ func decodeTableMapEvent(data []byte, h *BinFmtDescEvent) (*BinTableMapEvent, er
 	// set table id
 	event = event.Init(h)
 	pos := event.tableIDLen
+	// table_id, flags(2), schema_length(1)
+	if len(data) < pos+3 {
+		return nil, fmt.Errorf("invalid table map event size %d", len(data))
+	}
 	event.TableID = FixedLengthInt(data[:pos])
 
 	// set flags
 	event.Flags = binary.LittleEndian.Uint16(data[pos:])
 	pos += 2
 
-	// set schema && skip 0x00
+	// set schema && skip 0x00, then table_length(1)
 	schemaLength := int(data[pos])
 	pos++
+	if len(data) < pos+schemaLength+2 {
+		return nil, fmt.Errorf("invalid table map event size %d, schema length %d", len(data), schemaLength)
+	}
 	event.Schema = string(data[pos : pos+schemaLength])
 	pos += schemaLength + 1
 
 	// set table && skip 0x00
 	tableLength := int(data[pos])
 	pos++
+	if len(data) < pos+tableLength+2 {
+		return nil, fmt.Errorf("invalid table map event size %d, table length %d", len(data), tableLength)
+	}
 	event.Table = string(data[pos : pos+tableLength])
 	pos += tableLength + 1
 
 	// set column count
 	var n int
-	event.ColumnCount, _, n = LengthEncodedInt(data[pos:])
+	var err error
+	if event.ColumnCount, n, err = readLengthEncodedInt(data, pos); err != nil || uint64(len(data)-pos-n) < event.ColumnCount {
+		return nil, fmt.Errorf("invalid table map event size %d, column count %d", len(data), event.ColumnCount)
+	}
 	pos += n
 
 	// column_type_def (string.var_len)
@@ -95,7 +170,6 @@ func decodeTableMapEvent(data []byte, h *BinFmtDescEvent) (*BinTableMapEvent, er
 	pos += int(event.ColumnCount)
 
 	// decode column meta
-	var err error
 	var metaData []byte
 	if metaData, _, n, err = LengthEncodedString(data[pos:]); err != nil {
 		return nil, err
@@ -108,18 +182,272 @@ func decodeTableMapEvent(data []byte, h *BinFmtDescEvent) (*BinTableMapEvent, er
 	pos += n
 
 	// null_bitmap (string.var_len) [len=(column_count + 7) / 8]
-	if len(data[pos:]) == bitmapByteSize(int(event.ColumnCount)) {
-		event.NullBitmap = data[pos:]
-		return event, nil
+	bitmapSize := bitmapByteSize(int(event.ColumnCount))
+	if len(data[pos:]) < bitmapSize {
+		return event, io.EOF
+	}
+	event.NullBitmap = data[pos : pos+bitmapSize]
+	pos += bitmapSize
+
+	// optional metadata, binlog_row_metadata (mysql 8.0.1)
+	if err := event.decodeOptionalMeta(data[pos:]); err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
+
+// decodeOptionalMeta decode the optional metadata fields, each field is type(1), length(packed integer), value
+func (e *BinTableMapEvent) decodeOptionalMeta(data []byte) error {
+	for pos := 0; pos < len(data); {
+		t := data[pos]
+		pos++
+		length, n, err := readLengthEncodedInt(data, pos)
+		if err != nil {
+			return err
+		}
+		pos += n
+		if uint64(len(data)-pos) < length {
+			return io.ErrUnexpectedEOF
+		}
+		value := data[pos : pos+int(length)]
+		pos += int(length)
+
+		switch t {
+		case TableMapOptSignedness:
+			e.decodeSignedness(value)
+		case TableMapOptColumnName:
+			err = e.decodeColumnNames(value)
+		case TableMapOptEnumStrValue:
+			err = e.decodeEnumValues(value, MySQLTypeEnum)
+		case TableMapOptSetStrValue:
+			err = e.decodeEnumValues(value, MySQLTypeSet)
+		case TableMapOptDefaultCharset:
+			err = e.decodeDefaultCharset(value, isCharacterType)
+		case TableMapOptColumnCharset:
+			err = e.decodeColumnCharset(value, isCharacterType)
+		case TableMapOptEnumAndSetDefaultCharset:
+			err = e.decodeDefaultCharset(value, isEnumOrSetType)
+		case TableMapOptEnumAndSetColumnCharset:
+			err = e.decodeColumnCharset(value, isEnumOrSetType)
+		case TableMapOptGeometryType:
+			err = e.decodeGeometryTypes(value)
+		case TableMapOptColumnVisibility:
+			e.decodeColumnVisibility(value)
+		case TableMapOptSimplePrimaryKey:
+			err = e.decodePrimaryKey(value, false)
+		case TableMapOptPrimaryKeyWithPrefix:
+			err = e.decodePrimaryKey(value, true)
+		}
+		if err != nil {
+			return fmt.Errorf("decode table map optional metadata %d: %w", t, err)
+		}
+	}
+	return nil
+}
+
+// decodeSignedness decode SIGNEDNESS, a bitmap over numeric columns only, the highest bit first,
+// set if the column is unsigned
+func (e *BinTableMapEvent) decodeSignedness(data []byte) {
+	index := 0
+	for i, t := range e.ColumnTypeDef {
+		if !isNumericType(t) {
+			continue
+		}
+		if index/8 < len(data) {
+			e.ColumnMetaDef[i].unsigned = data[index/8]&(0x80>>uint(index%8)) != 0
+		}
+		index++
+	}
+}
+
+// decodeColumnVisibility decode COLUMN_VISIBILITY, a bitmap over all columns, the highest bit first,
+// set if the column is visible
+func (e *BinTableMapEvent) decodeColumnVisibility(data []byte) {
+	for i := range e.ColumnMetaDef {
+		if i/8 < len(data) {
+			e.ColumnMetaDef[i].invisible = data[i/8]&(0x80>>uint(i%8)) == 0
+		}
+	}
+}
+
+// isNumericType return bool of if the column type is numeric, which has signedness
+func isNumericType(t FieldType) bool {
+	switch t {
+	case MySQLTypeTiny, MySQLTypeShort, MySQLTypeInt24, MySQLTypeLong, MySQLTypeLonglong,
+		MySQLTypeNewDecimal, MySQLTypeFloat, MySQLTypeDouble:
+		return true
+	}
+	return false
+}
+
+// isCharacterType return bool of if the column type is character, which has charset, ENUM and SET are not included
+func isCharacterType(t FieldType) bool {
+	switch t {
+	case MySQLTypeString, MySQLTypeVarString, MySQLTypeVarchar, MySQLTypeBlob:
+		return true
+	}
+	return false
+}
+
+// isEnumOrSetType return bool of if the column type is ENUM or SET
+func isEnumOrSetType(t FieldType) bool {
+	return t == MySQLTypeEnum || t == MySQLTypeSet
+}
+
+// columnsOf return the indexes of columns whose real type matches
+func (e *BinTableMapEvent) columnsOf(match func(t FieldType) bool) []int {
+	var columns []int
+	for i, t := range e.ColumnTypeDef {
+		if match(e.ColumnMetaDef[i].realType(t)) {
+			columns = append(columns, i)
+		}
+	}
+	return columns
+}
+
+// decodeDefaultCharset decode DEFAULT_CHARSET or ENUM_AND_SET_DEFAULT_CHARSET, the default collation,
+// then pairs of the index (among the matched columns) and the collation of the columns not in default
+func (e *BinTableMapEvent) decodeDefaultCharset(data []byte, match func(t FieldType) bool) error {
+	columns := e.columnsOf(match)
+	defaultCollation, pos, err := readLengthEncodedInt(data, 0)
+	if err != nil {
+		return err
+	}
+	for _, i := range columns {
+		e.ColumnMetaDef[i].collation = uint16(defaultCollation)
 	}
 
-	return event, io.EOF
+	for pos < len(data) {
+		index, n, err := readLengthEncodedInt(data, pos)
+		if err != nil {
+			return err
+		}
+		pos += n
+		collation, n, err := readLengthEncodedInt(data, pos)
+		if err != nil {
+			return err
+		}
+		pos += n
+		if index >= uint64(len(columns)) {
+			return fmt.Errorf("invalid charset column index %d", index)
+		}
+		e.ColumnMetaDef[columns[index]].collation = uint16(collation)
+	}
+	return nil
+}
+
+// decodeColumnCharset decode COLUMN_CHARSET or ENUM_AND_SET_COLUMN_CHARSET, the collation of every matched column
+func (e *BinTableMapEvent) decodeColumnCharset(data []byte, match func(t FieldType) bool) error {
+	pos := 0
+	for _, i := range e.columnsOf(match) {
+		collation, n, err := readLengthEncodedInt(data, pos)
+		if err != nil {
+			return err
+		}
+		pos += n
+		e.ColumnMetaDef[i].collation = uint16(collation)
+	}
+	return nil
+}
+
+// decodeColumnNames decode COLUMN_NAME, the name of every column with length
+func (e *BinTableMapEvent) decodeColumnNames(data []byte) error {
+	for i, pos := 0, 0; i < len(e.ColumnMetaDef) && pos < len(data); i++ {
+		name, _, n, err := LengthEncodedString(data[pos:])
+		if err != nil {
+			return err
+		}
+		e.ColumnMetaDef[i].name = string(name)
+		pos += n
+	}
+	return nil
+}
+
+// decodeEnumValues decode ENUM_STR_VALUE or SET_STR_VALUE, for every ENUM or SET column in order,
+// the number of labels and the labels with length
+func (e *BinTableMapEvent) decodeEnumValues(data []byte, t FieldType) error {
+	pos := 0
+	for i := range e.ColumnTypeDef {
+		if e.ColumnMetaDef[i].realType(e.ColumnTypeDef[i]) != t {
+			continue
+		}
+		count, n, err := readLengthEncodedInt(data, pos)
+		if err != nil {
+			return err
+		}
+		pos += n
+		if count > uint64(len(data)-pos) {
+			return fmt.Errorf("invalid enum value count %d", count)
+		}
+
+		values := make([]string, 0, count)
+		for j := uint64(0); j < count; j++ {
+			if pos >= len(data) {
+				return io.ErrUnexpectedEOF
+			}
+			value, _, n, err := LengthEncodedString(data[pos:])
+			if err != nil {
+				return err
+			}
+			values = append(values, string(value))
+			pos += n
+		}
+		e.ColumnMetaDef[i].enumValues = values
+	}
+	return nil
+}
+
+// decodeGeometryTypes decode GEOMETRY_TYPE, the geometry type of every spatial column in order
+func (e *BinTableMapEvent) decodeGeometryTypes(data []byte) error {
+	pos := 0
+	for i, t := range e.ColumnTypeDef {
+		if t != MySQLTypeGeometry {
+			continue
+		}
+		geometryType, n, err := readLengthEncodedInt(data, pos)
+		if err != nil {
+			return err
+		}
+		pos += n
+		e.ColumnMetaDef[i].geometryType = GeometryType(geometryType)
+	}
+	return nil
+}
+
+// decodePrimaryKey decode SIMPLE_PRIMARY_KEY (column indexes)
+// and PRIMARY_KEY_WITH_PREFIX (pairs of column index and prefix length, 0 means the whole column)
+func (e *BinTableMapEvent) decodePrimaryKey(data []byte, withPrefix bool) error {
+	for pos := 0; pos < len(data); {
+		index, n, err := readLengthEncodedInt(data, pos)
+		if err != nil {
+			return err
+		}
+		pos += n
+		if index >= e.ColumnCount {
+			return fmt.Errorf("invalid primary key column index %d", index)
+		}
+
+		var prefix uint64
+		if withPrefix {
+			if prefix, n, err = readLengthEncodedInt(data, pos); err != nil {
+				return err
+			}
+			pos += n
+		}
+		e.PrimaryKey = append(e.PrimaryKey, int(index))
+		e.PrimaryKeyPrefix = append(e.PrimaryKeyPrefix, int(prefix))
+	}
+	return nil
 }
 
 func (e *BinTableMapEvent) decodeMeta(data []byte) error {
 	pos := 0
 	e.ColumnMetaDef = make([]ColumnType, e.ColumnCount)
 	for i, t := range e.ColumnTypeDef {
+		if len(data)-pos < columnMetaLength(t) {
+			return fmt.Errorf("invalid table map column meta size %d", len(data))
+		}
 		switch t {
 		case MySQLTypeString:
 			var fieldType, fieldLength uint8
@@ -144,10 +472,15 @@ func (e *BinTableMapEvent) decodeMeta(data []byte) error {
 			pos += 1
 			e.ColumnMetaDef[i].bits = (bytes * 8) + bits
 			e.ColumnMetaDef[i].bytes = int((e.ColumnMetaDef[i].bits + 7) / 8)
-		case MySQLTypeBlob, MySQLTypeGeometry, MySQLTypeDouble, MySQLTypeFloat,
+		case MySQLTypeBlob, MySQLTypeGeometry,
 			MySQLTypeMediumBlob, MySQLTypeTinyBlob, MySQLTypeLongBlob, MySQLTypeJSON:
+			// the bytes of length prefix
 			e.ColumnMetaDef[i].lengthSize = data[pos]
 			pos++
+		case MySQLTypeDouble, MySQLTypeFloat:
+			// the pack length, 4 or 8, not a length prefix
+			e.ColumnMetaDef[i].size = uint16(data[pos])
+			pos++
 		case MySQLTypeNewDecimal:
 			e.ColumnMetaDef[i].precision = int(data[pos])
 			pos += 1
@@ -156,6 +489,26 @@ func (e *BinTableMapEvent) decodeMeta(data []byte) error {
 		case MySQLTypeTime2, MySQLTypeDatetime2, MySQLTypeTimestamp2:
 			e.ColumnMetaDef[i].fsp = data[pos]
 			pos += 1
+		case MySQLTypeTypedArray:
+			// element type(1), element metadata
+			// https://github.com/mysql/mysql-server/blob/8.0/sql/field.cc (Field_typed_array::do_save_field_metadata)
+			e.ColumnMetaDef[i].elementType = FieldType(data[pos])
+			pos++
+			if len(data)-pos < typedArrayMetaLength(e.ColumnMetaDef[i].elementType) {
+				return fmt.Errorf("invalid table map column meta size %d", len(data))
+			}
+			switch e.ColumnMetaDef[i].elementType {
+			case MySQLTypeVarchar:
+				e.ColumnMetaDef[i].maxLength = binary.LittleEndian.Uint16(data[pos:])
+				pos += 2
+			case MySQLTypeNewDecimal:
+				e.ColumnMetaDef[i].precision = int(data[pos])
+				e.ColumnMetaDef[i].decimals = int(data[pos+1])
+				pos += 2
+			case MySQLTypeTime, MySQLTypeDatetime, MySQLTypeTime2, MySQLTypeDatetime2:
+				e.ColumnMetaDef[i].fsp = data[pos]
+				pos++
+			}
 		case MySQLTypeDate, MySQLTypeDatetime, MySQLTypeTimestamp, MySQLTypeTime,
 			MySQLTypeTiny, MySQLTypeShort, MySQLTypeInt24, MySQLTypeLong,
 			MySQLTypeLonglong, MySQLTypeNull, MySQLTypeYear, MySQLTypeNewDate:
@@ -163,10 +516,39 @@ func (e *BinTableMapEvent) decodeMeta(data []byte) error {
 		default:
 			return fmt.Errorf("unknown FieldType %s", fmt.Sprint(t))
 		}
+		if err := e.ColumnMetaDef[i].validate(t); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
+// columnMetaLength return the length of column metadata of the field type in TABLE_MAP_EVENT,
+// the element metadata of typed array is not included
+func columnMetaLength(t FieldType) int {
+	switch t {
+	case MySQLTypeString, MySQLTypeVarString, MySQLTypeVarchar, MySQLTypeDecimal,
+		MySQLTypeBit, MySQLTypeNewDecimal:
+		return 2
+	case MySQLTypeBlob, MySQLTypeGeometry, MySQLTypeMediumBlob, MySQLTypeTinyBlob, MySQLTypeLongBlob,
+		MySQLTypeJSON, MySQLTypeDouble, MySQLTypeFloat,
+		MySQLTypeTime2, MySQLTypeDatetime2, MySQLTypeTimestamp2, MySQLTypeTypedArray:
+		return 1
+	}
+	return 0
+}
+
+// typedArrayMetaLength return the length of element metadata of the typed array
+func typedArrayMetaLength(elementType FieldType) int {
+	switch elementType {
+	case MySQLTypeVarchar, MySQLTypeNewDecimal:
+		return 2
+	case MySQLTypeTime, MySQLTypeDatetime, MySQLTypeTime2, MySQLTypeDatetime2:
+		return 1
+	}
+	return 0
+}
+
 // BinRowsEvent describe MySQL ROWS_EVENT
 // https://dev.mysql.com/doc/internals/en/rows-event.html
 type BinRowsEvent struct {
@@ -183,19 +565,98 @@ type BinRowsEvent struct {
 	ColumnsBitmap1 Bitfield
 	ColumnsBitmap2 Bitfield // if UPDATE_ROWS_EVENTv1 or v2
 
-	rows []map[string]interface{}
+	// Rows is the decoded row images, UPDATE_ROWS_EVENT stores the before image and after image in turn.
+	// the value of a NULL column is nil, the column absent from the image (not set in the
+	// columns-present-bitmap, e.g. binlog_row_image=MINIMAL) is omitted from the map.
+	Rows []map[string]interface{}
 
-	tableMap *BinTableMapEvent // 该event所属的tableMap
+	tableMap  *BinTableMapEvent // 该event所属的tableMap
+	eventType EventType
+	imagePos  int // offset of the row images in event body
+}
+
+// RowsAction is the action of ROWS_EVENT
+type RowsAction int
+
+// ROWS_EVENT actions
+const (
+	RowsActionInsert RowsAction = iota
+	RowsActionUpdate
+	RowsActionDelete
+)
+
+// String return the SQL verb of action
+func (action RowsAction) String() string {
+	switch action {
+	case RowsActionInsert:
+		return "INSERT"
+	case RowsActionUpdate:
+		return "UPDATE"
+	case RowsActionDelete:
+		return "DELETE"
+	}
+	return "UNKNOWN"
+}
+
+// RowImageType is the binlog_row_image which the ROWS_EVENT is logged with, inferred from columns-present-bitmaps
+type RowImageType int
+
+// binlog_row_image
+const (
+	RowImageUnknown RowImageType = iota
+	RowImageFull                 // all columns
+	RowImageMinimal              // the columns to identify the row and the changed columns
+	RowImageNoBlob               // all columns except the unneeded BLOB, TEXT, JSON and GEOMETRY
+)
+
+// String return the value of binlog_row_image
+func (t RowImageType) String() string {
+	switch t {
+	case RowImageFull:
+		return "FULL"
+	case RowImageMinimal:
+		return "MINIMAL"
+	case RowImageNoBlob:
+		return "NOBLOB"
+	}
+	return "UNKNOWN"
+}
+
+// ImageType infer the binlog_row_image of event: FULL if all columns are present in the images,
+// NOBLOB if only BLOB-like columns are absent, otherwise MINIMAL. UNKNOWN if the table map is missing.
+// The before image of FULL or NOBLOB identifies the row by all of the non-BLOB columns, e.g. a safe WHERE
+// of flashback SQL, while MINIMAL relies on the primary key. A table without BLOB columns never reports NOBLOB.
+func (e *BinRowsEvent) ImageType() RowImageType {
+	if e.tableMap == nil {
+		return RowImageUnknown
+	}
+	imageType := RowImageFull
+	for i := 0; i < int(e.ColumnCount); i++ {
+		if e.ColumnsBitmap1.isSet(uint(i)) && (len(e.ColumnsBitmap2) == 0 || e.ColumnsBitmap2.isSet(uint(i))) {
+			continue
+		}
+		if i >= len(e.tableMap.ColumnTypeDef) {
+			return RowImageMinimal
+		}
+		switch e.tableMap.ColumnTypeDef[i] {
+		case MySQLTypeBlob, MySQLTypeTinyBlob, MySQLTypeMediumBlob, MySQLTypeLongBlob, MySQLTypeJSON, MySQLTypeGeometry:
+			imageType = RowImageNoBlob
+		default:
+			return RowImageMinimal
+		}
+	}
+	return imageType
 }
 
 // Init BinRowsEvent, adding version and table_id length
-func (e *BinRowsEvent) Init(h *BinFmtDescEvent, eventType uint8) *BinRowsEvent {
-	if int(h.EventTypeHeader[eventType-1]) == 6 {
+func (e *BinRowsEvent) Init(h *BinFmtDescEvent, eventType EventType) *BinRowsEvent {
+	if h.postHeaderLength(eventType) == 6 {
 		e.tableIDLen = 4
 	} else {
 		e.tableIDLen = 6
 	}
 
+	e.eventType = eventType
 	switch eventType {
 	case WriteRowsEventV0, UpdateRowsEventV0, DeleteRowsEventV0:
 		e.Version = 0
@@ -208,12 +669,16 @@ func (e *BinRowsEvent) Init(h *BinFmtDescEvent, eventType uint8) *BinRowsEvent {
 	return e
 }
 
-func decodeRowsEvent(data []byte, h *BinFmtDescEvent, typ uint8) (*BinRowsEvent, error) {
+func decodeRowsEvent(data []byte, h *BinFmtDescEvent, typ EventType, tableInfo map[uint64]*BinTableMapEvent, strictNull bool) (*BinRowsEvent, error) {
 	event := &BinRowsEvent{}
 	event = event.Init(h, typ)
 
 	// set table id
 	pos := event.tableIDLen
+	// table_id, flags(2), extra_data_length(2, v2)
+	if len(data) < pos+2 || event.Version == 2 && len(data) < pos+4 {
+		return nil, fmt.Errorf("invalid rows event size %d", len(data))
+	}
 	event.TableID = FixedLengthInt(data[:pos])
 
 	// set flags
@@ -222,20 +687,33 @@ func decodeRowsEvent(data []byte, h *BinFmtDescEvent, typ uint8) (*BinRowsEvent,
 
 	// set extraDataLength
 	if event.Version == 2 {
-		extraDataLen := binary.LittleEndian.Uint16(data[pos:])
+		extraDataLen := int(binary.LittleEndian.Uint16(data[pos:]))
 		pos += 2
+		if extraDataLen < 2 || len(data) < pos+extraDataLen-2 {
+			return nil, fmt.Errorf("invalid rows event size %d, extra data length %d", len(data), extraDataLen)
+		}
 
-		event.ExtraData = data[pos : pos+int(extraDataLen-2)]
-		pos += int(extraDataLen - 2)
+		event.ExtraData = data[pos : pos+extraDataLen-2]
+		pos += extraDataLen - 2
 	}
 
 	// body
 	var n int
-	event.ColumnCount, _, n = LengthEncodedInt(data[pos:])
+	var err error
+	if event.ColumnCount, n, err = readLengthEncodedInt(data, pos); err != nil || event.ColumnCount > uint64(len(data))*8 {
+		return nil, fmt.Errorf("invalid rows event size %d, column count %d", len(data), event.ColumnCount)
+	}
 	pos += n
 
 	// columns-present-bitmap1
 	bitCount := bitmapByteSize(int(event.ColumnCount))
+	bitmaps := 1
+	if typ == UpdateRowsEventV1 || typ == UpdateRowsEventV2 {
+		bitmaps = 2
+	}
+	if len(data) < pos+bitmaps*bitCount {
+		return nil, fmt.Errorf("invalid rows event size %d, column count %d", len(data), event.ColumnCount)
+	}
 	event.ColumnsBitmap1 = data[pos : pos+bitCount]
 	pos += bitCount
 
@@ -245,7 +723,112 @@ func decodeRowsEvent(data []byte, h *BinFmtDescEvent, typ uint8) (*BinRowsEvent,
 		pos += bitCount
 	}
 
-	// TODO Unfinished
+	table, ok := tableInfo[event.TableID]
+	if !ok {
+		return nil, fmt.Errorf("table map of table id %d not found", event.TableID)
+	}
+	event.tableMap = table
+
+	// rows, UPDATE_ROWS_EVENT contains the before image and the after image
+	event.imagePos = pos
+	for pos < len(data) {
+		start := pos
+		row, n, err := event.decodeImage(data[pos:], table, event.ColumnsBitmap1, strictNull)
+		if err != nil {
+			return nil, err
+		}
+		pos += n
+		event.Rows = append(event.Rows, row)
+
+		if typ == UpdateRowsEventV1 || typ == UpdateRowsEventV2 {
+			row, n, err = event.decodeImage(data[pos:], table, event.ColumnsBitmap2, strictNull)
+			if err != nil {
+				return nil, err
+			}
+			pos += n
+			event.Rows = append(event.Rows, row)
+		}
+		// the images of no present column are empty, which would never consume the rest
+		if pos == start {
+			return nil, fmt.Errorf("invalid rows event size %d, no column is present", len(data))
+		}
+	}
 
 	return event, nil
 }
+
+// Action return the action of rows event
+func (e *BinRowsEvent) Action() RowsAction {
+	switch e.eventType {
+	case UpdateRowsEventV0, UpdateRowsEventV1, UpdateRowsEventV2:
+		return RowsActionUpdate
+	case DeleteRowsEventV0, DeleteRowsEventV1, DeleteRowsEventV2:
+		return RowsActionDelete
+	}
+	return RowsActionInsert
+}
+
+// StmtEnd return bool of if the rows event is the last one of statement.
+// A statement may be logged as several rows events, e.g. REPLACE and INSERT ... ON DUPLICATE KEY UPDATE
+// are logged as UPDATE_ROWS_EVENT, or DELETE_ROWS_EVENT followed by WRITE_ROWS_EVENT when the
+// conflicting row can not be updated in place. The binary log does not record the original statement,
+// so these events should be applied in order as they are, only the last one has STMT_END_F.
+func (e *BinRowsEvent) StmtEnd() bool {
+	return e.Flags&RowsEventStmtEndF != 0
+}
+
+// TableMap return the TABLE_MAP_EVENT which the rows event belongs to
+func (e *BinRowsEvent) TableMap() *BinTableMapEvent {
+	return e.tableMap
+}
+
+// decodeImage decode a row image, only the columns set in columns-present-bitmap are stored in the image,
+// and the NULL-bitmap of the image is indexed over the present columns.
+// The NULL of a column declared NOT NULL by table map is an error if strictNull.
+func (e *BinRowsEvent) decodeImage(data []byte, table *BinTableMapEvent, present Bitfield, strictNull bool) (map[string]interface{}, int, error) {
+	columnCount := int(e.ColumnCount)
+	if columnCount > len(table.ColumnTypeDef) {
+		return nil, 0, fmt.Errorf("rows event has %d columns, but table map %s.%s has %d",
+			columnCount, table.Schema, table.Table, len(table.ColumnTypeDef))
+	}
+
+	presentCount := 0
+	for i := 0; i < columnCount; i++ {
+		if present.isSet(uint(i)) {
+			presentCount++
+		}
+	}
+
+	pos := bitmapByteSize(presentCount)
+	if len(data) < pos {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+	nullBitmap := Bitfield(data[:pos])
+
+	row := make(map[string]interface{}, presentCount)
+	nullIndex := uint(0)
+	for i := 0; i < columnCount; i++ {
+		if !present.isSet(uint(i)) {
+			continue
+		}
+
+		name := table.ColumnName(i)
+		// NULL is decided by the NULL-bitmap of row image, the nullable of table map only validates it
+		if nullBitmap.isSet(nullIndex) {
+			if strictNull && !table.Nullable(i) {
+				return nil, 0, fmt.Errorf("column %s of %s.%s is NULL, but declared NOT NULL in table map", name, table.Schema, table.Table)
+			}
+			row[name] = nil
+		} else {
+			v, n, err := decodeValue(data[pos:], table.ColumnTypeDef[i], &table.ColumnMetaDef[i])
+			if err != nil {
+				return nil, 0, fmt.Errorf("decode column %s of %s.%s: %w", name, table.Schema, table.Table, err)
+			}
+			row[name] = v
+			pos += n
+		}
+		nullIndex++
+	}
+
+	return row, pos, nil
+}
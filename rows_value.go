@@ -0,0 +1,390 @@
+package binlog
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// offsets of the MySQL 5.6 temporal types
+// https://dev.mysql.com/doc/internals/en/date-and-time-data-type-representation.html
+const (
+	datetimeIntOffset = 0x8000000000
+	timeIntOffset     = 0x800000
+	timeOffset        = 0x800000000000
+)
+
+// realType return the actual type of column, ENUM and SET are stored as MYSQL_TYPE_STRING in TABLE_MAP_EVENT
+func (c *ColumnType) realType(t FieldType) FieldType {
+	if t == MySQLTypeString && (c.columnType == MySQLTypeEnum || c.columnType == MySQLTypeSet) {
+		return c.columnType
+	}
+	return t
+}
+
+// validate check the precision and fsp of column, the values could not be decoded if they are out of range
+func (c *ColumnType) validate(t FieldType) error {
+	if c.fsp > 6 {
+		return fmt.Errorf("invalid fsp %d of FieldType %s", c.fsp, fmt.Sprint(t))
+	}
+	if (t == MySQLTypeNewDecimal || c.elementType == MySQLTypeNewDecimal) && !validDecimal(c.precision, c.decimals) {
+		return fmt.Errorf("invalid DECIMAL(%d,%d)", c.precision, c.decimals)
+	}
+	return nil
+}
+
+// decodeValue decode a single column value of row image, return the value and the bytes consumed
+func decodeValue(data []byte, t FieldType, meta *ColumnType) (interface{}, int, error) {
+	// fixed length types
+	var size int
+	switch meta.realType(t) {
+	case MySQLTypeNull:
+		return nil, 0, nil
+	case MySQLTypeTiny, MySQLTypeYear:
+		size = 1
+	case MySQLTypeShort:
+		size = 2
+	case MySQLTypeInt24, MySQLTypeDate, MySQLTypeNewDate, MySQLTypeTime:
+		size = 3
+	case MySQLTypeLong, MySQLTypeFloat, MySQLTypeTimestamp:
+		size = 4
+	case MySQLTypeLonglong, MySQLTypeDouble, MySQLTypeDatetime:
+		size = 8
+	case MySQLTypeTimestamp2:
+		size = 4 + fracByteSize(meta.fsp)
+	case MySQLTypeDatetime2:
+		size = 5 + fracByteSize(meta.fsp)
+	case MySQLTypeTime2:
+		size = 3 + fracByteSize(meta.fsp)
+	case MySQLTypeBit:
+		size = meta.bytes
+	case MySQLTypeEnum, MySQLTypeSet:
+		size = int(meta.size)
+	case MySQLTypeNewDecimal:
+		size = decimalByteSize(meta.precision, meta.decimals)
+	}
+	if len(data) < size {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+
+	switch meta.realType(t) {
+	case MySQLTypeTiny:
+		if meta.unsigned {
+			return data[0], 1, nil
+		}
+		return int8(data[0]), 1, nil
+	case MySQLTypeShort:
+		v := binary.LittleEndian.Uint16(data)
+		if meta.unsigned {
+			return v, 2, nil
+		}
+		return int16(v), 2, nil
+	case MySQLTypeInt24:
+		v := uint32(FixedLengthInt(data[:3]))
+		if meta.unsigned {
+			return v, 3, nil
+		}
+		if v&0x800000 != 0 {
+			v |= 0xff000000
+		}
+		return int32(v), 3, nil
+	case MySQLTypeLong:
+		v := binary.LittleEndian.Uint32(data)
+		if meta.unsigned {
+			return v, 4, nil
+		}
+		return int32(v), 4, nil
+	case MySQLTypeLonglong:
+		v := binary.LittleEndian.Uint64(data)
+		if meta.unsigned {
+			return v, 8, nil
+		}
+		return int64(v), 8, nil
+	case MySQLTypeFloat:
+		return math.Float32frombits(binary.LittleEndian.Uint32(data)), 4, nil
+	case MySQLTypeDouble:
+		return math.Float64frombits(binary.LittleEndian.Uint64(data)), 8, nil
+	case MySQLTypeNewDecimal:
+		return decodeDecimal(data[:size], meta.precision, meta.decimals), size, nil
+	case MySQLTypeBit:
+		return BFixedLengthInt(data[:size]), size, nil
+	case MySQLTypeEnum:
+		v := int64(FixedLengthInt(data[:size]))
+		if meta.enumValues != nil {
+			return enumLabel(meta.enumValues, v), size, nil
+		}
+		return v, size, nil
+	case MySQLTypeSet:
+		v := FixedLengthInt(data[:size])
+		if meta.enumValues != nil {
+			return setLabels(meta.enumValues, v), size, nil
+		}
+		return v, size, nil
+	case MySQLTypeYear:
+		if data[0] == 0 {
+			return 0, 1, nil
+		}
+		return 1900 + int(data[0]), 1, nil
+	case MySQLTypeDate, MySQLTypeNewDate:
+		v := FixedLengthInt(data[:3])
+		return fmt.Sprintf("%04d-%02d-%02d", v>>9, (v>>5)%16, v%32), 3, nil
+	case MySQLTypeTime:
+		v := FixedLengthInt(data[:3])
+		d := time.Duration(v/10000)*time.Hour + time.Duration(v%10000/100)*time.Minute + time.Duration(v%100)*time.Second
+		return d, 3, nil
+	case MySQLTypeDatetime:
+		v := binary.LittleEndian.Uint64(data)
+		d, t := v/1000000, v%1000000
+		return fmt.Sprintf("%04d-%02d-%02d %02d:%02d:%02d",
+			d/10000, d%10000/100, d%100, t/10000, t%10000/100, t%100), 8, nil
+	case MySQLTypeTimestamp:
+		return time.Unix(int64(binary.LittleEndian.Uint32(data)), 0).UTC(), 4, nil
+	case MySQLTypeTimestamp2:
+		sec := int64(binary.BigEndian.Uint32(data))
+		usec := decodeFrac(data[4:size], meta.fsp)
+		return time.Unix(sec, usec*int64(time.Microsecond)).UTC(), size, nil
+	case MySQLTypeDatetime2:
+		return decodeDatetime2(data[:size], meta.fsp), size, nil
+	case MySQLTypeTime2:
+		return decodeTime2(data[:size], meta.fsp), size, nil
+	case MySQLTypeVarchar, MySQLTypeVarString, MySQLTypeString:
+		return decodeString(data, meta.maxLength)
+	case MySQLTypeBlob, MySQLTypeTinyBlob, MySQLTypeMediumBlob, MySQLTypeLongBlob, MySQLTypeGeometry:
+		return decodeBlob(data, int(meta.lengthSize))
+	case MySQLTypeJSON:
+		v, n, err := decodeBlob(data, int(meta.lengthSize))
+		if err != nil {
+			return nil, 0, err
+		}
+		doc, err := decodeJSONB(v.([]byte))
+		if err != nil {
+			return nil, 0, fmt.Errorf("decode JSON: %w", err)
+		}
+		return doc, n, nil
+	case MySQLTypeTypedArray:
+		// stored like JSON with 4 bytes length, the raw bytes are returned
+		return decodeBlob(data, 4)
+	}
+
+	return nil, 0, fmt.Errorf("unsupported FieldType %d", t)
+}
+
+// enumLabel return the label of ENUM index, the index starts from 1, 0 is the empty string of invalid value
+func enumLabel(values []string, index int64) string {
+	if index < 1 || index > int64(len(values)) {
+		return ""
+	}
+	return values[index-1]
+}
+
+// setLabels return the labels of SET bits, joined with comma in order
+func setLabels(values []string, bits uint64) string {
+	labels := make([]string, 0, len(values))
+	for i, value := range values {
+		if bits&(1<<uint(i)) != 0 {
+			labels = append(labels, value)
+		}
+	}
+	return strings.Join(labels, ",")
+}
+
+// decodeString decode VARCHAR/CHAR, the length is stored in 1 byte if max length < 256, otherwise 2 bytes
+func decodeString(data []byte, maxLength uint16) (interface{}, int, error) {
+	var length, n int
+	if maxLength < 256 {
+		if len(data) < 1 {
+			return nil, 0, io.ErrUnexpectedEOF
+		}
+		length, n = int(data[0]), 1
+	} else {
+		if len(data) < 2 {
+			return nil, 0, io.ErrUnexpectedEOF
+		}
+		length, n = int(binary.LittleEndian.Uint16(data)), 2
+	}
+	if len(data) < n+length {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+	return string(data[n : n+length]), n + length, nil
+}
+
+// decodeBlob decode BLOB/TEXT/GEOMETRY/JSON, the length is stored in lengthSize bytes
+func decodeBlob(data []byte, lengthSize int) (interface{}, int, error) {
+	if lengthSize < 1 || lengthSize > 4 {
+		return nil, 0, fmt.Errorf("invalid blob length size %d", lengthSize)
+	}
+	if len(data) < lengthSize {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+	length := int(FixedLengthInt(data[:lengthSize]))
+	if len(data) < lengthSize+length {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+	return data[lengthSize : lengthSize+length], lengthSize + length, nil
+}
+
+// fracByteSize return the bytes of fractional seconds part with fsp
+func fracByteSize(fsp uint8) int {
+	return int(fsp+1) / 2
+}
+
+// decodeFrac return the microseconds of fractional seconds part, which is stored in big-endian
+func decodeFrac(data []byte, fsp uint8) int64 {
+	switch fracByteSize(fsp) {
+	case 1:
+		return int64(data[0]) * 10000
+	case 2:
+		return int64(binary.BigEndian.Uint16(data)) * 100
+	case 3:
+		return int64(BFixedLengthInt(data[:3]))
+	}
+	return 0
+}
+
+// formatFrac append fsp digits of microseconds
+func formatFrac(usec int64, fsp uint8) string {
+	if fsp == 0 {
+		return ""
+	}
+	return "." + fmt.Sprintf("%06d", usec)[:fsp]
+}
+
+// decodeDatetime2 decode DATETIME2
+// 1 bit  sign           (1 = non-negative, 0 = negative)
+// 17 bits year*13+month (year 0-9999, month 0-12)
+// 5 bits  day           (0-31)
+// 5 bits  hour          (0-23)
+// 6 bits  minute        (0-59)
+// 6 bits  second        (0-59)
+func decodeDatetime2(data []byte, fsp uint8) string {
+	intPart := int64(BFixedLengthInt(data[:5])) - datetimeIntOffset
+	usec := decodeFrac(data[5:], fsp)
+
+	ymd := intPart >> 17
+	ym := ymd >> 5
+	hms := intPart % (1 << 17)
+
+	return fmt.Sprintf("%04d-%02d-%02d %02d:%02d:%02d%s",
+		ym/13, ym%13, ymd%(1<<5), hms>>12, (hms>>6)%(1<<6), hms%(1<<6), formatFrac(usec, fsp))
+}
+
+// decodeTime2 decode TIME2
+// 1 bit  sign    (1 = non-negative, 0 = negative)
+// 1 bit  unused  (reserved for future extensions)
+// 10 bits hour   (0-838)
+// 6 bits  minute (0-59)
+// 6 bits  second (0-59)
+func decodeTime2(data []byte, fsp uint8) time.Duration {
+	var packed int64
+	switch fsp {
+	case 1, 2:
+		intPart := int64(BFixedLengthInt(data[:3])) - timeIntOffset
+		frac := int64(data[3])
+		if intPart < 0 && frac != 0 {
+			intPart++
+			frac -= 0x100
+		}
+		packed = intPart<<24 + frac*10000
+	case 3, 4:
+		intPart := int64(BFixedLengthInt(data[:3])) - timeIntOffset
+		frac := int64(binary.BigEndian.Uint16(data[3:]))
+		if intPart < 0 && frac != 0 {
+			intPart++
+			frac -= 0x10000
+		}
+		packed = intPart<<24 + frac*100
+	case 5, 6:
+		packed = int64(BFixedLengthInt(data[:6])) - timeOffset
+	default:
+		packed = (int64(BFixedLengthInt(data[:3])) - timeIntOffset) << 24
+	}
+
+	sign := time.Duration(1)
+	if packed < 0 {
+		sign, packed = -1, -packed
+	}
+	hms := packed >> 24
+	usec := packed % (1 << 24)
+
+	d := time.Duration((hms>>12)%(1<<10))*time.Hour +
+		time.Duration((hms>>6)%(1<<6))*time.Minute +
+		time.Duration(hms%(1<<6))*time.Second +
+		time.Duration(usec)*time.Microsecond
+	return sign * d
+}
+
+// decimal is stored as 9 digits per 4 bytes, the leftover digits use compressedBytes bytes
+const digitsPerInteger = 9
+
+var compressedBytes = []int{0, 1, 1, 2, 2, 3, 3, 4, 4, 4}
+
+// validDecimal return false if the decimal with precision and decimals could not be stored
+func validDecimal(precision, decimals int) bool {
+	return precision > 0 && decimals <= precision
+}
+
+func decimalByteSize(precision, decimals int) int {
+	integral := precision - decimals
+	return integral/digitsPerInteger*4 + compressedBytes[integral%digitsPerInteger] +
+		decimals/digitsPerInteger*4 + compressedBytes[decimals%digitsPerInteger]
+}
+
+// decodeDecimal decode NEWDECIMAL into its string form
+// https://github.com/mysql/mysql-server/blob/5.7/strings/decimal.c (bin2decimal)
+func decodeDecimal(data []byte, precision, decimals int) string {
+	integral := precision - decimals
+	buf := make([]byte, len(data))
+	copy(buf, data)
+
+	// the highest bit is the sign, negative numbers are stored inverted
+	var mask byte
+	negative := buf[0]&0x80 == 0
+	if negative {
+		mask = 0xff
+	}
+	buf[0] ^= 0x80
+	for i := range buf {
+		buf[i] ^= mask
+	}
+
+	var pos int
+	var res strings.Builder
+
+	// integral part
+	if n := compressedBytes[integral%digitsPerInteger]; n > 0 {
+		res.WriteString(strconv.FormatUint(BFixedLengthInt(buf[pos:pos+n]), 10))
+		pos += n
+	}
+	for i := 0; i < integral/digitsPerInteger; i++ {
+		res.WriteString(fmt.Sprintf("%09d", binary.BigEndian.Uint32(buf[pos:])))
+		pos += 4
+	}
+	intPart := strings.TrimLeft(res.String(), "0")
+	if intPart == "" {
+		intPart = "0"
+	}
+
+	// fractional part
+	res.Reset()
+	for i := 0; i < decimals/digitsPerInteger; i++ {
+		res.WriteString(fmt.Sprintf("%09d", binary.BigEndian.Uint32(buf[pos:])))
+		pos += 4
+	}
+	if leftover := decimals % digitsPerInteger; leftover > 0 {
+		n := compressedBytes[leftover]
+		res.WriteString(fmt.Sprintf("%0*d", leftover, BFixedLengthInt(buf[pos:pos+n])))
+	}
+
+	value := intPart
+	if decimals > 0 {
+		value += "." + res.String()
+	}
+	if negative {
+		value = "-" + value
+	}
+	return value
+}
@@ -0,0 +1,145 @@
+package binlog
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// ScanInto will fill dest with decoded rows, dest is a pointer to a slice of structs or struct pointers,
+// one element for each image of Rows, so UPDATE_ROWS_EVENT fills the before and after images in turn.
+// Struct fields are mapped by tag `binlog:"column_name"`, or by field name if not tagged, "-" is skipped.
+// NULL sets a pointer field to nil and others to zero value, the absent columns are left untouched.
+func (e *BinRowsEvent) ScanInto(dest interface{}) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("scan into %T: dest must be a pointer to slice", dest)
+	}
+	slice := v.Elem()
+	elemType := slice.Type().Elem()
+	isPtr := elemType.Kind() == reflect.Ptr
+	structType := elemType
+	if isPtr {
+		structType = elemType.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		return fmt.Errorf("scan into %T: element must be struct or struct pointer", dest)
+	}
+	fields := scanFields(structType)
+
+	for i, row := range e.Rows {
+		elem := reflect.New(structType).Elem()
+		for column, value := range row {
+			index, ok := fields[column]
+			if !ok {
+				continue
+			}
+			field := elem.Field(index)
+			if err := scanValue(field, value); err != nil {
+				return fmt.Errorf("scan row %d column %s into field %s: %w", i, column, structType.Field(index).Name, err)
+			}
+		}
+		if isPtr {
+			elem = elem.Addr()
+		}
+		slice.Set(reflect.Append(slice, elem))
+	}
+	return nil
+}
+
+// scanFields return the column name => field index of struct
+func scanFields(t reflect.Type) map[string]int {
+	fields := make(map[string]int, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := field.Tag.Get("binlog")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = i
+	}
+	return fields
+}
+
+// scanValue set the decoded value into field, with conversion between numeric types and string/[]byte
+func scanValue(field reflect.Value, value interface{}) error {
+	if value == nil {
+		field.Set(reflect.Zero(field.Type()))
+		return nil
+	}
+	if field.Kind() == reflect.Ptr {
+		elem := reflect.New(field.Type().Elem())
+		if err := scanValue(elem.Elem(), value); err != nil {
+			return err
+		}
+		field.Set(elem)
+		return nil
+	}
+
+	v := reflect.ValueOf(value)
+	if v.Type().AssignableTo(field.Type()) {
+		field.Set(v)
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if !field.OverflowInt(v.Int()) {
+				field.SetInt(v.Int())
+				return nil
+			}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if n := v.Uint(); n <= 1<<63-1 && !field.OverflowInt(int64(n)) {
+				field.SetInt(int64(n))
+				return nil
+			}
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if n := v.Int(); n >= 0 && !field.OverflowUint(uint64(n)) {
+				field.SetUint(uint64(n))
+				return nil
+			}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if !field.OverflowUint(v.Uint()) {
+				field.SetUint(v.Uint())
+				return nil
+			}
+		}
+	case reflect.Float32, reflect.Float64:
+		switch v.Kind() {
+		case reflect.Float32, reflect.Float64:
+			field.SetFloat(v.Float())
+			return nil
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			field.SetFloat(float64(v.Int()))
+			return nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			field.SetFloat(float64(v.Uint()))
+			return nil
+		}
+	case reflect.String:
+		switch value := value.(type) {
+		case string:
+			field.SetString(value)
+			return nil
+		case []byte:
+			field.SetString(string(value))
+			return nil
+		}
+	case reflect.Slice:
+		if s, ok := value.(string); ok && field.Type().Elem().Kind() == reflect.Uint8 {
+			field.SetBytes([]byte(s))
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot convert %v (%T) to %s", value, value, field.Type())
+}
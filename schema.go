@@ -0,0 +1,92 @@
+package binlog
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+)
+
+// LoadColumnNames load the column names from a JSON file, which maps 'db.table' to ordered column names.
+// e.g. {"employees.departments": ["dept_no", "dept_name"]}
+func LoadColumnNames(path string) (map[string][]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	columnNames := make(map[string][]string)
+	if err := json.Unmarshal(data, &columnNames); err != nil {
+		return nil, err
+	}
+	return columnNames, nil
+}
+
+// applyColumnNames set the column names of the table map which has no column name in binary log
+func applyColumnNames(table *BinTableMapEvent, columnNames map[string][]string) {
+	names, ok := columnNames[table.Schema+"."+table.Table]
+	if !ok {
+		return
+	}
+	for i := range table.ColumnMetaDef {
+		if i < len(names) && table.ColumnMetaDef[i].name == "" {
+			table.ColumnMetaDef[i].name = names[i]
+		}
+	}
+}
+
+// invalidateColumnNames drop the column names of tables changed by the DDL of query, since the columns
+// may be added, dropped or reordered, the rows after it are keyed by positional '@N' names rather than
+// the stale names. The names follow the tables of RENAME TABLE. The map of BinReaderOption is not changed.
+func (info *BinaryLogInfo) invalidateColumnNames(query *BinQueryEvent) {
+	if len(info.columnNames) == 0 || !IsDDL(query.Query) {
+		return
+	}
+	change, ok := ParseDDL(query.Schema, query.Query)
+	if !ok {
+		return
+	}
+
+	var dropped []string
+	var renamed [][2]string
+	switch change.Operation {
+	case DDLAlterTable, DDLDropTable:
+		// the columns of ALTER TABLE ... RENAME may be changed too
+		for _, table := range append(change.Tables, change.NewTables...) {
+			dropped = append(dropped, table.String())
+		}
+	case DDLRenameTable:
+		for i, table := range change.Tables {
+			if i < len(change.NewTables) {
+				renamed = append(renamed, [2]string{table.String(), change.NewTables[i].String()})
+			}
+		}
+	case DDLDropDatabase:
+		for _, table := range change.Tables {
+			for name := range info.columnNames {
+				if strings.HasPrefix(name, table.Schema+".") {
+					dropped = append(dropped, name)
+				}
+			}
+		}
+	default:
+		return
+	}
+
+	columnNames := make(map[string][]string, len(info.columnNames))
+	for name, columns := range info.columnNames {
+		columnNames[name] = columns
+	}
+	for _, name := range dropped {
+		delete(columnNames, name)
+	}
+	// in order, e.g. swap by RENAME TABLE a TO tmp, b TO a, tmp TO b
+	for _, rename := range renamed {
+		if columns, ok := columnNames[rename[0]]; ok {
+			delete(columnNames, rename[0])
+			columnNames[rename[1]] = columns
+		} else {
+			delete(columnNames, rename[1])
+		}
+	}
+	info.columnNames = columnNames
+}
@@ -0,0 +1,295 @@
+package binlog
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SQLOption describe how to generate SQL statements from ROWS_EVENT
+type SQLOption struct {
+	// use all columns of the before image in WHERE clause of UPDATE/DELETE,
+	// even if the primary key is known
+	FullColumnWhere bool
+
+	// omit the invisible columns (mysql 8.0.23) from INSERT and SET of UPDATE,
+	// the same as SELECT * which does not return invisible columns
+	SkipInvisibleColumns bool
+
+	// prepend SET time_zone='+00:00' to the statements. TIMESTAMP values are rendered in UTC,
+	// so the statements must run in a session of UTC time_zone, or they change the values
+	SetTimeZone bool
+}
+
+// setTimeZoneUTC is the statement of SQLOption.SetTimeZone
+const setTimeZoneUTC = "SET time_zone='+00:00'"
+
+// zeroTimestamp is the zero value of TIMESTAMP, which is stored as 0 seconds since epoch.
+// 1970-01-01 00:00:00 UTC is out of the range of TIMESTAMP, so 0 is always the zero value
+const zeroTimestamp = "0000-00-00 00:00:00"
+
+// SQL return the statements which apply the rows event, one statement per row.
+// The WHERE clause of UPDATE/DELETE use the primary key columns if the primary key is known
+// (TABLE_MAP optional metadata) and present in the before image, otherwise all columns.
+// TIMESTAMP values are in UTC, see SQLOption.SetTimeZone.
+func (e *BinRowsEvent) SQL(option *SQLOption) ([]string, error) {
+	table := e.tableMap
+	if table == nil {
+		return nil, fmt.Errorf("table map of table id %d not found", e.TableID)
+	}
+	if option == nil {
+		option = &SQLOption{}
+	}
+
+	name := quoteIdentifier(table.Schema) + "." + quoteIdentifier(table.Table)
+	var statements []string
+	if option.SetTimeZone {
+		statements = append(statements, setTimeZoneUTC)
+	}
+	switch e.Action() {
+	case RowsActionInsert:
+		for _, row := range e.Rows {
+			columns, values := e.columnValues(row, option)
+			statements = append(statements, fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+				name, strings.Join(columns, ", "), strings.Join(values, ", ")))
+		}
+	case RowsActionUpdate:
+		if len(e.Rows)%2 != 0 {
+			return nil, fmt.Errorf("update rows event has unpaired row images")
+		}
+		for i := 0; i < len(e.Rows); i += 2 {
+			columns, values := e.columnValues(e.Rows[i+1], option)
+			set := make([]string, len(columns))
+			for j := range columns {
+				set[j] = columns[j] + "=" + values[j]
+			}
+			statements = append(statements, fmt.Sprintf("UPDATE %s SET %s WHERE %s",
+				name, strings.Join(set, ", "), e.where(e.Rows[i], option)))
+		}
+	case RowsActionDelete:
+		for _, row := range e.Rows {
+			statements = append(statements, fmt.Sprintf("DELETE FROM %s WHERE %s", name, e.where(row, option)))
+		}
+	}
+	return statements, nil
+}
+
+// columnValues return the quoted columns and values present in row, in the order of table columns
+func (e *BinRowsEvent) columnValues(row map[string]interface{}, option *SQLOption) ([]string, []string) {
+	var columns, values []string
+	for i := 0; i < int(e.tableMap.ColumnCount); i++ {
+		if option.SkipInvisibleColumns && i < len(e.tableMap.ColumnMetaDef) && e.tableMap.ColumnMetaDef[i].Invisible() {
+			continue
+		}
+		name := e.tableMap.ColumnName(i)
+		v, ok := row[name]
+		if !ok {
+			continue
+		}
+		columns = append(columns, quoteIdentifier(name))
+		values = append(values, formatSQLValue(v))
+	}
+	return columns, values
+}
+
+// where return the WHERE clause matching the before image
+func (e *BinRowsEvent) where(row map[string]interface{}, option *SQLOption) string {
+	columns, byPrimaryKey := e.whereColumns(row, option)
+	conditions := make([]string, 0, len(columns))
+	for _, i := range columns {
+		name := e.tableMap.ColumnName(i)
+		if v := row[name]; v == nil {
+			conditions = append(conditions, quoteIdentifier(name)+" IS NULL")
+		} else {
+			conditions = append(conditions, quoteIdentifier(name)+"="+formatSQLValue(v))
+		}
+	}
+	where := strings.Join(conditions, " AND ")
+	if !byPrimaryKey {
+		where += " LIMIT 1"
+	}
+	return where
+}
+
+// whereColumns return the primary key columns if all of them are in row, otherwise all columns in row
+func (e *BinRowsEvent) whereColumns(row map[string]interface{}, option *SQLOption) ([]int, bool) {
+	if !option.FullColumnWhere && len(e.tableMap.PrimaryKey) > 0 {
+		usePrimaryKey := true
+		for _, i := range e.tableMap.PrimaryKey {
+			if _, ok := row[e.tableMap.ColumnName(i)]; !ok {
+				usePrimaryKey = false
+				break
+			}
+		}
+		if usePrimaryKey {
+			return e.tableMap.PrimaryKey, true
+		}
+	}
+
+	var columns []int
+	for i := 0; i < int(e.tableMap.ColumnCount); i++ {
+		if _, ok := row[e.tableMap.ColumnName(i)]; ok {
+			columns = append(columns, i)
+		}
+	}
+	return columns, false
+}
+
+func quoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+func quoteString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return "'" + strings.ReplaceAll(s, "'", `\'`) + "'"
+}
+
+// formatSQLValue format decoded value as SQL literal
+func formatSQLValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return quoteString(v)
+	case []byte:
+		if len(v) == 0 {
+			return "''"
+		}
+		return "0x" + hex.EncodeToString(v)
+	case time.Time:
+		// TIMESTAMP
+		if v.Unix() == 0 && v.Nanosecond() == 0 {
+			return quoteString(zeroTimestamp)
+		}
+		return quoteString(v.UTC().Format("2006-01-02 15:04:05.999999"))
+	case time.Duration:
+		return quoteString(formatDuration(v))
+	case map[string]interface{}, []interface{}:
+		doc, _ := json.Marshal(v)
+		return quoteString(string(doc))
+	case int8, int16, int32, int64, int, uint8, uint16, uint32, uint64, float32, float64:
+		return fmt.Sprint(v)
+	}
+	return quoteString(fmt.Sprint(v))
+}
+
+// FormatRow render every value of decoded row as its canonical string form, keyed by the same column names,
+// e.g. writing to a text log. Integers and floats are decimal, DECIMAL is as-is,
+// temporals are 'YYYY-MM-DD HH:MM:SS[.ffffff]' with the fractional digits of column, blobs are 0x hex,
+// JSON is encoded, NULL is 'NULL'. table could be nil if the column metadata is unknown.
+func FormatRow(row map[string]interface{}, table *BinTableMapEvent) map[string]string {
+	metas := make(map[string]*ColumnType)
+	if table != nil {
+		for i := range table.ColumnMetaDef {
+			metas[table.ColumnName(i)] = &table.ColumnMetaDef[i]
+		}
+	}
+
+	formatted := make(map[string]string, len(row))
+	for name, v := range row {
+		formatted[name] = formatTextValue(v, metas[name])
+	}
+	return formatted
+}
+
+// maxSafeJSONInteger is the largest integer which float64 represents exactly, 2^53-1
+const maxSafeJSONInteger = 1<<53 - 1
+
+// RowJSON encode decoded row as a JSON object keyed by the same column names, e.g. shipping changes to
+// a message queue. The integers beyond 2^53 (e.g. BIGINT UNSIGNED 18446744073709551615) are strings, since
+// most JSON parsers read numbers as float64 and lose precision. Other values are strings of FormatRow,
+// except that NULL is null, and JSON columns are embedded as-is. table could be nil if the column metadata is unknown.
+func RowJSON(row map[string]interface{}, table *BinTableMapEvent) ([]byte, error) {
+	metas := make(map[string]*ColumnType)
+	if table != nil {
+		for i := range table.ColumnMetaDef {
+			metas[table.ColumnName(i)] = &table.ColumnMetaDef[i]
+		}
+	}
+
+	values := make(map[string]interface{}, len(row))
+	for name, v := range row {
+		values[name] = jsonValue(v, metas[name])
+	}
+	return json.Marshal(values)
+}
+
+// jsonValue convert decoded value into the value encoded by RowJSON, meta is nil if unknown
+func jsonValue(v interface{}, meta *ColumnType) interface{} {
+	switch v := v.(type) {
+	case nil, bool, int8, int16, int32, uint8, uint16, uint32, float32, float64:
+		return v
+	case int64:
+		if v > maxSafeJSONInteger || v < -maxSafeJSONInteger {
+			return strconv.FormatInt(v, 10)
+		}
+		return v
+	case uint64:
+		if v > maxSafeJSONInteger {
+			return strconv.FormatUint(v, 10)
+		}
+		return v
+	case map[string]interface{}:
+		object := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			object[key] = jsonValue(value, nil)
+		}
+		return object
+	case []interface{}:
+		array := make([]interface{}, len(v))
+		for i, value := range v {
+			array[i] = jsonValue(value, nil)
+		}
+		return array
+	}
+	return formatTextValue(v, meta)
+}
+
+// formatTextValue format decoded value as string without quoting, meta is nil if unknown
+func formatTextValue(v interface{}, meta *ColumnType) string {
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return v
+	case []byte:
+		if len(v) == 0 {
+			return ""
+		}
+		return "0x" + hex.EncodeToString(v)
+	case time.Time:
+		if meta == nil {
+			return v.Format("2006-01-02 15:04:05.999999")
+		}
+		return v.Format("2006-01-02 15:04:05") + formatFrac(int64(v.Nanosecond()/1000), meta.fsp)
+	case time.Duration:
+		if meta == nil {
+			return formatDuration(v)
+		}
+		frac := v % time.Second
+		if frac < 0 {
+			frac = -frac
+		}
+		return formatDuration(v-v%time.Second) + formatFrac(int64(frac/time.Microsecond), meta.fsp)
+	case map[string]interface{}, []interface{}:
+		doc, _ := json.Marshal(v)
+		return string(doc)
+	}
+	return fmt.Sprint(v)
+}
+
+// formatDuration format TIME value as [-]HH:MM:SS[.ffffff]
+func formatDuration(d time.Duration) string {
+	sign := ""
+	if d < 0 {
+		sign, d = "-", -d
+	}
+	s := fmt.Sprintf("%s%02d:%02d:%02d", sign, d/time.Hour, d%time.Hour/time.Minute, d%time.Minute/time.Second)
+	if usec := d % time.Second / time.Microsecond; usec > 0 {
+		s += fmt.Sprintf(".%06d", usec)
+	}
+	return s
+}
@@ -0,0 +1,86 @@
+package binlog
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// StatementContext is the session state of statement-based QUERY_EVENT, accumulated from the
+// INTVAR_EVENT, RAND_EVENT and USER_VAR_EVENT logged before it, which should be set before
+// replaying the query, e.g. INSERT with auto-increment column, RAND() or @var.
+type StatementContext struct {
+	InsertID     *uint64 // SET INSERT_ID
+	LastInsertID *uint64 // SET LAST_INSERT_ID
+	Rand         *BinRandEvent
+	UserVars     []*BinUserVarEvent
+}
+
+// add will accumulate the INTVAR_EVENT, RAND_EVENT or USER_VAR_EVENT into context
+func (c *StatementContext) add(body BinEventBody) {
+	switch body := body.(type) {
+	case *BinIntvarEvent:
+		value := body.Value
+		switch body.Type {
+		case IntvarInsertID:
+			c.InsertID = &value
+		case IntvarLastInsertID:
+			c.LastInsertID = &value
+		}
+	case *BinRandEvent:
+		c.Rand = body
+	case *BinUserVarEvent:
+		c.UserVars = append(c.UserVars, body)
+	}
+}
+
+// SetStatements return the SET statements to restore the context before replaying query, like mysqlbinlog.
+// String variables are quoted if valid UTF-8, otherwise hex, the charset is left to the caller (BinUserVarEvent.Charset).
+func (c *StatementContext) SetStatements() []string {
+	if c == nil {
+		return nil
+	}
+	var statements []string
+	if c.LastInsertID != nil {
+		statements = append(statements, fmt.Sprintf("SET LAST_INSERT_ID=%d", *c.LastInsertID))
+	}
+	if c.InsertID != nil {
+		statements = append(statements, fmt.Sprintf("SET INSERT_ID=%d", *c.InsertID))
+	}
+	if c.Rand != nil {
+		statements = append(statements, fmt.Sprintf("SET @@RAND_SEED1=%d, @@RAND_SEED2=%d", c.Rand.Seed1, c.Rand.Seed2))
+	}
+	for _, v := range c.UserVars {
+		statements = append(statements, fmt.Sprintf("SET @%s:=%s", quoteIdentifier(v.Name), v.literal()))
+	}
+	return statements
+}
+
+// literal return the SQL literal of user variable
+func (e *BinUserVarEvent) literal() string {
+	if e.IsNull {
+		return "NULL"
+	}
+	switch value := e.Value.(type) {
+	case string:
+		if e.Type == UserVarDecimal {
+			return value
+		}
+		if utf8.ValidString(value) {
+			return quoteString(value)
+		}
+		return formatSQLValue([]byte(value))
+	}
+	return formatSQLValue(e.Value)
+}
+
+// decodeStatementContextEvent decode INTVAR_EVENT, RAND_EVENT or USER_VAR_EVENT
+func decodeStatementContextEvent(eventType EventType, data []byte) (BinEventBody, error) {
+	switch eventType {
+	case IntvarEvent:
+		return decodeIntvarEvent(data)
+	case RandEvent:
+		return decodeRandEvent(data)
+	default:
+		return decodeUserVarEvent(data)
+	}
+}
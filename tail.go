@@ -0,0 +1,149 @@
+package binlog
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultTailInterval is the poll interval of Tail if not set
+const defaultTailInterval = time.Second
+
+// Tail will walk the binary log like WalkEvent, but never stops at the end, like mysqlbinlog --stop-never.
+// At the end of binary log, it polls every interval for the appended events, or the next-numbered
+// binary log (e.g. mysql-bin.000005 after mysql-bin.000004) which is created by rotation or server restart,
+// even if there is no ROTATE_EVENT in the binary log. It returns ctx.Err() when ctx is done,
+// or nil when f stops the walk. The binary logs opened by Tail are closed on return.
+func (decoder *BinFileDecoder) Tail(ctx context.Context, interval time.Duration, f func(event *BinEvent) (isContinue bool, err error)) error {
+	if decoder.file == nil {
+		return errNotFile
+	}
+	if interval <= 0 {
+		interval = defaultTailInterval
+	}
+	walk := func(event *BinEvent) (isContinue bool, err error) {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		return f(event)
+	}
+
+	current := decoder
+	defer func() {
+		if current != decoder {
+			current.file.Close()
+		}
+	}()
+	for {
+		current.endReason = EndUnknown
+		err := current.walkEvent(current.decodeSession, walk)
+		// the binary logs followed by ROTATE_EVENT
+		for current.next != nil {
+			current = current.next
+		}
+		if err != nil {
+			return err
+		}
+		if current.endReason == EndUnknown {
+			// stopped by f, EndPos, EndTime or the limits
+			return nil
+		}
+
+		next, err := current.waitNext(ctx, interval)
+		if err != nil {
+			return err
+		}
+		if next != nil {
+			current = next
+		}
+	}
+}
+
+// waitNext wait until the binary log grows, or the next-numbered binary log is created.
+// return the decoder of next binary log, or nil if the binary log grows
+func (decoder *BinFileDecoder) waitNext(ctx context.Context, interval time.Duration) (*BinFileDecoder, error) {
+	session := decoder.decodeSession
+	nextPath := nextBinlogPath(decoder.Path)
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+
+	for {
+		// no more events are appended after ROTATE_EVENT or STOP_EVENT
+		if !decoder.endReason.Clean() {
+			grown, err := decoder.grown()
+			if err != nil {
+				return nil, err
+			}
+			if grown {
+				// the incomplete event is read again from its start
+				if _, err := session.file.Seek(session.offset, io.SeekStart); err != nil {
+					return nil, err
+				}
+				session.buf.Reset(session.file)
+				return nil, nil
+			}
+		}
+
+		if nextPath != "" {
+			if _, err := os.Stat(nextPath); err == nil {
+				next, err := decoder.openNext(session, nextPath)
+				if err == nil {
+					session.file.Close()
+					decoder.logger().Debugf("tail to %s", nextPath)
+					return next, nil
+				}
+				// the header of the new binary log may be not written yet
+				if err != io.EOF && err != io.ErrUnexpectedEOF {
+					return nil, err
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timer.C:
+			timer.Reset(interval)
+		}
+	}
+}
+
+// grown return bool of if the binary log has more bytes than read
+func (decoder *BinFileDecoder) grown() (bool, error) {
+	info, err := decoder.file.Stat()
+	if err != nil {
+		return false, err
+	}
+	return info.Size() > decoder.offset, nil
+}
+
+// nextBinlogPath return the path of next-numbered binary log, e.g. mysql-bin.000005 of mysql-bin.000004,
+// empty if the binary log is not numbered
+func nextBinlogPath(path string) string {
+	base := filepath.Base(path)
+	number, ok := binlogIndex(base)
+	if !ok {
+		return ""
+	}
+	dot := strings.LastIndex(base, ".")
+	width := len(base) - dot - 1
+	return filepath.Join(filepath.Dir(path), fmt.Sprintf("%s.%0*d", base[:dot], width, number+1))
+}
+
+// binlogIndex return the number of binary log name, e.g. 4 of mysql-bin.000004
+func binlogIndex(name string) (uint64, bool) {
+	dot := strings.LastIndex(name, ".")
+	if dot < 0 || dot == len(name)-1 {
+		return 0, false
+	}
+	number, err := strconv.ParseUint(name[dot+1:], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return number, true
+}
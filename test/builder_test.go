@@ -0,0 +1,211 @@
+package test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/obgnail/binlog-parser"
+)
+
+// postHeaderLengths is the event type header lengths of mysql 5.7
+var postHeaderLengths = []byte{
+	56, 13, 0, 8, 0, 18, 0, 4, 4, 4, 4, 18, 0, 0, 95, 0, 4, 26, 8, 0,
+	0, 0, 8, 8, 8, 2, 0, 0, 0, 10, 10, 10, 42, 42, 0, 18, 52, 0,
+}
+
+// binlogBuilder build binary log bytes for tests
+type binlogBuilder struct {
+	buf      bytes.Buffer
+	checksum byte
+	pos      uint32
+	serverID uint32 // server id of the next appended events
+	flag     uint16 // flags of the next appended events
+	rowsFlag uint16 // flags of the next appended ROWS_EVENTs
+}
+
+func newBinlogBuilder(checksum byte) *binlogBuilder {
+	b := &binlogBuilder{checksum: checksum, serverID: 1}
+	b.buf.Write([]byte{254, 98, 105, 110})
+	b.pos = 4
+	b.fmtDesc(checksum)
+	return b
+}
+
+// fmtDesc append a FORMAT_DESCRIPTION_EVENT
+func (b *binlogBuilder) fmtDesc(checksum byte) {
+	body := make([]byte, 2+50+4+1)
+	binary.LittleEndian.PutUint16(body, 4)
+	copy(body[2:], "5.7.23-log")
+	body[56] = 19
+	body = append(body, postHeaderLengths...)
+	body = append(body, checksum)
+
+	// FORMAT_DESCRIPTION_EVENT always has a checksum value field
+	b.checksum = binlog.BinlogChecksumAlgCRC32
+	b.event(binlog.FormatDescriptionEvent, body)
+	b.checksum = checksum
+}
+
+// serverVersion rewrite the server version of FORMAT_DESCRIPTION_EVENT
+func (b *binlogBuilder) serverVersion(version string) {
+	fde := b.buf.Bytes()[4:]
+	fde = fde[:binary.LittleEndian.Uint32(fde[9:])]
+	copy(fde[19+2:19+52], make([]byte, 50))
+	copy(fde[19+2:], version)
+	binary.LittleEndian.PutUint32(fde[len(fde)-4:], crc32.ChecksumIEEE(fde[:len(fde)-4]))
+}
+
+// event append an event with body
+func (b *binlogBuilder) event(eventType binlog.EventType, body []byte) {
+	size := 19 + len(body)
+	if b.checksum == binlog.BinlogChecksumAlgCRC32 {
+		size += 4
+	}
+	b.pos += uint32(size)
+
+	header := make([]byte, 19)
+	binary.LittleEndian.PutUint32(header, 1537611870)
+	header[4] = byte(eventType)
+	binary.LittleEndian.PutUint32(header[5:], b.serverID)
+	binary.LittleEndian.PutUint32(header[9:], uint32(size))
+	binary.LittleEndian.PutUint32(header[13:], b.pos)
+	binary.LittleEndian.PutUint16(header[17:], b.flag)
+
+	data := append(header, body...)
+	if b.checksum == binlog.BinlogChecksumAlgCRC32 {
+		data = appendUint32(data, crc32.ChecksumIEEE(data))
+	}
+	b.buf.Write(data)
+}
+
+// tableMap append a TABLE_MAP_EVENT
+func (b *binlogBuilder) tableMap(tableID uint64, schema, table string, types []byte, meta []byte, nullable []byte) {
+	body := make([]byte, 8)
+	putTableID(body, tableID)
+	body = append(body, byte(len(schema)))
+	body = append(append(body, schema...), 0)
+	body = append(body, byte(len(table)))
+	body = append(append(body, table...), 0)
+	body = appendLengthEncodedInt(body, uint64(len(types)))
+	body = append(body, types...)
+	body = appendLengthEncodedInt(body, uint64(len(meta)))
+	body = append(body, meta...)
+	body = append(body, nullable...)
+	b.event(binlog.TableMapEvent, body)
+}
+
+// rows append a ROWS_EVENTv2, bitmap2 is only used by UPDATE_ROWS_EVENTv2
+func (b *binlogBuilder) rows(eventType binlog.EventType, tableID uint64, columnCount int, bitmap1, bitmap2 []byte, rows []byte) {
+	body := make([]byte, 8)
+	putTableID(body, tableID)
+	binary.LittleEndian.PutUint16(body[6:], b.rowsFlag)
+	// extra data length
+	body = append(body, 2, 0)
+	body = appendLengthEncodedInt(body, uint64(columnCount))
+	body = append(body, bitmap1...)
+	if eventType == binlog.UpdateRowsEventV2 {
+		body = append(body, bitmap2...)
+	}
+	body = append(body, rows...)
+	b.event(eventType, body)
+}
+
+// file write binary log into a temp file and return the path
+func (b *binlogBuilder) file(t *testing.T) string {
+	return b.writeFile(t, filepath.Join(t.TempDir(), "mysql-bin.000001"))
+}
+
+// writeFile write binary log into path
+func (b *binlogBuilder) writeFile(t *testing.T, path string) string {
+	if err := os.WriteFile(path, b.buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// walk decode all events of binary log
+func (b *binlogBuilder) walk(t *testing.T, options ...*binlog.BinReaderOption) []*binlog.BinEvent {
+	decoder, err := binlog.NewBinFileDecoder(b.file(t), options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var events []*binlog.BinEvent
+	err = decoder.WalkEvent(func(event *binlog.BinEvent) (isContinue bool, err error) {
+		events = append(events, event)
+		return true, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return events
+}
+
+// decodeEvents decode all events of binary log from memory by DecodeEvent, without writing a file
+func (b *binlogBuilder) decodeEvents(t *testing.T, options ...*binlog.BinReaderOption) []*binlog.BinEvent {
+	decoder, err := binlog.NewBinReaderDecoder(bytes.NewReader(b.buf.Bytes()), options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var events []*binlog.BinEvent
+	for {
+		event, err := decoder.DecodeEvent()
+		if err == io.EOF {
+			return events
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, event)
+	}
+}
+
+// queryBody return the body of QUERY_EVENT
+func queryBody(schema, query string, statusVars ...byte) []byte {
+	body := make([]byte, 13)
+	body[8] = byte(len(schema))
+	binary.LittleEndian.PutUint16(body[11:], uint16(len(statusVars)))
+	body = append(body, statusVars...)
+	body = append(append(body, schema...), 0)
+	return append(body, query...)
+}
+
+func putTableID(data []byte, tableID uint64) {
+	for i := 0; i < 6; i++ {
+		data[i] = byte(tableID >> (8 * i))
+	}
+}
+
+func appendLengthEncodedInt(data []byte, n uint64) []byte {
+	switch {
+	case n < 251:
+		return append(data, byte(n))
+	case n < 1<<16:
+		return append(data, 0xfc, byte(n), byte(n>>8))
+	case n < 1<<24:
+		return append(data, 0xfd, byte(n), byte(n>>8), byte(n>>16))
+	}
+	data = append(data, 0xfe)
+	return appendUint32(appendUint32(data, uint32(n)), uint32(n>>32))
+}
+
+func appendUint32(data []byte, n uint32) []byte {
+	return append(data, byte(n), byte(n>>8), byte(n>>16), byte(n>>24))
+}
+
+// rowsEvents return the ROWS_EVENTs of events
+func rowsEvents(events []*binlog.BinEvent) []*binlog.BinRowsEvent {
+	var rows []*binlog.BinRowsEvent
+	for _, event := range events {
+		if e, ok := event.Body.(*binlog.BinRowsEvent); ok {
+			rows = append(rows, e)
+		}
+	}
+	return rows
+}
@@ -0,0 +1,94 @@
+package test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/obgnail/binlog-parser"
+)
+
+func TestParseDDL(t *testing.T) {
+	table := func(schema, name string) binlog.DDLTable { return binlog.DDLTable{Schema: schema, Table: name} }
+	cases := []struct {
+		query     string
+		operation binlog.DDLOperation
+		tables    []binlog.DDLTable
+		newTables []binlog.DDLTable
+	}{
+		{"CREATE TABLE IF NOT EXISTS `user` (id INT, `rename` INT)", binlog.DDLCreateTable,
+			[]binlog.DDLTable{table("test", "user")}, nil},
+		{"/* app */ drop table if exists a, `other`.`b c`", binlog.DDLDropTable,
+			[]binlog.DDLTable{table("test", "a"), table("other", "b c")}, nil},
+		{"ALTER TABLE user ADD COLUMN email VARCHAR(64), RENAME COLUMN name TO nick", binlog.DDLAlterTable,
+			[]binlog.DDLTable{table("test", "user")}, nil},
+		{"ALTER TABLE user ADD INDEX idx (name), RENAME AS archive.user_old", binlog.DDLAlterTable,
+			[]binlog.DDLTable{table("test", "user")}, []binlog.DDLTable{table("archive", "user_old")}},
+		{"RENAME TABLE a TO b, other.c TO other.d", binlog.DDLRenameTable,
+			[]binlog.DDLTable{table("test", "a"), table("other", "c")}, []binlog.DDLTable{table("test", "b"), table("other", "d")}},
+		{"TRUNCATE user", binlog.DDLTruncateTable, []binlog.DDLTable{table("test", "user")}, nil},
+		{"CREATE UNIQUE INDEX idx ON user (name)", binlog.DDLCreateIndex, []binlog.DDLTable{table("test", "user")}, nil},
+		{"DROP DATABASE IF EXISTS `shop`", binlog.DDLDropDatabase, []binlog.DDLTable{{Schema: "shop"}}, nil},
+	}
+	for _, c := range cases {
+		change, ok := binlog.ParseDDL("test", c.query)
+		if !ok {
+			t.Errorf("%s is not parsed", c.query)
+			continue
+		}
+		if change.Operation != c.operation || !reflect.DeepEqual(change.Tables, c.tables) || !reflect.DeepEqual(change.NewTables, c.newTables) {
+			t.Errorf("%s got %s %v => %v", c.query, change.Operation, change.Tables, change.NewTables)
+		}
+	}
+
+	for _, query := range []string{"BEGIN", "INSERT INTO user VALUES (1)", "CREATE VIEW v AS SELECT 1"} {
+		if change, ok := binlog.ParseDDL("test", query); ok {
+			t.Errorf("%s got %+v, want not parsed", query, change)
+		}
+	}
+}
+
+func TestStopBeforeDDL(t *testing.T) {
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	b.event(binlog.QueryEvent, queryBody("test", "BEGIN"))
+	userTableMap(b)
+	b.rows(binlog.WriteRowsEventV2, 100, 3, []byte{0x07}, nil, []byte{0x04, 1, 0, 0, 0, 1, 'a'})
+	b.event(binlog.XIDEvent, make([]byte, 8))
+	ddl := int64(b.buf.Len())
+	b.event(binlog.QueryEvent, queryBody("test", "/* schema change */ ALTER TABLE user ADD COLUMN email VARCHAR(20)"))
+	b.event(binlog.QueryEvent, queryBody("test", "BEGIN"))
+	b.event(binlog.XIDEvent, make([]byte, 8))
+
+	events := b.walk(t, &binlog.BinReaderOption{StopFunc: binlog.StopBeforeDDL})
+	if len(events) != 5 || events[4].Header.EventType != binlog.XIDEvent {
+		t.Fatalf("got %d events before DDL, want 5", len(events))
+	}
+
+	// resume from the DDL, stop at the next DDL
+	option := &binlog.BinReaderOption{StopFunc: func(event *binlog.BinEvent) bool {
+		return event.Header.LogPos-event.Header.EventSize > ddl && binlog.StopBeforeDDL(event)
+	}}
+	decoder, err := binlog.NewBinFileDecoder(b.file(t), option)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = decoder.DecodeEvent(); err != nil {
+		t.Fatal(err)
+	}
+	if err = decoder.SeekTo(ddl); err != nil {
+		t.Fatal(err)
+	}
+	events = events[:0]
+	err = decoder.WalkEvent(func(event *binlog.BinEvent) (isContinue bool, err error) {
+		events = append(events, event)
+		return true, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("got %d events after DDL, want 3", len(events))
+	}
+	if query := events[0].Body.(*binlog.BinQueryEvent); !binlog.IsDDL(query.Query) {
+		t.Errorf("got first event %s after resume", query.Query)
+	}
+}
@@ -1,7 +1,16 @@
 package test
 
 import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
 	"runtime"
 	"strings"
 	"testing"
@@ -69,3 +78,1071 @@ func TestBinLog(t *testing.T) {
 		panic(err)
 	}
 }
+
+func TestIgnoreServerIDs(t *testing.T) {
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	for xid, serverID := range []uint32{1, 2, 1, 3, 2} {
+		b.serverID = serverID
+		b.event(binlog.XIDEvent, []byte{byte(xid), 0, 0, 0, 0, 0, 0, 0})
+	}
+
+	var xids []uint64
+	for _, event := range b.walk(t, &binlog.BinReaderOption{IgnoreServerIDs: []int64{2, 3}}) {
+		if event.Header.ServerID != 1 {
+			t.Errorf("got event from ignored server: %s", event.Header)
+		}
+		if xid, ok := event.Body.(*binlog.BinXIDEvent); ok {
+			xids = append(xids, xid.XID)
+		}
+	}
+	if !reflect.DeepEqual(xids, []uint64{0, 2}) {
+		t.Errorf("got xids %v, want [0 2]", xids)
+	}
+}
+
+func TestDecodeHooks(t *testing.T) {
+	before := make(map[binlog.EventType]int)
+	elapsed := make(map[binlog.EventType]time.Duration)
+	option := &binlog.BinReaderOption{
+		BeforeDecode: func(header *binlog.BinEventHeader) {
+			before[header.EventType]++
+		},
+		AfterDecode: func(header *binlog.BinEventHeader, d time.Duration) {
+			elapsed[header.EventType] += d
+		},
+	}
+	decoder, err := binlog.NewBinFileDecoder("./testdata/mysql-bin.000004", option)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count := make(map[binlog.EventType]int)
+	err = decoder.WalkEvent(func(event *binlog.BinEvent) (isContinue bool, err error) {
+		count[event.Header.EventType]++
+		return true, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for eventType, n := range count {
+		if before[eventType] != n {
+			t.Errorf("%s: BeforeDecode called %d times, want %d", binlog.EventType2Str[eventType], before[eventType], n)
+		}
+		if _, ok := elapsed[eventType]; !ok {
+			t.Errorf("%s: AfterDecode not called", binlog.EventType2Str[eventType])
+		}
+	}
+}
+
+func TestStopAtTransactionEnd(t *testing.T) {
+	// EndPos falls inside the first transaction of employees
+	option := &binlog.BinReaderOption{EndPos: 9014, StopAtTransactionEnd: true}
+	decoder, err := binlog.NewBinFileDecoder("./testdata/mysql-bin.000004", option)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var last *binlog.BinEvent
+	err = decoder.WalkEvent(func(event *binlog.BinEvent) (isContinue bool, err error) {
+		last = event
+		return true, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if last == nil || last.Header.EventType != binlog.XIDEvent {
+		t.Fatalf("got last event %v, want XID_EVENT", last.Header)
+	}
+	if last.Header.LogPos <= option.EndPos {
+		t.Errorf("got last event end pos %d, want > %d", last.Header.LogPos, option.EndPos)
+	}
+}
+
+func TestMultipleFormatDescription(t *testing.T) {
+	// concatenate a binlog with CRC32 checksum and a binlog without checksum
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	userTableMap(b)
+	b.rows(binlog.WriteRowsEventV2, 100, 3, []byte{0x07}, nil, []byte{0x00, 1, 0, 0, 0, 1, 'a', 2, 0, 0, 0})
+
+	b.fmtDesc(binlog.BinlogChecksumAlgOff)
+	userTableMap(b)
+	b.rows(binlog.WriteRowsEventV2, 100, 3, []byte{0x07}, nil, []byte{0x04, 2, 0, 0, 0, 1, 'b'})
+
+	events := b.walk(t)
+	if len(events) != 6 {
+		t.Fatalf("got %d events, want 6", len(events))
+	}
+
+	desc := events[3].Body.(*binlog.BinFmtDescEvent)
+	if desc.ChecksumAlgorithm != binlog.BinlogChecksumAlgOff {
+		t.Errorf("got checksum algorithm %d, want %d", desc.ChecksumAlgorithm, binlog.BinlogChecksumAlgOff)
+	}
+	if events[5].ChecksumVal != nil {
+		t.Errorf("event after checksum off got checksum %v", events[5].ChecksumVal)
+	}
+
+	rows := rowsEvents(events)
+	if len(rows) != 2 || rows[0].Rows[0]["@2"] != "a" || rows[1].Rows[0]["@2"] != "b" || rows[1].Rows[0]["@3"] != nil {
+		t.Fatalf("got rows %v, %v", rows[0].Rows, rows[1].Rows)
+	}
+}
+
+func TestUnparseableServerVersion(t *testing.T) {
+	for _, version := range []string{"", "8", "garbage"} {
+		b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+		b.serverVersion(version)
+		data := b.buf.Bytes()[4:]
+		// must not panic, the version without checksum support is a pre-5.6.2 server
+		if _, err := binlog.DecodeEventBytes(data[:binary.LittleEndian.Uint32(data[9:])], nil, nil); err != nil {
+			t.Logf("server version %q: %v", version, err)
+		}
+	}
+}
+
+func TestRegisterChecksumValidator(t *testing.T) {
+	const alg byte = 0x7d
+	data, checksum := []byte("event"), []byte{0xaa}
+	if err := binlog.VerifyChecksum(alg, checksum, data); err == nil || !strings.Contains(err.Error(), "unsupported") {
+		t.Errorf("got error %v of unregistered algorithm, want unsupported", err)
+	}
+	if !binlog.ChecksumValidate(alg, checksum, data) {
+		t.Errorf("got checksum of unregistered algorithm invalid")
+	}
+
+	binlog.RegisterChecksumValidator(alg, len(checksum), func(expectedChecksum []byte, data []byte) bool {
+		return bytes.Equal(expectedChecksum, checksum)
+	})
+	t.Cleanup(func() { binlog.RegisterChecksumValidator(alg, 0, nil) })
+	if err := binlog.VerifyChecksum(alg, checksum, data); err != nil {
+		t.Errorf("got error %v of registered validator", err)
+	}
+	if err := binlog.VerifyChecksum(alg, []byte{0xbb}, data); !errors.Is(err, binlog.ErrChecksumFailed) {
+		t.Errorf("got error %v, want ErrChecksumFailed", err)
+	}
+	if binlog.ChecksumValidate(alg, []byte{0xbb}, data) {
+		t.Errorf("got mismatched checksum valid")
+	}
+}
+
+func TestChecksumLength(t *testing.T) {
+	for alg, want := range map[byte]int{binlog.BinlogChecksumAlgCRC32: 4, binlog.BinlogChecksumAlgOff: 0} {
+		if length, ok := binlog.ChecksumLength(alg); !ok || length != want {
+			t.Errorf("got checksum length %d, %v of algorithm %d, want %d", length, ok, alg, want)
+		}
+	}
+
+	// a future algorithm with 8 bytes trailer
+	const alg byte = 0x7f
+	if _, ok := binlog.ChecksumLength(alg); ok {
+		t.Errorf("got checksum length of unregistered algorithm %d", alg)
+	}
+	unknown := newBinlogBuilder(alg)
+	unknown.event(binlog.XIDEvent, make([]byte, 12))
+	decoder, err := binlog.NewBinFileDecoder(unknown.file(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = decoder.WalkEvent(func(event *binlog.BinEvent) (isContinue bool, err error) { return true, nil })
+	if err == nil || !strings.Contains(err.Error(), "unsupported binlog checksum algorithm") {
+		t.Errorf("got error %v of unregistered algorithm", err)
+	}
+
+	trailer := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	t.Cleanup(func() { binlog.RegisterChecksumValidator(alg, 0, nil) })
+	binlog.RegisterChecksumValidator(alg, len(trailer), func(expectedChecksum []byte, data []byte) bool {
+		// FORMAT_DESCRIPTION_EVENT reserves 4 bytes, the builder writes CRC32
+		if len(expectedChecksum) == 4 {
+			return binary.LittleEndian.Uint32(expectedChecksum) == crc32.ChecksumIEEE(data)
+		}
+		return bytes.Equal(expectedChecksum, trailer)
+	})
+
+	b := newBinlogBuilder(alg)
+	b.event(binlog.QueryEvent, append(queryBody("test", "CREATE TABLE t (id int)"), trailer...))
+	events := b.walk(t)
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+	query := events[1].Body.(*binlog.BinQueryEvent)
+	if query.Query != "CREATE TABLE t (id int)" || !bytes.Equal(events[1].ChecksumVal, trailer) || events[1].ChecksumType != alg {
+		t.Errorf("got query %q, checksum %x of %d", query.Query, events[1].ChecksumVal, events[1].ChecksumType)
+	}
+}
+
+type countMetrics struct {
+	events      map[binlog.EventType]int
+	bytes       int64
+	skipped     int64
+	unsupported int
+	pos         int64
+}
+
+func (m *countMetrics) EventDecoded(eventType binlog.EventType, size int64) {
+	m.events[eventType]++
+	m.bytes += size
+}
+func (m *countMetrics) EventSkipped(eventType binlog.EventType, size int64) { m.skipped += size }
+func (m *countMetrics) ChecksumFailed(eventType binlog.EventType)           {}
+func (m *countMetrics) UnsupportedEvent(eventType binlog.EventType)         { m.unsupported++ }
+func (m *countMetrics) Progress(pos int64, lag time.Duration)               { m.pos = pos }
+
+func TestMetrics(t *testing.T) {
+	metrics := &countMetrics{events: make(map[binlog.EventType]int)}
+	decoder, err := binlog.NewBinFileDecoder("./testdata/mysql-bin.000004", &binlog.BinReaderOption{Metrics: metrics})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = decoder.WalkEvent(func(event *binlog.BinEvent) (isContinue bool, err error) { return true, nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	f, _ := decoder.BinFile.Stat()
+	if metrics.bytes+4 != f.Size() || metrics.pos != f.Size() {
+		t.Errorf("got %d bytes decoded and position %d, file size %d", metrics.bytes, metrics.pos, f.Size())
+	}
+	if metrics.events[binlog.XIDEvent] != 168 {
+		t.Errorf("got %d XID_EVENT, want 168", metrics.events[binlog.XIDEvent])
+	}
+	if metrics.unsupported != 0 {
+		t.Errorf("got %d unsupported events, want 0", metrics.unsupported)
+	}
+
+	// the filtered bodies are counted as skipped
+	metrics = &countMetrics{events: make(map[binlog.EventType]int)}
+	option := &binlog.BinReaderOption{Metrics: metrics, EventTypeFilter: func(eventType binlog.EventType) bool { return eventType == binlog.XIDEvent }}
+	if decoder, err = binlog.NewBinFileDecoder("./testdata/mysql-bin.000004", option); err != nil {
+		t.Fatal(err)
+	}
+	if err = decoder.WalkEvent(func(event *binlog.BinEvent) (isContinue bool, err error) { return true, nil }); err != nil {
+		t.Fatal(err)
+	}
+	if metrics.skipped == 0 || metrics.bytes+metrics.skipped+4 != f.Size() || metrics.pos != f.Size() {
+		t.Errorf("got %d bytes decoded, %d bytes skipped and position %d, file size %d", metrics.bytes, metrics.skipped, metrics.pos, f.Size())
+	}
+}
+
+func TestScanHeaders(t *testing.T) {
+	decoder, err := binlog.NewBinFileDecoder("./testdata/mysql-bin.000004")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counts := make(map[binlog.EventType]int)
+	var lastPos int64
+	err = decoder.ScanHeaders(func(header *binlog.BinEventHeader) bool {
+		counts[header.EventType]++
+		lastPos = header.LogPos
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, _ := decoder.BinFile.Stat()
+	if lastPos != f.Size() {
+		t.Errorf("got last position %d, file size %d", lastPos, f.Size())
+	}
+	if counts[binlog.WriteRowsEventV2] != 8138 || counts[binlog.TableMapEvent] != 168 || counts[binlog.FormatDescriptionEvent] != 1 {
+		t.Errorf("got event counts %v", counts)
+	}
+}
+
+func TestReadBinlogIndex(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "mysql-bin.index")
+	if err := os.WriteFile(path, []byte("./mysql-bin.000009\r\n./mysql-bin.000010\n\n/var/lib/mysql/mysql-bin.000011\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	paths, err := binlog.ReadBinlogIndex(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		filepath.Join(dir, "mysql-bin.000009"),
+		filepath.Join(dir, "mysql-bin.000010"),
+		"/var/lib/mysql/mysql-bin.000011",
+	}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("got paths %v, want %v", paths, want)
+	}
+}
+
+func TestDecodeEventInChunks(t *testing.T) {
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	// Q_MICROSECONDS 654321 of BEGIN
+	b.event(binlog.QueryEvent, queryBody("test", "BEGIN", binlog.QMicroseconds, 0xf1, 0xfb, 0x09))
+	userTableMap(b)
+	b.event(binlog.XIDEvent, make([]byte, 8)) // filtered
+	b.rows(binlog.WriteRowsEventV2, 100, 3, []byte{0x07}, nil, []byte{0x04, 1, 0, 0, 0, 1, 'a'})
+	b.event(binlog.QueryEvent, queryBody("test", "COMMIT"))
+
+	decoder, err := binlog.NewBinFileDecoder(b.file(t), &binlog.BinReaderOption{
+		EventTypeFilter: func(eventType binlog.EventType) bool { return eventType != binlog.XIDEvent },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := func(want binlog.EventType) *binlog.BinEvent {
+		event, err := decoder.DecodeEvent()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if event.Header.EventType != want {
+			t.Fatalf("got %s, want %s", event.Header.EventType, want)
+		}
+		return event
+	}
+
+	// the first chunk ends after TABLE_MAP_EVENT
+	next(binlog.FormatDescriptionEvent)
+	next(binlog.QueryEvent)
+	next(binlog.TableMapEvent)
+
+	// other work between the chunks, e.g. an independent walk
+	if err = decoder.Walk(func(event *binlog.BinEvent) (isContinue bool, err error) { return true, nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	// the table map and the transaction time are kept, the filtered XID_EVENT is not returned
+	event := next(binlog.WriteRowsEventV2)
+	if rows := event.Body.(*binlog.BinRowsEvent); rows.TableMap() == nil || len(rows.Rows) != 1 {
+		t.Errorf("got rows event %+v", rows)
+	}
+	if got := event.Time(); !got.Equal(time.Unix(1537611870, 654321000)) {
+		t.Errorf("got time %s of rows event", got)
+	}
+	next(binlog.QueryEvent)
+	for i := 0; i < 2; i++ {
+		if _, err = decoder.DecodeEvent(); err != io.EOF {
+			t.Errorf("got error %v at the end, want io.EOF", err)
+		}
+	}
+}
+
+func TestConcurrentWalk(t *testing.T) {
+	decoder, err := binlog.NewBinFileDecoder("./testdata/mysql-bin.000004")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the default session is halfway, independent walks start from the beginning
+	for i := 0; i < 100; i++ {
+		if _, err := decoder.DecodeEvent(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	counts := make([]int, 2)
+	errs := make(chan error, len(counts))
+	for i := range counts {
+		go func(i int) {
+			errs <- decoder.Walk(func(event *binlog.BinEvent) (isContinue bool, err error) {
+				if _, ok := event.Body.(*binlog.BinRowsEvent); ok {
+					counts[i]++
+				}
+				return true, nil
+			})
+		}(i)
+	}
+	for range counts {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, count := range counts {
+		if count != 8138 {
+			t.Errorf("walk %d got %d rows events, want 8138", i, count)
+		}
+	}
+}
+
+func TestMaxEventSize(t *testing.T) {
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	b.event(binlog.QueryEvent, queryBody("test", strings.Repeat("x", 100)))
+
+	decoder, err := binlog.NewBinFileDecoder(b.file(t), &binlog.BinReaderOption{MaxEventSize: 128})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = decoder.WalkEvent(func(event *binlog.BinEvent) (isContinue bool, err error) { return true, nil })
+	if err == nil || !strings.Contains(err.Error(), "max event size 128") {
+		t.Errorf("got error %v, want max event size exceeded", err)
+	}
+}
+
+func TestStream(t *testing.T) {
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	for i := 0; i < 10; i++ {
+		b.event(binlog.XIDEvent, make([]byte, 8))
+	}
+	path := b.file(t)
+
+	decoder, err := binlog.NewBinFileDecoder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, errs := decoder.Stream(context.Background())
+	count := 0
+	for range events {
+		count++
+	}
+	if err := <-errs; err != nil || count != 11 {
+		t.Errorf("got %d events and error %v, want 11 events", count, err)
+	}
+
+	// stop receiving and cancel
+	decoder, err = binlog.NewBinFileDecoder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	events, errs = decoder.Stream(ctx)
+	<-events
+	cancel()
+	if err := <-errs; !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want context.Canceled", err)
+	}
+	for range events {
+	}
+}
+
+func TestSeekWithFormatDescription(t *testing.T) {
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	userTableMap(b)
+	b.rows(binlog.WriteRowsEventV2, 100, 3, []byte{0x07}, nil, []byte{0x00, 1, 0, 0, 0, 1, 'a', 2, 0, 0, 0})
+	path := b.file(t)
+
+	// the format description is taken from another decoder
+	events := b.walk(t)
+	desc := events[0].Body.(*binlog.BinFmtDescEvent)
+
+	decoder, err := binlog.NewBinFileDecoder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoder.SetFormatDescription(desc)
+	if err = decoder.SeekTo(events[1].Header.LogPos - events[1].Header.EventSize); err != nil {
+		t.Fatal(err)
+	}
+
+	var types []binlog.EventType
+	err = decoder.WalkEvent(func(event *binlog.BinEvent) (isContinue bool, err error) {
+		types = append(types, event.Header.EventType)
+		return true, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(types, []binlog.EventType{binlog.TableMapEvent, binlog.WriteRowsEventV2}) {
+		t.Errorf("got event types %v", types)
+	}
+}
+
+func TestEndPosition(t *testing.T) {
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	b.event(binlog.XIDEvent, []byte{1, 0, 0, 0, 0, 0, 0, 0})
+	second := b.buf.Len()
+	b.event(binlog.XIDEvent, []byte{2, 0, 0, 0, 0, 0, 0, 0})
+	b.event(binlog.XIDEvent, []byte{3, 0, 0, 0, 0, 0, 0, 0})
+	// the log position of the second XID_EVENT is not the file offset, e.g. relay log
+	binary.LittleEndian.PutUint32(b.buf.Bytes()[second+13:], 12345)
+	path := b.file(t)
+
+	option := &binlog.BinReaderOption{SkipChecksumVerify: true}
+	decoder, err := binlog.NewBinFileDecoder(path, option)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ends []int64
+	var desc *binlog.BinFmtDescEvent
+	err = decoder.WalkEvent(func(event *binlog.BinEvent) (isContinue bool, err error) {
+		if d, ok := event.Body.(*binlog.BinFmtDescEvent); ok {
+			desc = d
+		}
+		ends = append(ends, event.EndPosition())
+		return true, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// FORMAT_DESCRIPTION_EVENT and 3 XID_EVENTs of 31 bytes
+	if want := []int64{int64(second - 31), int64(second), int64(second + 31), int64(b.pos)}; !reflect.DeepEqual(ends, want) {
+		t.Fatalf("got end positions %v, want %v", ends, want)
+	}
+
+	// resume after the first XID_EVENT
+	decoder, err = binlog.NewBinFileDecoder(path, option)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoder.SetFormatDescription(desc)
+	if err = decoder.SeekTo(ends[1]); err != nil {
+		t.Fatal(err)
+	}
+	var xids []uint64
+	err = decoder.WalkEvent(func(event *binlog.BinEvent) (isContinue bool, err error) {
+		xids = append(xids, event.Body.(*binlog.BinXIDEvent).XID)
+		return true, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(xids, []uint64{2, 3}) {
+		t.Errorf("got xids %v after resume", xids)
+	}
+}
+
+func TestRecoverMode(t *testing.T) {
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	b.event(binlog.XIDEvent, []byte{1, 0, 0, 0, 0, 0, 0, 0})
+	corrupted := b.buf.Len()
+	b.event(binlog.XIDEvent, []byte{2, 0, 0, 0, 0, 0, 0, 0})
+	b.event(binlog.XIDEvent, []byte{3, 0, 0, 0, 0, 0, 0, 0})
+
+	// corrupt the event type and size of the second XID_EVENT
+	data := b.buf.Bytes()
+	copy(data[corrupted+4:], []byte{0xee, 0xee, 0xee, 0xee, 0xee, 0xee, 0xee, 0xee, 0xee})
+	path := b.file(t)
+
+	decoder, err := binlog.NewBinFileDecoder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = decoder.WalkEvent(func(event *binlog.BinEvent) (isContinue bool, err error) { return true, nil }); err == nil {
+		t.Errorf("got no error without RecoverMode")
+	}
+
+	decoder, err = binlog.NewBinFileDecoder(path, &binlog.BinReaderOption{RecoverMode: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var xids []uint64
+	err = decoder.WalkEvent(func(event *binlog.BinEvent) (isContinue bool, err error) {
+		if xid, ok := event.Body.(*binlog.BinXIDEvent); ok {
+			xids = append(xids, xid.XID)
+		}
+		return true, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(xids, []uint64{1, 3}) || decoder.SkippedBytes() != 19+8+4 {
+		t.Errorf("got xids %v, skipped %d bytes", xids, decoder.SkippedBytes())
+	}
+}
+
+func TestRecoverMalformedBody(t *testing.T) {
+	// the checksum is valid, but the QUERY_EVENT body is truncated
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	b.event(binlog.XIDEvent, []byte{1, 0, 0, 0, 0, 0, 0, 0})
+	b.event(binlog.QueryEvent, []byte{1, 0, 0, 0, 0})
+	b.event(binlog.XIDEvent, []byte{2, 0, 0, 0, 0, 0, 0, 0})
+	path := b.file(t)
+
+	decoder, err := binlog.NewBinFileDecoder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = decoder.WalkEvent(func(event *binlog.BinEvent) (isContinue bool, err error) { return true, nil }); err == nil {
+		t.Errorf("got no error of truncated QUERY_EVENT")
+	}
+
+	decoder, err = binlog.NewBinFileDecoder(path, &binlog.BinReaderOption{RecoverMode: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var xids []uint64
+	err = decoder.WalkEvent(func(event *binlog.BinEvent) (isContinue bool, err error) {
+		if xid, ok := event.Body.(*binlog.BinXIDEvent); ok {
+			xids = append(xids, xid.XID)
+		}
+		return true, nil
+	})
+	if err != nil || !reflect.DeepEqual(xids, []uint64{1, 2}) {
+		t.Errorf("got xids %v, error %v", xids, err)
+	}
+
+	// the untrusted bytes of DecodeEventBytes
+	b = newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	userTableMap(b)
+	b.event(binlog.QueryEvent, []byte{1, 0, 0, 0, 0})
+	b.event(binlog.TableMapEvent, []byte{101, 0, 0, 0, 0, 0, 0, 0, 4, 't'})
+	b.rows(binlog.WriteRowsEventV2, 100, 3, []byte{0x07}, nil, []byte{0x00, 1, 0})
+	b.event(binlog.WriteRowsEventV2, []byte{100, 0, 0, 0, 0, 0, 0, 0, 9, 0})
+	// the meta of VARCHAR is short, DECIMAL(2,5), the optional metadata is truncated
+	b.tableMap(102, "test", "t", []byte{byte(binlog.MySQLTypeVarchar)}, []byte{20}, []byte{0})
+	b.tableMap(102, "test", "t", []byte{byte(binlog.MySQLTypeNewDecimal)}, []byte{2, 5}, []byte{0})
+	b.tableMap(102, "test", "t", []byte{byte(binlog.MySQLTypeLong)}, nil, []byte{0, 4, 0xfc})
+	// no column is present
+	b.rows(binlog.WriteRowsEventV2, 100, 3, []byte{0x00}, nil, []byte{0x00})
+	// the value of JSON object points back to the object itself
+	b.tableMap(104, "test", "j", []byte{byte(binlog.MySQLTypeJSON)}, []byte{4}, []byte{0})
+	b.rows(binlog.WriteRowsEventV2, 104, 1, []byte{0x01}, nil,
+		[]byte{0x00, 13, 0, 0, 0, 0x00, 1, 0, 12, 0, 11, 0, 1, 0, 0x00, 0, 0, 'a'})
+
+	var desc *binlog.BinFmtDescEvent
+	tableInfo := make(map[uint64]*binlog.BinTableMapEvent)
+	var failed int
+	for data := b.buf.Bytes()[4:]; len(data) > 0; {
+		size := binary.LittleEndian.Uint32(data[9:])
+		event, err := binlog.DecodeEventBytes(data[:size], desc, tableInfo)
+		if err != nil {
+			failed++
+		} else if fde, ok := event.Body.(*binlog.BinFmtDescEvent); ok {
+			desc = fde
+		}
+		data = data[size:]
+	}
+	if failed != 9 {
+		t.Errorf("got %d malformed events failed, want 9", failed)
+	}
+
+	// FORMAT_DESCRIPTION_EVENT without the checksum algorithm or with a short event header length
+	for _, headerLength := range []byte{19, 0} {
+		body := make([]byte, 57)
+		body[0] = 4
+		copy(body[2:], "5.7.23-log")
+		body[56] = headerLength
+		data := make([]byte, 19, 19+len(body))
+		data[4] = byte(binlog.FormatDescriptionEvent)
+		binary.LittleEndian.PutUint32(data[9:], uint32(19+len(body)))
+		if _, err := binlog.DecodeEventBytes(append(data, body...), nil, nil); err == nil {
+			t.Errorf("got no error of malformed FORMAT_DESCRIPTION_EVENT, header length %d", headerLength)
+		}
+	}
+}
+
+func TestWalkErrorContext(t *testing.T) {
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	b.event(binlog.XIDEvent, make([]byte, 8))
+	pos := b.buf.Len()
+	b.event(binlog.IgnorableEvent, []byte{1, 2})
+	path := b.file(t)
+
+	decoder, err := binlog.NewBinFileDecoder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = decoder.WalkEvent(func(event *binlog.BinEvent) (isContinue bool, err error) { return true, nil })
+	if !errors.Is(err, binlog.ErrUnsupportedEvent) {
+		t.Fatalf("got error %v, want ErrUnsupportedEvent", err)
+	}
+	want := fmt.Sprintf("%s: event #3, last good position %d: ", path, pos)
+	if !strings.HasPrefix(err.Error(), want) {
+		t.Errorf("got error %q, want prefix %q", err, want)
+	}
+}
+
+func TestMaxEventsAndRows(t *testing.T) {
+	decoder, err := binlog.NewBinFileDecoder("./testdata/mysql-bin.000004", &binlog.BinReaderOption{MaxEvents: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := 0
+	err = decoder.WalkEvent(func(event *binlog.BinEvent) (isContinue bool, err error) {
+		count++
+		return true, nil
+	})
+	if err != nil || count != 10 {
+		t.Errorf("got %d events and error %v, want 10 events", count, err)
+	}
+
+	// 3 rows events of 2 rows, the walk stops after the event reaching MaxRows
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	userTableMap(b)
+	for i := 0; i < 3; i++ {
+		b.rows(binlog.WriteRowsEventV2, 100, 3, []byte{0x07}, nil, []byte{
+			0x04, 1, 0, 0, 0, 1, 'a',
+			0x04, 2, 0, 0, 0, 1, 'b',
+		})
+	}
+	rows := 0
+	for _, event := range b.walk(t, &binlog.BinReaderOption{MaxRows: 3}) {
+		if e, ok := event.Body.(*binlog.BinRowsEvent); ok {
+			rows += len(e.Rows)
+		}
+	}
+	if rows != 4 {
+		t.Errorf("got %d rows, want 4", rows)
+	}
+}
+
+type recordLogger struct {
+	debugs, warns []string
+}
+
+func (l *recordLogger) Debugf(format string, args ...interface{}) {
+	l.debugs = append(l.debugs, fmt.Sprintf(format, args...))
+}
+
+func (l *recordLogger) Warnf(format string, args ...interface{}) {
+	l.warns = append(l.warns, fmt.Sprintf(format, args...))
+}
+
+func TestLogger(t *testing.T) {
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	b.event(binlog.IgnorableEvent, []byte{1, 2})
+	b.event(binlog.XIDEvent, make([]byte, 8))
+
+	logger := &recordLogger{}
+	b.walk(t, &binlog.BinReaderOption{
+		SkipUnsupported: true,
+		EventTypeFilter: func(eventType binlog.EventType) bool { return eventType != binlog.XIDEvent },
+		Logger:          logger,
+	})
+	if len(logger.warns) != 1 || !strings.HasPrefix(logger.warns[0], "unsupported IGNORABLE_EVENT") {
+		t.Errorf("got warnings %q", logger.warns)
+	}
+	if len(logger.debugs) != 1 || !strings.HasPrefix(logger.debugs[0], "skip XID_EVENT") {
+		t.Errorf("got debugs %q", logger.debugs)
+	}
+}
+
+func TestEndReason(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		last     binlog.EventType
+		body     []byte
+		truncate int
+		want     binlog.EndReason
+	}{
+		{"rotate", binlog.RotateEvent, append(make([]byte, 8), "mysql-bin.000002"...), 0, binlog.EndRotate},
+		{"stop", binlog.StopEvent, nil, 0, binlog.EndStop},
+		{"abrupt", binlog.XIDEvent, make([]byte, 8), 0, binlog.EndAbrupt},
+		{"truncated body", binlog.XIDEvent, make([]byte, 8), 5, binlog.EndTruncated},
+		{"missing body", binlog.XIDEvent, make([]byte, 8), 12, binlog.EndTruncated},
+		{"truncated header", binlog.XIDEvent, make([]byte, 8), 20, binlog.EndTruncated},
+	} {
+		b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+		b.event(binlog.XIDEvent, make([]byte, 8))
+		b.event(tc.last, tc.body)
+		b.buf.Truncate(b.buf.Len() - tc.truncate)
+
+		decoder, err := binlog.NewBinFileDecoder(b.file(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decoder.EndReason() != binlog.EndUnknown {
+			t.Errorf("%s: got end reason %s before walking", tc.name, decoder.EndReason())
+		}
+		if err = decoder.WalkEvent(func(event *binlog.BinEvent) (isContinue bool, err error) { return true, nil }); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if got := decoder.EndReason(); got != tc.want || got.Clean() != (tc.want == binlog.EndRotate || tc.want == binlog.EndStop) {
+			t.Errorf("%s: got end reason %s, want %s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestEncryptedBinlog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mysql-bin.000001")
+	if err := os.WriteFile(path, append([]byte{0xfd, 'b', 'i', 'n'}, make([]byte, 508)...), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := binlog.NewBinFileDecoder(path); !errors.Is(err, binlog.ErrEncryptedBinlog) {
+		t.Errorf("got error %v, want ErrEncryptedBinlog", err)
+	}
+}
+
+func TestFileHeader(t *testing.T) {
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	b.event(binlog.XIDEvent, make([]byte, 8))
+	copy(b.buf.Bytes(), "xbin")
+	path := b.file(t)
+
+	if _, err := binlog.NewBinFileDecoder(path); err == nil || !strings.Contains(err.Error(), "invalid binary log header") {
+		t.Errorf("got error %v, want invalid binary log header", err)
+	}
+	if _, err := binlog.NewBinFileDecoder(path, &binlog.BinReaderOption{FileHeader: []byte("bin")}); err == nil {
+		t.Errorf("got no error of 3 bytes FileHeader")
+	}
+
+	for _, option := range []*binlog.BinReaderOption{{FileHeader: []byte("xbin")}, {SkipHeaderCheck: true}} {
+		decoder, err := binlog.NewBinFileDecoder(path, option)
+		if err != nil {
+			t.Fatal(err)
+		}
+		count := 0
+		err = decoder.WalkEvent(func(event *binlog.BinEvent) (isContinue bool, err error) {
+			count++
+			return true, nil
+		})
+		if err != nil || count != 2 {
+			t.Errorf("got %d events, error %v, want 2 events", count, err)
+		}
+	}
+}
+
+func TestTail(t *testing.T) {
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	b.event(binlog.XIDEvent, make([]byte, 8))
+	path := b.file(t)
+
+	decoder, err := binlog.NewBinFileDecoder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events := make(chan binlog.EventType, 16)
+	done := make(chan error, 1)
+	go func() {
+		done <- decoder.Tail(ctx, 10*time.Millisecond, func(event *binlog.BinEvent) (isContinue bool, err error) {
+			events <- event.Header.EventType
+			return true, nil
+		})
+	}()
+	expect := func(want ...binlog.EventType) {
+		for _, w := range want {
+			select {
+			case got := <-events:
+				if got != w {
+					t.Fatalf("got event %s, want %s", got, w)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("timeout waiting for %s", w)
+			}
+		}
+	}
+	expect(binlog.FormatDescriptionEvent, binlog.XIDEvent)
+
+	// events appended to the binary log
+	size := b.buf.Len()
+	b.event(binlog.XIDEvent, make([]byte, 8))
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = file.Write(b.buf.Bytes()[size:]); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	expect(binlog.XIDEvent)
+
+	// the next-numbered binary log without ROTATE_EVENT
+	next := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	next.event(binlog.XIDEvent, make([]byte, 8))
+	next.writeFile(t, filepath.Join(filepath.Dir(path), "mysql-bin.000002"))
+	expect(binlog.FormatDescriptionEvent, binlog.XIDEvent)
+
+	cancel()
+	select {
+	case err = <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("got error %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Tail not stopped by context")
+	}
+}
+
+func TestBinlogCreateTime(t *testing.T) {
+	decoder, err := binlog.NewBinFileDecoder("./testdata/mysql-bin.000004")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = decoder.BinlogCreateTime(); err == nil {
+		t.Error("got no error before FORMAT_DESCRIPTION_EVENT is read")
+	}
+	if _, err = decoder.DecodeEvent(); err != nil {
+		t.Fatal(err)
+	}
+	// the create timestamp is zero, the header timestamp is used
+	created, err := decoder.BinlogCreateTime()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !created.Equal(time.Unix(1537611870, 0)) {
+		t.Errorf("got create time %s", created)
+	}
+
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	// create timestamp of the first binary log since the server starts
+	fde := b.buf.Bytes()[4:]
+	binary.LittleEndian.PutUint32(fde[19+52:], 1600000000)
+	binary.LittleEndian.PutUint32(fde[len(fde)-4:], crc32.ChecksumIEEE(fde[:len(fde)-4]))
+	decoder, err = binlog.NewBinFileDecoder(b.file(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = decoder.DecodeEvent(); err != nil {
+		t.Fatal(err)
+	}
+	if created, err = decoder.BinlogCreateTime(); err != nil || !created.Equal(time.Unix(1600000000, 0)) {
+		t.Errorf("got create time %s, %v", created, err)
+	}
+}
+
+func TestLegacyBinlogVersion(t *testing.T) {
+	// START_EVENT_V3 of mysql 4.1 (binlog_version 3), 19 bytes header, no checksum
+	body := make([]byte, 2+50+4)
+	binary.LittleEndian.PutUint16(body, 3)
+	copy(body[2:], "4.1.22-log")
+	header := make([]byte, 19)
+	header[4] = byte(binlog.StartEventV3)
+	binary.LittleEndian.PutUint32(header[9:], uint32(len(header)+len(body)))
+	binary.LittleEndian.PutUint32(header[13:], uint32(4+len(header)+len(body)))
+	data := append(append([]byte{0xfe, 'b', 'i', 'n'}, header...), body...)
+
+	path := filepath.Join(t.TempDir(), "mysql-bin.000001")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	decoder, err := binlog.NewBinFileDecoder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = decoder.WalkEvent(func(event *binlog.BinEvent) (isContinue bool, err error) { return true, nil })
+	if !errors.Is(err, binlog.ErrUnsupportedBinlogVersion) || !strings.Contains(err.Error(), "binlog_version 3") {
+		t.Errorf("got error %v, want ErrUnsupportedBinlogVersion", err)
+	}
+
+	// the events depend on FORMAT_DESCRIPTION_EVENT fail clearly without it
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgOff)
+	b.buf.Truncate(4)
+	b.event(binlog.QueryEvent, queryBody("test", "BEGIN"))
+	decoder, err = binlog.NewBinFileDecoder(b.file(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = decoder.DecodeEvent(); !errors.Is(err, binlog.ErrNoFormatDescription) {
+		t.Errorf("got error %v, want ErrNoFormatDescription", err)
+	}
+}
+
+func TestDecodeWithoutFormatDescription(t *testing.T) {
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	fde := b.buf.Len()
+	userTableMap(b)
+	b.rows(binlog.WriteRowsEventV2, 100, 3, []byte{0x07}, nil, []byte{0x04, 1, 0, 0, 0, 1, 'a'})
+	b.event(binlog.XIDEvent, []byte{5, 0, 0, 0, 0, 0, 0, 0})
+	// the magic and the events after FORMAT_DESCRIPTION_EVENT
+	fragment := append([]byte{0xfe, 'b', 'i', 'n'}, b.buf.Bytes()[fde:]...)
+
+	decoder, err := binlog.NewBinReaderDecoder(bytes.NewReader(fragment))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = decoder.DecodeEvent(); !errors.Is(err, binlog.ErrNoFormatDescription) || !strings.Contains(err.Error(), "SetFormatDescription") {
+		t.Errorf("got error %v, want ErrNoFormatDescription", err)
+	}
+
+	decoder, err = binlog.NewBinReaderDecoder(bytes.NewReader(fragment))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoder.SetFormatDescription(binlog.NewFormatDescription("5.7.23-log", binlog.BinlogChecksumAlgCRC32))
+	var events []*binlog.BinEvent
+	for {
+		event, err := decoder.DecodeEvent()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, event)
+	}
+	if len(events) != 3 || !events[1].ChecksumOK {
+		t.Fatalf("got %d events", len(events))
+	}
+	if rows := rowsEvents(events); len(rows) != 1 || rows[0].Rows[0]["@2"] != "a" {
+		t.Errorf("got rows %v", rows)
+	}
+	if xid := events[2].Body.(*binlog.BinXIDEvent).XID; xid != 5 {
+		t.Errorf("got xid %d, want 5", xid)
+	}
+
+	// no checksum before mysql 5.6.2
+	if desc := binlog.NewFormatDescription("5.5.62-log", binlog.BinlogChecksumAlgCRC32); desc.ChecksumAlgorithm != binlog.BinlogChecksumAlgOff {
+		t.Errorf("got checksum algorithm %d of mysql 5.5", desc.ChecksumAlgorithm)
+	}
+}
+
+func TestStartFuncAndStopFunc(t *testing.T) {
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	var starts []int64
+	for xid := byte(1); xid <= 4; xid++ {
+		starts = append(starts, int64(b.buf.Len()))
+		b.event(binlog.XIDEvent, []byte{xid, 0, 0, 0, 0, 0, 0, 0})
+	}
+	xids := func(events []*binlog.BinEvent) []uint64 {
+		var xids []uint64
+		for _, event := range events {
+			if xid, ok := event.Body.(*binlog.BinXIDEvent); ok {
+				xids = append(xids, xid.XID)
+			}
+		}
+		return xids
+	}
+	xidIs := func(n uint64) func(event *binlog.BinEvent) bool {
+		return func(event *binlog.BinEvent) bool {
+			xid, ok := event.Body.(*binlog.BinXIDEvent)
+			return ok && xid.XID == n
+		}
+	}
+	at := func(i int) func(header *binlog.BinEventHeader) bool {
+		return func(header *binlog.BinEventHeader) bool { return header.LogPos-header.EventSize == starts[i] }
+	}
+
+	for _, tc := range []struct {
+		name   string
+		option *binlog.BinReaderOption
+		want   []uint64
+	}{
+		{"StartPos", &binlog.BinReaderOption{StartPos: starts[1]}, []uint64{2, 3, 4}},
+		{"StartTime", &binlog.BinReaderOption{StartTime: time.Unix(1537611871, 0)}, nil},
+		// StartFunc is met once, the following events are decoded
+		{"StartFunc", &binlog.BinReaderOption{StartFunc: at(2)}, []uint64{3, 4}},
+		// all of the start conditions are met
+		{"StartPos and StartFunc", &binlog.BinReaderOption{StartPos: starts[1], StartFunc: at(0)}, nil},
+		{"StartFunc and StopFunc", &binlog.BinReaderOption{
+			StartFunc: at(1),
+			StopFunc:  xidIs(4),
+		}, []uint64{2, 3}},
+		// any of the stop conditions is met
+		{"EndPos or StopFunc", &binlog.BinReaderOption{
+			EndPos:   starts[3],
+			StopFunc: xidIs(2),
+		}, []uint64{1}},
+	} {
+		if got := xids(b.walk(t, tc.option)); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got xids %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestChecksumAlgorithm(t *testing.T) {
+	decoder, err := binlog.NewBinFileDecoder("./testdata/mysql-bin.000004")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := decoder.ChecksumAlgorithm(); got != "UNKNOWN" {
+		t.Errorf("got checksum algorithm %s before FORMAT_DESCRIPTION_EVENT is read", got)
+	}
+	if _, err = decoder.DecodeEvent(); err != nil {
+		t.Fatal(err)
+	}
+	if got := decoder.ChecksumAlgorithm(); got != "CRC32" {
+		t.Errorf("got checksum algorithm %s, want CRC32", got)
+	}
+
+	for alg, want := range map[byte]string{binlog.BinlogChecksumAlgOff: "NONE", 0x7e: "UNKNOWN(126)"} {
+		// the checksum of unknown algorithm could not be verified
+		option := &binlog.BinReaderOption{SkipChecksumVerify: true}
+		decoder, err = binlog.NewBinReaderDecoder(bytes.NewReader(newBinlogBuilder(alg).buf.Bytes()), option)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = decoder.DecodeEvent(); err != nil {
+			t.Fatal(err)
+		}
+		if got := decoder.ChecksumAlgorithm(); got != want {
+			t.Errorf("got checksum algorithm %s, want %s", got, want)
+		}
+	}
+}
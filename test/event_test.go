@@ -0,0 +1,823 @@
+package test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/obgnail/binlog-parser"
+)
+
+func TestEventTypeString(t *testing.T) {
+	for typ := binlog.UnknownEvent; typ <= binlog.HeartbeatLogEventV2; typ++ {
+		if _, ok := binlog.EventType2Str[typ]; !ok {
+			t.Errorf("event type %#x has no name", uint8(typ))
+		}
+	}
+	if binlog.QueryEvent.String() != "QUERY_EVENT" {
+		t.Errorf("got %s, want QUERY_EVENT", binlog.QueryEvent)
+	}
+	if s := binlog.EventType(0x50).String(); s != "UNKNOWN_EVENT(0x50)" {
+		t.Errorf("got %s, want UNKNOWN_EVENT(0x50)", s)
+	}
+}
+
+func TestLegacyLoadEvents(t *testing.T) {
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	b.event(binlog.CreateFileEvent, []byte{1, 0, 0, 0, 'a', 'b'})
+	b.event(binlog.AppendBlockEvent, []byte{1, 0, 0, 0, 'c', 'd'})
+	b.event(binlog.ExecLoadEvent, []byte{1, 0, 0, 0})
+	b.event(binlog.DeleteFileEvent, []byte{1, 0, 0, 0})
+
+	events := b.walk(t)
+	if len(events) != 5 {
+		t.Fatalf("got %d events, want 5", len(events))
+	}
+
+	if _, ok := events[1].Body.(*binlog.BinEventUnParsed); !ok {
+		t.Errorf("CREATE_FILE_EVENT got %T, want *BinEventUnParsed", events[1].Body)
+	}
+
+	block, ok := events[2].Body.(*binlog.BinAppendBlockEvent)
+	if !ok || block.FileID != 1 || !bytes.Equal(block.BlockData, []byte("cd")) {
+		t.Errorf("APPEND_BLOCK_EVENT got %+v", events[2].Body)
+	}
+
+	for _, event := range events[3:] {
+		if e, ok := event.Body.(*binlog.BinFileIDEvent); !ok || e.FileID != 1 {
+			t.Errorf("%s got %+v", event.Header.Type(), event.Body)
+		}
+	}
+}
+
+func TestGroupReplicationEvents(t *testing.T) {
+	uuid := "3e11fa47-71ca-11e1-9e33-c80aa9429562"
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+
+	context := []byte{byte(len(uuid)), 7, 0, 0, 0, 1, 0, 0, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0}
+	context = append(context, uuid...)
+	context = append(context, 2, 0, 'h', '1', 2, 0, 'h', '2')
+	b.event(binlog.TransactionContextEvent, context)
+
+	view := make([]byte, 52)
+	copy(view, "15894112345:3")
+	view[40] = 9
+	view[48] = 1
+	view = append(view, 3, 0, 'k', 'e', 'y', 2, 0, 0, 0, 'v', '1')
+	b.event(binlog.ViewChangeEvent, view)
+
+	events := b.walk(t)
+	if len(events) != 3 {
+		t.Fatalf("got %d events, want 3", len(events))
+	}
+
+	ctx, ok := events[1].Body.(*binlog.BinTransactionContextEvent)
+	if !ok || ctx.ServerUUID != uuid || ctx.ThreadID != 7 || !ctx.GTIDSpecified ||
+		len(ctx.WriteSet) != 2 || ctx.WriteSet[1] != "h2" {
+		t.Errorf("TRANSACTION_CONTEXT_EVENT got %+v", events[1].Body)
+	}
+
+	vc, ok := events[2].Body.(*binlog.BinViewChangeEvent)
+	if !ok || vc.ViewID != "15894112345:3" || vc.SeqNumber != 9 || vc.CertInfoSize != 1 ||
+		!bytes.Equal(vc.CertInfo["key"], []byte("v1")) {
+		t.Errorf("VIEW_CHANGE_EVENT got %+v", events[2].Body)
+	}
+}
+
+func TestGroupReplicationOversizedCounts(t *testing.T) {
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	// write_set_items 0xFFFFFFFF
+	b.event(binlog.TransactionContextEvent, []byte{0, 7, 0, 0, 0, 1, 0, 0, 0, 0, 0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0})
+	// cert_info_size 0xFFFFFFFF
+	view := make([]byte, 52)
+	copy(view[48:], []byte{0xff, 0xff, 0xff, 0xff})
+	b.event(binlog.ViewChangeEvent, append(view, 3, 0, 'k', 'e', 'y'))
+
+	var desc *binlog.BinFmtDescEvent
+	var errs []error
+	for data := b.buf.Bytes()[4:]; len(data) > 0; {
+		size := binary.LittleEndian.Uint32(data[9:])
+		event, err := binlog.DecodeEventBytes(data[:size], desc, nil)
+		if err != nil {
+			errs = append(errs, err)
+		} else if fde, ok := event.Body.(*binlog.BinFmtDescEvent); ok {
+			desc = fde
+		}
+		data = data[size:]
+	}
+	if len(errs) != 2 || !strings.Contains(errs[0].Error(), "items") || !strings.Contains(errs[1].Error(), "cert info size") {
+		t.Errorf("got errors %v, want the oversized counts rejected", errs)
+	}
+}
+
+func TestEventFlags(t *testing.T) {
+	header := &binlog.BinEventHeader{Flag: binlog.LogEventArtificialF | binlog.LogEventSuppressUseF}
+	flags := header.Flags()
+	if !flags.Artificial || !flags.SuppressUse || flags.BinlogInUse || flags.Ignorable {
+		t.Errorf("got flags %+v", flags)
+	}
+}
+
+func TestFollowRotate(t *testing.T) {
+	rotate := func(fileName string) []byte {
+		body := make([]byte, 8)
+		binary.LittleEndian.PutUint64(body, 4)
+		return append(body, fileName...)
+	}
+
+	dir := t.TempDir()
+	first := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	first.event(binlog.XIDEvent, make([]byte, 8))
+	first.event(binlog.RotateEvent, rotate("mysql-bin.000002"))
+	path := first.writeFile(t, filepath.Join(dir, "mysql-bin.000001"))
+
+	second := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	// artificial ROTATE_EVENT is not a rotation, must not be followed
+	second.flag = binlog.LogEventArtificialF
+	second.event(binlog.RotateEvent, rotate("mysql-bin.000003"))
+	second.flag = 0
+	second.event(binlog.XIDEvent, make([]byte, 8))
+	second.writeFile(t, filepath.Join(dir, "mysql-bin.000002"))
+
+	decoder, err := binlog.NewBinFileDecoder(path, &binlog.BinReaderOption{FollowRotate: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types []binlog.EventType
+	err = decoder.WalkEvent(func(event *binlog.BinEvent) (isContinue bool, err error) {
+		types = append(types, event.Header.EventType)
+		return true, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []binlog.EventType{
+		binlog.FormatDescriptionEvent, binlog.XIDEvent, binlog.RotateEvent,
+		binlog.FormatDescriptionEvent, binlog.RotateEvent, binlog.XIDEvent,
+	}
+	if !reflect.DeepEqual(types, expected) {
+		t.Errorf("got event types %v, expected %v", types, expected)
+	}
+
+	// Walk closes the binary logs it opened, including the followed ones
+	fds := func() int {
+		entries, err := os.ReadDir("/proc/self/fd")
+		if err != nil {
+			t.Skip("open files are not listed")
+		}
+		return len(entries)
+	}
+	before := fds()
+	for i := 0; i < 3; i++ {
+		types = nil
+		err = decoder.Walk(func(event *binlog.BinEvent) (isContinue bool, err error) {
+			types = append(types, event.Header.EventType)
+			return true, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(types, expected) {
+			t.Errorf("got event types %v of Walk, expected %v", types, expected)
+		}
+	}
+	if after := fds(); after != before {
+		t.Errorf("got %d open files after Walk, expected %d", after, before)
+	}
+}
+
+func TestQueryStatusVars(t *testing.T) {
+	statusVars := []byte{
+		binlog.QAutoIncrement, 2, 0, 1, 0,
+		binlog.QCatalogNZCode, 3, 's', 't', 'd',
+		binlog.QCharsetCode, 33, 0, 33, 0, 8, 0,
+	}
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	b.event(binlog.QueryEvent, queryBody("test", "BEGIN", statusVars...))
+	events := b.walk(t)
+
+	vars, err := events[1].Body.(*binlog.BinQueryEvent).DecodeStatusVars()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vars.AutoIncrement == nil || *vars.AutoIncrement != (binlog.AutoIncrement{Increment: 2, Offset: 1}) {
+		t.Errorf("got auto increment %+v", vars.AutoIncrement)
+	}
+	if vars.Catalog != "std" || vars.ClientCharset != 33 || vars.CollationServer != 8 {
+		t.Errorf("got status vars %+v", vars)
+	}
+}
+
+func TestQueryStatusVars80(t *testing.T) {
+	statusVars := []byte{
+		binlog.QExplicitDefaultsForTimestamp, 1,
+		binlog.QDDLLoggedWithXID, 7, 0, 0, 0, 0, 0, 0, 0,
+		binlog.QDefaultCollationForUTF8MB4, 255, 0,
+		binlog.QSQLRequirePrimaryKey, 0,
+	}
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	b.event(binlog.QueryEvent, queryBody("test", "CREATE TABLE t (id int)", statusVars...))
+	events := b.walk(t)
+
+	vars, err := events[1].Body.(*binlog.BinQueryEvent).DecodeStatusVars()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vars.ExplicitDefaultsForTimestamp == nil || !*vars.ExplicitDefaultsForTimestamp {
+		t.Errorf("got explicit_defaults_for_timestamp %v", vars.ExplicitDefaultsForTimestamp)
+	}
+	if vars.SQLRequirePrimaryKey == nil || *vars.SQLRequirePrimaryKey {
+		t.Errorf("got sql_require_primary_key %v", vars.SQLRequirePrimaryKey)
+	}
+	if vars.DefaultTableEncryption != nil {
+		t.Errorf("got default_table_encryption %v, want absent", *vars.DefaultTableEncryption)
+	}
+	if vars.DDLXID != 7 || vars.DefaultCollationForUTF8MB4 != 255 {
+		t.Errorf("got status vars %+v", vars)
+	}
+}
+
+func TestQueryCharsetAndSQLMode(t *testing.T) {
+	sqlMode := make([]byte, 8)
+	binary.LittleEndian.PutUint64(sqlMode, binlog.SQLModeANSIQuotes|1<<21)
+	statusVars := append([]byte{binlog.QSQLModeCode}, sqlMode...)
+	statusVars = append(statusVars, binlog.QCharsetCode, 28, 0, 28, 0, 8, 0)
+
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	b.event(binlog.QueryEvent, queryBody("test", `CREATE TABLE "t" (id int)`, statusVars...))
+	b.event(binlog.QueryEvent, queryBody("test", "BEGIN"))
+	events := b.walk(t)
+
+	query := events[1].Body.(*binlog.BinQueryEvent)
+	if query.Charset != "gbk" || !query.ANSIQuotes || query.NoBackslashEscapes {
+		t.Errorf("got charset %q, ANSI_QUOTES %v, NO_BACKSLASH_ESCAPES %v", query.Charset, query.ANSIQuotes, query.NoBackslashEscapes)
+	}
+	query = events[2].Body.(*binlog.BinQueryEvent)
+	if query.Charset != "" || query.ANSIQuotes {
+		t.Errorf("got charset %q, ANSI_QUOTES %v without status vars", query.Charset, query.ANSIQuotes)
+	}
+	if binlog.CharsetName(255) != "utf8mb4" || binlog.CharsetName(63) != "binary" || binlog.CharsetName(0) != "" {
+		t.Error("got wrong charset names")
+	}
+}
+
+func TestEventTime(t *testing.T) {
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	b.event(binlog.QueryEvent, queryBody("test", "BEGIN", binlog.QMicroseconds, 0x40, 0xe2, 0x01))
+	b.event(binlog.XIDEvent, make([]byte, 8))
+	events := b.walk(t)
+
+	// the builder writes timestamp 1537611870
+	if !events[2].Time().Equal(time.Unix(1537611870, 0)) || !events[2].Header.Time().Equal(events[2].Time()) {
+		t.Errorf("got XID_EVENT time %s", events[2].Time())
+	}
+	if !events[1].Time().Equal(time.Unix(1537611870, 123456000)) {
+		t.Errorf("got QUERY_EVENT time %s", events[1].Time())
+	}
+}
+
+func TestSkipUnsupported(t *testing.T) {
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	b.event(binlog.IgnorableEvent, []byte{1, 2})
+	b.event(0x50, []byte{3, 4})
+	b.event(binlog.XIDEvent, make([]byte, 8))
+
+	decoder, err := binlog.NewBinFileDecoder(b.file(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = decoder.WalkEvent(func(event *binlog.BinEvent) (isContinue bool, err error) { return true, nil })
+	if !errors.Is(err, binlog.ErrUnsupportedEvent) {
+		t.Errorf("got error %v, want ErrUnsupportedEvent", err)
+	}
+
+	events := b.walk(t, &binlog.BinReaderOption{SkipUnsupported: true})
+	if len(events) != 4 {
+		t.Fatalf("got %d events, want 4", len(events))
+	}
+	for i, data := range [][]byte{{1, 2}, {3, 4}} {
+		if body, ok := events[i+1].Body.(*binlog.BinEventUnParsed); !ok || !bytes.Equal(body.Data, data) {
+			t.Errorf("got event %d body %+v, want raw %v", i+1, events[i+1].Body, data)
+		}
+	}
+}
+
+func TestHexDump(t *testing.T) {
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	b.event(binlog.XIDEvent, make([]byte, 8))
+	pos := b.buf.Len()
+	b.event(binlog.IgnorableEvent, []byte{0xab, 0xcd})
+
+	var dump bytes.Buffer
+	b.walk(t, &binlog.BinReaderOption{SkipUnsupported: true, HexDump: &dump})
+	want := fmt.Sprintf("IGNORABLE_EVENT at %d, size 25\n", pos)
+	if !strings.HasPrefix(dump.String(), want) || !strings.Contains(dump.String(), "ab cd") {
+		t.Errorf("got hex dump %q, want prefix %q", dump.String(), want)
+	}
+	if strings.Contains(dump.String(), "XID_EVENT") {
+		t.Errorf("got hex dump of decoded event %q", dump.String())
+	}
+}
+
+func TestHeartbeat(t *testing.T) {
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	b.event(binlog.HeartbeatEvent, []byte("mysql-bin.000007"))
+
+	var fileName string
+	var pos int64
+	events := b.walk(t, &binlog.BinReaderOption{Heartbeat: func(f string, p int64) { fileName, pos = f, p }})
+	if fileName != "mysql-bin.000007" || pos != events[1].Header.LogPos {
+		t.Errorf("got heartbeat %s:%d, want mysql-bin.000007:%d", fileName, pos, events[1].Header.LogPos)
+	}
+}
+
+func TestHeartbeatV2(t *testing.T) {
+	// filename, position beyond 4GB, an unknown field, end mark
+	body := append([]byte{1, 16}, "mysql-bin.000007"...)
+	position := appendLengthEncodedInt(nil, 5000000000)
+	body = append(append(body, 2, byte(len(position))), position...)
+	body = append(body, 7, 2, 0xaa, 0xbb, 0)
+
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	b.event(binlog.HeartbeatLogEventV2, body)
+
+	var fileName string
+	var pos int64
+	events := b.walk(t, &binlog.BinReaderOption{Heartbeat: func(f string, p int64) { fileName, pos = f, p }})
+	if fileName != "mysql-bin.000007" || pos != 5000000000 {
+		t.Errorf("got heartbeat %s:%d, want mysql-bin.000007:5000000000", fileName, pos)
+	}
+	if _, ok := events[1].Body.(*binlog.BinHeartbeatEvent); !ok {
+		t.Errorf("got %T, want BinHeartbeatEvent", events[1].Body)
+	}
+}
+
+func TestRelayLog(t *testing.T) {
+	rotate := func(fileName string, pos uint64) []byte {
+		body := make([]byte, 8)
+		binary.LittleEndian.PutUint64(body, pos)
+		return append(body, fileName...)
+	}
+
+	dir := t.TempDir()
+	first := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	first.event(binlog.RotateEvent, rotate("master-bin.000003", 4))
+	first.event(binlog.XIDEvent, make([]byte, 8))
+	first.flag = binlog.LogEventRelayLogF
+	first.event(binlog.RotateEvent, rotate("relay-bin.000002", 4))
+	path := first.writeFile(t, filepath.Join(dir, "relay-bin.000001"))
+
+	second := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	second.event(binlog.XIDEvent, make([]byte, 8))
+	second.writeFile(t, filepath.Join(dir, "relay-bin.000002"))
+
+	decoder, err := binlog.NewBinFileDecoder(path, &binlog.BinReaderOption{FollowRotate: true, RelayLog: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var last *binlog.BinEvent
+	err = decoder.WalkEvent(func(event *binlog.BinEvent) (isContinue bool, err error) {
+		last = event
+		return true, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the ROTATE_EVENT of master is not followed
+	file, pos := decoder.MasterPosition()
+	if file != "master-bin.000003" || pos != last.Header.LogPos {
+		t.Errorf("got master position %s:%d, want master-bin.000003:%d", file, pos, last.Header.LogPos)
+	}
+}
+
+func TestDecodeEventBytes(t *testing.T) {
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	userTableMap(b)
+	b.rows(binlog.WriteRowsEventV2, 100, 3, []byte{0x07}, nil, []byte{0x00, 1, 0, 0, 0, 1, 'a', 2, 0, 0, 0})
+
+	var desc *binlog.BinFmtDescEvent
+	tableInfo := make(map[uint64]*binlog.BinTableMapEvent)
+	var events []*binlog.BinEvent
+	for data := b.buf.Bytes()[4:]; len(data) > 0; {
+		size := binary.LittleEndian.Uint32(data[9:])
+		event, err := binlog.DecodeEventBytes(data[:size], desc, tableInfo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fde, ok := event.Body.(*binlog.BinFmtDescEvent); ok {
+			desc = fde
+		}
+		events = append(events, event)
+		data = data[size:]
+	}
+
+	rows := rowsEvents(events)
+	if len(events) != 3 || len(rows) != 1 || rows[0].Rows[0]["@2"] != "a" {
+		t.Fatalf("got %d events, rows %v", len(events), rows)
+	}
+}
+
+func TestOnDDL(t *testing.T) {
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	b.event(binlog.QueryEvent, queryBody("test", "BEGIN"))
+	b.event(binlog.QueryEvent, queryBody("test", "/* app */ alter table user add column email varchar(64)"))
+	b.event(binlog.QueryEvent, queryBody("test", "COMMIT"))
+	b.event(binlog.QueryEvent, queryBody("test", "GRANT SELECT ON test.* TO 'u'@'%'"))
+	b.event(binlog.QueryEvent, queryBody("test", "TRUNCATE user"))
+
+	var ddl []string
+	b.walk(t, &binlog.BinReaderOption{OnDDL: func(schema, query string) { ddl = append(ddl, schema+": "+query) }})
+
+	want := []string{"test: /* app */ alter table user add column email varchar(64)", "test: TRUNCATE user"}
+	if !reflect.DeepEqual(ddl, want) {
+		t.Errorf("got DDL %v, want %v", ddl, want)
+	}
+}
+
+func TestTinyEventWithChecksum(t *testing.T) {
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	// a corrupted event without checksum value, the body is smaller than the checksum
+	b.checksum = binlog.BinlogChecksumAlgOff
+	b.event(binlog.XIDEvent, []byte{1, 2})
+
+	decoder, err := binlog.NewBinFileDecoder(b.file(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = decoder.WalkEvent(func(event *binlog.BinEvent) (isContinue bool, err error) { return true, nil })
+	if err == nil || !strings.Contains(err.Error(), "smaller than checksum size") {
+		t.Errorf("got error %v, want body smaller than checksum", err)
+	}
+}
+
+func TestTruncatedFixedSizeEvents(t *testing.T) {
+	for _, eventType := range []binlog.EventType{binlog.XIDEvent, binlog.RotateEvent} {
+		// the checksum is valid, but the body is truncated
+		b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+		b.event(eventType, []byte{1, 2})
+
+		decoder, err := binlog.NewBinFileDecoder(b.file(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = decoder.WalkEvent(func(event *binlog.BinEvent) (isContinue bool, err error) { return true, nil })
+		if err == nil || !strings.Contains(err.Error(), "size 2") {
+			t.Errorf("%s: got error %v, want invalid event size", eventType, err)
+		}
+	}
+}
+
+func TestTransactionEnd(t *testing.T) {
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	b.event(binlog.QueryEvent, queryBody("test", "BEGIN"))
+	b.event(binlog.XIDEvent, []byte{42, 0, 0, 0, 0, 0, 0, 0})
+	b.event(binlog.QueryEvent, queryBody("test", "BEGIN"))
+	b.event(binlog.QueryEvent, queryBody("test", "COMMIT"))
+	events := b.walk(t)
+
+	var ends []binlog.TransactionEnd
+	for _, event := range events {
+		if end, ok := event.TransactionEnd(); ok {
+			ends = append(ends, *end)
+		}
+	}
+	if len(ends) != 2 || ends[0].XID != 42 || ends[0].Position != events[2].Header.LogPos || ends[1].XID != 0 ||
+		ends[1].Position != events[4].Header.LogPos || !ends[1].Timestamp.Equal(events[4].Time()) {
+		t.Errorf("got transaction ends %+v", ends)
+	}
+}
+
+func TestTransactionID(t *testing.T) {
+	xaPrepare := []byte{0, 1, 0, 0, 0, 5, 0, 0, 0, 2, 0, 0, 0}
+	xaPrepare = append(xaPrepare, "gtridbq"...)
+
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	b.event(binlog.QueryEvent, queryBody("test", "BEGIN"))
+	b.event(binlog.XIDEvent, []byte{42, 0, 0, 0, 0, 0, 0, 0})
+	b.event(binlog.QueryEvent, queryBody("test", "XA START X'6774726964',X'6271',1"))
+	b.event(binlog.QueryEvent, queryBody("test", "XA END X'6774726964',X'6271',1"))
+	b.event(binlog.XAPrepareLogEvent, xaPrepare)
+	events := b.walk(t)
+
+	prepare, ok := events[5].Body.(*binlog.BinXAPrepareEvent)
+	if !ok || prepare.OnePhase || prepare.FormatID != 1 || string(prepare.GTRID) != "gtrid" || string(prepare.BQUAL) != "bq" {
+		t.Fatalf("got XA_PREPARE_LOG_EVENT %+v", events[5].Body)
+	}
+
+	var ids []string
+	var kinds []binlog.TransactionIDKind
+	for _, event := range events {
+		if end, ok := event.TransactionEnd(); ok {
+			ids = append(ids, end.ID.String())
+			kinds = append(kinds, end.ID.Kind)
+		}
+	}
+	if !reflect.DeepEqual(ids, []string{"42", "X'6774726964',X'6271',1"}) ||
+		!reflect.DeepEqual(kinds, []binlog.TransactionIDKind{binlog.TransactionIDXID, binlog.TransactionIDXA}) {
+		t.Errorf("got transaction ids %q of kinds %v", ids, kinds)
+	}
+}
+
+func TestXATracker(t *testing.T) {
+	xaPrepare := func(onePhase byte, gtrid, bqual string) []byte {
+		body := []byte{onePhase, 1, 0, 0, 0, byte(len(gtrid)), 0, 0, 0, byte(len(bqual)), 0, 0, 0}
+		return append(body, gtrid+bqual...)
+	}
+
+	// the prepares and their commit or rollback are in different binary logs
+	first := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	first.event(binlog.XAPrepareLogEvent, xaPrepare(0, "gtrid", "bq"))
+	first.event(binlog.XAPrepareLogEvent, xaPrepare(0, "abc", ""))
+	first.event(binlog.XAPrepareLogEvent, xaPrepare(1, "one", ""))
+	second := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	second.event(binlog.QueryEvent, queryBody("test", "XA COMMIT X'6774726964',X'6271',1"))
+	second.event(binlog.QueryEvent, queryBody("test", "XA ROLLBACK 'lost'"))
+	second.event(binlog.QueryEvent, queryBody("test", "XA START 'next'"))
+
+	var tracker binlog.XATracker
+	var got []string
+	for _, events := range [][]*binlog.BinEvent{first.walk(t), second.walk(t)} {
+		for _, event := range events {
+			if tx, ok := tracker.Track(event); ok {
+				got = append(got, fmt.Sprintf("%s %s %t %t", tx.ID.String(), tx.State, tx.Prepare != nil, tx.End != nil))
+			}
+		}
+	}
+	want := []string{
+		"X'6774726964',X'6271',1 prepared true false",
+		"X'616263',X'',1 prepared true false",
+		"X'6f6e65',X'',1 committed true false",
+		"X'6774726964',X'6271',1 committed true true",
+		"X'6c6f7374',X'',1 rolled back false true",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got XA transactions %q", got)
+	}
+	if prepared := tracker.Prepared(); len(prepared) != 1 || string(prepared[0].ID.GTRID) != "abc" {
+		t.Errorf("got prepared XA transactions %+v", prepared)
+	}
+
+	action, id, ok := binlog.ParseXA("xa rollback 0x616263, 'b''q', 7")
+	if !ok || action != binlog.XARollback || string(id.GTRID) != "abc" || string(id.BQUAL) != "b'q" || id.FormatID != 7 {
+		t.Errorf("got %v %+v %t", action, id, ok)
+	}
+	if _, _, ok := binlog.ParseXA("COMMIT"); ok {
+		t.Errorf("COMMIT is not XA statement")
+	}
+}
+
+func TestLenientChecksum(t *testing.T) {
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	b.event(binlog.XIDEvent, []byte{42, 0, 0, 0, 0, 0, 0, 0})
+	b.event(binlog.XIDEvent, []byte{43, 0, 0, 0, 0, 0, 0, 0})
+	// corrupt the checksum of the first XID_EVENT
+	data := b.buf.Bytes()
+	data[b.buf.Len()-31-1] ^= 0xff
+	path := b.file(t)
+
+	decoder, err := binlog.NewBinFileDecoder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = decoder.WalkEvent(func(event *binlog.BinEvent) (isContinue bool, err error) { return true, nil })
+	if !errors.Is(err, binlog.ErrChecksumFailed) {
+		t.Errorf("got error %v, want ErrChecksumFailed", err)
+	}
+
+	// the checksum is stripped but not verified
+	events := make([]*binlog.BinEvent, 0, 3)
+	decoder, err = binlog.NewBinFileDecoder(path, &binlog.BinReaderOption{SkipChecksumVerify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = decoder.WalkEvent(func(event *binlog.BinEvent) (isContinue bool, err error) {
+		events = append(events, event)
+		return true, nil
+	})
+	if err != nil || len(events) != 3 || !events[1].ChecksumOK || events[1].Body.(*binlog.BinXIDEvent).XID != 42 {
+		t.Errorf("got events %v, error %v without checksum verify", events, err)
+	}
+
+	decoder, err = binlog.NewBinFileDecoder(path, &binlog.BinReaderOption{LenientChecksum: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ok []bool
+	var xids []uint64
+	err = decoder.WalkEvent(func(event *binlog.BinEvent) (isContinue bool, err error) {
+		ok = append(ok, event.ChecksumOK)
+		if xid, isXID := event.Body.(*binlog.BinXIDEvent); isXID {
+			xids = append(xids, xid.XID)
+		}
+		return true, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ok, []bool{true, false, true}) || !reflect.DeepEqual(xids, []uint64{42, 43}) {
+		t.Errorf("got checksum ok %v, xids %v", ok, xids)
+	}
+}
+
+func TestQueryBytes(t *testing.T) {
+	// INSERT INTO t VALUES ('中') in gbk
+	query := append([]byte("INSERT INTO t VALUES ('"), 0xd6, 0xd0, '\'', ')')
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	b.event(binlog.QueryEvent, queryBody("test", string(query), binlog.QCharsetCode, 28, 0, 28, 0, 28, 0))
+	events := b.walk(t)
+
+	event := events[1].Body.(*binlog.BinQueryEvent)
+	if !bytes.Equal(event.QueryBytes, query) {
+		t.Errorf("got query bytes %x, want %x", event.QueryBytes, query)
+	}
+	if event.Query != "INSERT INTO t VALUES ('�')" {
+		t.Errorf("got query %q", event.Query)
+	}
+	if vars, err := event.DecodeStatusVars(); err != nil || vars.ClientCharset != 28 {
+		t.Errorf("got status vars %+v, error %v", vars, err)
+	}
+}
+
+func TestStatementContext(t *testing.T) {
+	userVar := func(name string, typ byte, value []byte, flags ...byte) []byte {
+		body := appendUint32(nil, uint32(len(name)))
+		body = append(append(body, name...), 0, typ)
+		body = appendUint32(body, 45)
+		body = appendUint32(body, uint32(len(value)))
+		return append(append(body, value...), flags...)
+	}
+	seeds := make([]byte, 16)
+	binary.LittleEndian.PutUint64(seeds, 11)
+	binary.LittleEndian.PutUint64(seeds[8:], 22)
+	intValue := make([]byte, 8)
+	binary.LittleEndian.PutUint64(intValue, 1<<63)
+
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	b.event(binlog.IntvarEvent, []byte{binlog.IntvarInsertID, 5, 0, 0, 0, 0, 0, 0, 0})
+	b.event(binlog.RandEvent, seeds)
+	b.event(binlog.UserVarEvent, userVar("name", binlog.UserVarString, []byte("it's")))
+	b.event(binlog.UserVarEvent, userVar("big", binlog.UserVarInt, intValue, 1))
+	b.event(binlog.UserVarEvent, append(appendUint32(nil, 1), 'n', 1))
+	b.event(binlog.QueryEvent, queryBody("test", "INSERT INTO t VALUES (NULL, @name, @big, @n, RAND())"))
+	b.event(binlog.QueryEvent, queryBody("test", "COMMIT"))
+
+	var queries []*binlog.BinQueryEvent
+	for _, event := range b.walk(t) {
+		if query, ok := event.Body.(*binlog.BinQueryEvent); ok {
+			queries = append(queries, query)
+		}
+	}
+	if len(queries) != 2 {
+		t.Fatalf("got %d queries", len(queries))
+	}
+	want := []string{
+		"SET INSERT_ID=5",
+		"SET @@RAND_SEED1=11, @@RAND_SEED2=22",
+		"SET @`name`:='it\\'s'",
+		"SET @`big`:=9223372036854775808",
+		"SET @`n`:=NULL",
+	}
+	if got := queries[0].Context.SetStatements(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if queries[1].Context != nil {
+		t.Errorf("got context %+v of the next query, want nil", queries[1].Context)
+	}
+}
+
+func TestDecodeEventFromReader(t *testing.T) {
+	xid := make([]byte, 8)
+	binary.LittleEndian.PutUint64(xid, 12345)
+	query := queryBody("test", "CREATE TABLE t (id INT)")
+	binary.LittleEndian.PutUint32(query[4:], 3) // execution time
+
+	for _, tc := range []struct {
+		eventType binlog.EventType
+		body      []byte
+		want      binlog.BinEventBody
+	}{
+		{binlog.XIDEvent, xid, &binlog.BinXIDEvent{XID: 12345}},
+		{binlog.QueryEvent, query, &binlog.BinQueryEvent{ExecutionTime: 3, Schema: "test", Query: "CREATE TABLE t (id INT)"}},
+	} {
+		b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+		b.event(tc.eventType, tc.body)
+		events := b.decodeEvents(t)
+		if len(events) != 2 {
+			t.Fatalf("%s: got %d events, want 2", tc.eventType, len(events))
+		}
+		event := events[1]
+		if event.Header.EventType != tc.eventType || event.Header.LogPos != int64(b.pos) || !event.ChecksumOK {
+			t.Errorf("%s: got header %+v", tc.eventType, event.Header)
+		}
+
+		switch want := tc.want.(type) {
+		case *binlog.BinXIDEvent:
+			if got, ok := event.Body.(*binlog.BinXIDEvent); !ok || got.XID != want.XID {
+				t.Errorf("got %+v, want %+v", event.Body, want)
+			}
+		case *binlog.BinQueryEvent:
+			got, ok := event.Body.(*binlog.BinQueryEvent)
+			if !ok || got.ExecutionTime != want.ExecutionTime || got.Schema != want.Schema || got.Query != want.Query {
+				t.Errorf("got %+v, want %+v", event.Body, want)
+			}
+		}
+	}
+
+	// the features need a file
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	decoder, err := binlog.NewBinReaderDecoder(bytes.NewReader(b.buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = decoder.SeekTo(4); err == nil {
+		t.Error("got no error of SeekTo")
+	}
+	if err = decoder.Walk(func(event *binlog.BinEvent) (isContinue bool, err error) { return true, nil }); err == nil {
+		t.Error("got no error of Walk")
+	}
+}
+
+func TestServerFlavor(t *testing.T) {
+	for _, tc := range []struct {
+		version string
+		want    binlog.ServerFlavor
+	}{
+		{"5.7.23-log", binlog.FlavorMySQL},
+		{"10.3.9-MariaDB-log", binlog.FlavorMariaDB},
+		{"5.7.12-aurora-log", binlog.FlavorAurora},
+		{"5.7.44-RDS.20240408-log", binlog.FlavorRDS},
+	} {
+		b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+		b.serverVersion(tc.version)
+		// a vendor-specific event
+		b.event(binlog.EventType(0x90), []byte{1, 2, 3})
+		b.event(binlog.XIDEvent, make([]byte, 8))
+
+		decoder, err := binlog.NewBinFileDecoder(b.file(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var events []*binlog.BinEvent
+		err = decoder.WalkEvent(func(event *binlog.BinEvent) (isContinue bool, err error) {
+			events = append(events, event)
+			return true, nil
+		})
+		if tc.want == binlog.FlavorMySQL {
+			if err == nil {
+				t.Errorf("%s: got no error of unknown event", tc.version)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tc.version, err)
+		}
+		if got := events[0].Body.(*binlog.BinFmtDescEvent).Flavor(); got != tc.want {
+			t.Errorf("%s: got flavor %s, want %s", tc.version, got, tc.want)
+		}
+		if len(events) != 3 {
+			t.Fatalf("%s: got %d events, want 3", tc.version, len(events))
+		}
+		if body, ok := events[1].Body.(*binlog.BinEventUnParsed); !ok || !bytes.Equal(body.Data, []byte{1, 2, 3}) {
+			t.Errorf("%s: got vendor event %+v, want unparsed", tc.version, events[1].Body)
+		}
+	}
+}
+
+func TestFailedQuery(t *testing.T) {
+	failed := queryBody("test", "INSERT INTO myisam_t VALUES (1), (1)")
+	binary.LittleEndian.PutUint16(failed[9:], 1062)
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	b.event(binlog.QueryEvent, queryBody("test", "CREATE TABLE t (id int)"))
+	b.event(binlog.QueryEvent, failed)
+
+	events := b.walk(t)
+	ok, bad := events[1].Body.(*binlog.BinQueryEvent), events[2].Body.(*binlog.BinQueryEvent)
+	if ok.Failed() || ok.ErrorMessage() != "" || !strings.HasSuffix(ok.String(), "ErrorCode:0") {
+		t.Errorf("got failed query %s", ok)
+	}
+	if !bad.Failed() || bad.ErrorCode != 1062 || !strings.HasPrefix(bad.ErrorMessage(), "ER_DUP_ENTRY") {
+		t.Errorf("got error code %d, %s", bad.ErrorCode, bad.ErrorMessage())
+	}
+	if !strings.Contains(bad.String(), "ErrorCode:1062 (ER_DUP_ENTRY") {
+		t.Errorf("got string %s", bad)
+	}
+	if binlog.QueryErrorMessage(9999) != "unknown error 9999" {
+		t.Errorf("got message %s", binlog.QueryErrorMessage(9999))
+	}
+
+	option := &binlog.BinReaderOption{SkipFailedQueries: true}
+	for _, events := range [][]*binlog.BinEvent{b.walk(t, option), b.decodeEvents(t, option)} {
+		if len(events) != 2 || events[1].Body.(*binlog.BinQueryEvent).Failed() {
+			t.Errorf("got %d events, want the failed query skipped", len(events))
+		}
+	}
+}
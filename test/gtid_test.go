@@ -0,0 +1,471 @@
+package test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/obgnail/binlog-parser"
+)
+
+var testSID = []byte{0x3e, 0x11, 0xfa, 0x47, 0x71, 0xca, 0x11, 0xe1, 0x9e, 0x33, 0xc8, 0x0a, 0xa9, 0x42, 0x95, 0x62}
+
+const testUUID = "3e11fa47-71ca-11e1-9e33-c80aa9429562"
+
+// gtidBody return the body of GTID_EVENT
+func gtidBody(gno int64) []byte {
+	body := append([]byte{1}, testSID...)
+	body = append(body, make([]byte, 8+17)...)
+	binary.LittleEndian.PutUint64(body[17:], uint64(gno))
+	body[25] = 2
+	binary.LittleEndian.PutUint64(body[34:], uint64(gno))
+	return body
+}
+
+func TestPreviousGTIDs(t *testing.T) {
+	body := appendUint32(appendUint32(nil, 1), 0)
+	body = append(body, testSID...)
+	body = appendUint32(appendUint32(body, 2), 0)
+	for _, n := range []uint32{1, 6, 7, 8} {
+		body = appendUint32(appendUint32(body, n), 0)
+	}
+
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	b.event(binlog.PreviousGTIDEvent, body)
+	events := b.walk(t)
+
+	set := events[1].Body.(*binlog.BinPreGTIDsEvent).GTIDSet
+	if set.String() != testUUID+":1-5:7" {
+		t.Errorf("got previous gtids %s", set)
+	}
+	if !set.Contains(testUUID, 5) || set.Contains(testUUID, 6) {
+		t.Errorf("got wrong Contains of %s", set)
+	}
+}
+
+func TestStartGTID(t *testing.T) {
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	// DDL
+	b.event(binlog.GTIDEvent, gtidBody(1))
+	b.event(binlog.QueryEvent, queryBody("test", "CREATE TABLE user (id INT, name VARCHAR(20), age INT)"))
+	// table map is only written in the skipped transaction
+	b.event(binlog.GTIDEvent, gtidBody(2))
+	b.event(binlog.QueryEvent, queryBody("test", "BEGIN"))
+	userTableMap(b)
+	b.rows(binlog.WriteRowsEventV2, 100, 3, []byte{0x07}, nil, []byte{0x00, 1, 0, 0, 0, 1, 'a', 2, 0, 0, 0})
+	b.event(binlog.XIDEvent, make([]byte, 8))
+	// DML
+	b.event(binlog.GTIDEvent, gtidBody(3))
+	b.event(binlog.QueryEvent, queryBody("test", "BEGIN"))
+	b.rows(binlog.WriteRowsEventV2, 100, 3, []byte{0x07}, nil, []byte{0x00, 2, 0, 0, 0, 1, 'b', 3, 0, 0, 0})
+	b.event(binlog.XIDEvent, make([]byte, 8))
+
+	startGTID := binlog.NewGTIDSet()
+	startGTID.AddInterval(testUUID, binlog.GTIDInterval{Start: 1, Stop: 3})
+	events := b.walk(t, &binlog.BinReaderOption{StartGTID: startGTID})
+
+	if len(events) != 5 {
+		t.Fatalf("got %d events, want 5", len(events))
+	}
+	if gtid, ok := events[1].Body.(*binlog.BinGTIDEvent); !ok || gtid.GTID() != testUUID+":3" || gtid.SequenceNumber != 3 {
+		t.Errorf("got first transaction %+v", events[1].Body)
+	}
+	rows := rowsEvents(events)
+	if len(rows) != 1 || rows[0].Rows[0]["@2"] != "b" {
+		t.Errorf("got rows %v", rows)
+	}
+}
+
+func TestGTIDCommitTimestamp(t *testing.T) {
+	appendTimestamp := func(data []byte, usec uint64) []byte {
+		for i := 0; i < 7; i++ {
+			data = append(data, byte(usec>>(8*i)))
+		}
+		return data
+	}
+	original := uint64(1537611870123456)
+	immediate := original + 1500000
+
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	// the original is the same as the immediate
+	b.event(binlog.GTIDEvent, appendTimestamp(gtidBody(1), original))
+	b.event(binlog.GTIDEvent, appendTimestamp(appendTimestamp(gtidBody(2), immediate|1<<55), original))
+	events := b.walk(t)
+
+	first := events[1].Body.(*binlog.BinGTIDEvent)
+	if !first.OriginalCommitTime.Equal(time.Unix(1537611870, 123456000)) || !first.ImmediateCommitTime.Equal(first.OriginalCommitTime) {
+		t.Errorf("got commit time %s, %s", first.OriginalCommitTime, first.ImmediateCommitTime)
+	}
+	second := events[2].Body.(*binlog.BinGTIDEvent)
+	if !second.OriginalCommitTime.Equal(first.OriginalCommitTime) || second.ImmediateCommitTime.Sub(second.OriginalCommitTime) != 1500*time.Millisecond {
+		t.Errorf("got commit time %s, %s", second.OriginalCommitTime, second.ImmediateCommitTime)
+	}
+}
+
+func TestBinlogDumpGTID(t *testing.T) {
+	set := binlog.NewGTIDSet()
+	set.AddInterval(testUUID, binlog.GTIDInterval{Start: 1, Stop: 6})
+	set.Add(testUUID, 7)
+
+	// the encoded set is the same as the body of PREVIOUS_GTIDS_EVENT
+	encoded, err := set.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	b.event(binlog.PreviousGTIDEvent, encoded)
+	events := b.walk(t)
+	if got := events[1].Body.(*binlog.BinPreGTIDsEvent).GTIDSet.String(); got != set.String() {
+		t.Errorf("got gtid set %s, want %s", got, set)
+	}
+
+	dump := &binlog.BinlogDump{ServerID: 100, FileName: "mysql-bin.000001", Position: 4, GTIDSet: set}
+	data, err := dump.Command()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data[0] != binlog.ComBinlogDumpGTID || binary.LittleEndian.Uint16(data[1:])&binlog.BinlogThroughGTID == 0 {
+		t.Errorf("got command %x", data[:3])
+	}
+	pos := 7 + 4 + len(dump.FileName)
+	if binary.LittleEndian.Uint32(data[3:]) != 100 || binary.LittleEndian.Uint64(data[pos:]) != 4 {
+		t.Errorf("got command %x", data)
+	}
+	if size := binary.LittleEndian.Uint32(data[pos+8:]); !bytes.Equal(data[pos+12:], encoded) || int(size) != len(encoded) {
+		t.Errorf("got gtid set data %x, want %x", data[pos+12:], encoded)
+	}
+
+	if _, err = (&binlog.BinlogDump{GTIDSet: &binlog.GTIDSet{Sets: map[string][]binlog.GTIDInterval{"bad": {{Start: 1, Stop: 2}}}}}).Command(); err == nil {
+		t.Errorf("got no error of invalid uuid")
+	}
+}
+
+func TestRowsEventMicrosecondTime(t *testing.T) {
+	// original_commit_timestamp 1537611870.123456, the builder writes timestamp 1537611870
+	gtid := gtidBody(1)
+	for usec, i := uint64(1537611870123456), 0; i < 7; i++ {
+		gtid = append(gtid, byte(usec>>(8*i)))
+	}
+	row := []byte{0x04, 1, 0, 0, 0, 1, 'a'}
+
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	userTableMap(b)
+	b.event(binlog.GTIDEvent, gtid)
+	b.event(binlog.QueryEvent, queryBody("test", "BEGIN"))
+	b.rows(binlog.WriteRowsEventV2, 100, 3, []byte{0x07}, nil, row)
+	b.event(binlog.XIDEvent, make([]byte, 8))
+	// Q_MICROSECONDS 654321 of BEGIN
+	b.event(binlog.QueryEvent, queryBody("test", "BEGIN", binlog.QMicroseconds, 0xf1, 0xfb, 0x09))
+	b.rows(binlog.WriteRowsEventV2, 100, 3, []byte{0x07}, nil, row)
+	b.event(binlog.XIDEvent, make([]byte, 8))
+	// no microseconds
+	b.event(binlog.QueryEvent, queryBody("test", "BEGIN"))
+	b.rows(binlog.WriteRowsEventV2, 100, 3, []byte{0x07}, nil, row)
+	b.event(binlog.XIDEvent, make([]byte, 8))
+
+	var times []time.Time
+	for _, event := range b.walk(t) {
+		if _, ok := event.Body.(*binlog.BinRowsEvent); ok {
+			times = append(times, event.Time())
+		}
+	}
+	want := []time.Time{time.Unix(1537611870, 123456000), time.Unix(1537611870, 654321000), time.Unix(1537611870, 0)}
+	if len(times) != len(want) {
+		t.Fatalf("got %d rows events, want %d", len(times), len(want))
+	}
+	for i := range want {
+		if !times[i].Equal(want[i]) {
+			t.Errorf("got time %s of rows event %d, want %s", times[i], i, want[i])
+		}
+	}
+}
+
+func TestWalkTransaction(t *testing.T) {
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	b.event(binlog.GTIDEvent, gtidBody(1))
+	b.event(binlog.QueryEvent, queryBody("test", "BEGIN"))
+	userTableMap(b)
+	b.rows(binlog.WriteRowsEventV2, 100, 3, []byte{0x07}, nil, []byte{0x04, 1, 0, 0, 0, 1, 'a'})
+	b.event(binlog.XIDEvent, make([]byte, 8))
+	b.event(binlog.GTIDEvent, gtidBody(2))
+	b.event(binlog.QueryEvent, queryBody("test", "CREATE TABLE t (id int)"))
+	b.event(binlog.QueryEvent, queryBody("test", "BEGIN"))
+	b.event(binlog.QueryEvent, queryBody("test", "ROLLBACK"))
+	// incomplete
+	b.event(binlog.GTIDEvent, gtidBody(3))
+	b.event(binlog.QueryEvent, queryBody("test", "BEGIN"))
+
+	decoder, err := binlog.NewBinFileDecoder(b.file(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var txs []*binlog.Transaction
+	err = decoder.WalkTransaction(func(tx *binlog.Transaction) (isContinue bool, err error) {
+		txs = append(txs, tx)
+		return true, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txs) != 3 {
+		t.Fatalf("got %d transactions, want 3", len(txs))
+	}
+
+	for i, want := range []struct {
+		gtid     string
+		events   int
+		sequence int64
+		rollback bool
+	}{
+		{testUUID + ":1", 5, 1, false},
+		{testUUID + ":2", 2, 2, false},
+		{"", 2, 0, true},
+	} {
+		tx := txs[i]
+		if tx.GTID != want.gtid || len(tx.Events) != want.events || tx.Order.SequenceNumber != want.sequence || tx.Rollback != want.rollback {
+			t.Errorf("transaction %d: got gtid %q, %d events, sequence %d, rollback %v",
+				i, tx.GTID, len(tx.Events), tx.Order.SequenceNumber, tx.Rollback)
+		}
+		if tx.Order.FileIndex != 1 || tx.Order.File != "mysql-bin.000001" || tx.Order.Position != tx.Events[len(tx.Events)-1].EndPosition() {
+			t.Errorf("transaction %d: got order %+v", i, tx.Order)
+		}
+		if i > 0 && !txs[i-1].Order.Less(tx.Order) {
+			t.Errorf("transaction %d: got order %s not after %s", i, tx.Order, txs[i-1].Order)
+		}
+	}
+	if txs[0].End.ID.Kind != binlog.TransactionIDXID {
+		t.Errorf("got transaction end %+v", txs[0].End)
+	}
+}
+
+func TestWalkLargeTransaction(t *testing.T) {
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	b.event(binlog.GTIDEvent, gtidBody(1))
+	b.event(binlog.QueryEvent, queryBody("test", "BEGIN"))
+	userTableMap(b)
+	for i := byte(1); i <= 4; i++ {
+		b.rows(binlog.WriteRowsEventV2, 100, 3, []byte{0x07}, nil, []byte{0x04, i, 0, 0, 0, 1, 'a'})
+	}
+	b.event(binlog.XIDEvent, make([]byte, 8))
+	b.event(binlog.GTIDEvent, gtidBody(2))
+	b.event(binlog.QueryEvent, queryBody("test", "CREATE TABLE t (id int)"))
+	path := b.file(t)
+
+	events := b.walk(t)
+	limit := events[1].Header.EventSize + events[2].Header.EventSize
+
+	decoder, err := binlog.NewBinFileDecoder(path, &binlog.BinReaderOption{MaxTransactionSize: limit, StreamLargeTransactions: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parts []*binlog.Transaction
+	err = decoder.WalkTransaction(func(tx *binlog.Transaction) (isContinue bool, err error) {
+		parts = append(parts, tx)
+		return true, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(parts) < 3 {
+		t.Fatalf("got %d parts, want the first transaction split", len(parts))
+	}
+	last := len(parts) - 2
+	var count int
+	for i, part := range parts[:last+1] {
+		var size int64
+		for _, event := range part.Events[:len(part.Events)-1] {
+			size += event.Header.EventSize
+		}
+		if part.GTID != testUUID+":1" || part.Part != i || part.Continues != (i < last) || (part.End != nil) != (i == last) || size > limit {
+			t.Errorf("part %d: got gtid %q, part %d, continues %v, end %+v, %d bytes", i, part.GTID, part.Part, part.Continues, part.End, size)
+		}
+		count += len(part.Events)
+	}
+	if count != 8 {
+		t.Errorf("got %d events in parts, want 8", count)
+	}
+	if ddl := parts[len(parts)-1]; ddl.GTID != testUUID+":2" || ddl.Part != 0 || ddl.Continues || len(ddl.Events) != 2 {
+		t.Errorf("got transaction %+v after the split one", ddl)
+	}
+	if s := parts[0].String(); !strings.HasPrefix(s, "GTID "+testUUID+":1, part 0, continues\n") {
+		t.Errorf("got part string %q", s)
+	}
+
+	decoder, err = binlog.NewBinFileDecoder(path, &binlog.BinReaderOption{MaxTransactionSize: limit})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = decoder.WalkTransaction(func(tx *binlog.Transaction) (isContinue bool, err error) {
+		t.Errorf("got transaction %+v, want error", tx)
+		return true, nil
+	})
+	if !errors.Is(err, binlog.ErrTransactionTooLarge) {
+		t.Errorf("got error %v, want ErrTransactionTooLarge", err)
+	}
+}
+
+func TestAnalyzeGTIDs(t *testing.T) {
+	dir := t.TempDir()
+	writeBinlog := func(name string, previous *binlog.GTIDSet, gnos ...int64) string {
+		encoded, err := previous.Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+		b.event(binlog.PreviousGTIDEvent, encoded)
+		for _, gno := range gnos {
+			b.event(binlog.GTIDEvent, gtidBody(gno))
+			b.event(binlog.QueryEvent, queryBody("test", "CREATE TABLE t (id int)"))
+		}
+		return b.writeFile(t, filepath.Join(dir, name))
+	}
+	set := func(intervals ...binlog.GTIDInterval) *binlog.GTIDSet {
+		s := binlog.NewGTIDSet()
+		for _, interval := range intervals {
+			s.AddInterval(testUUID, interval)
+		}
+		return s
+	}
+
+	paths := []string{
+		// 1-2 are purged
+		writeBinlog("mysql-bin.000001", set(binlog.GTIDInterval{Start: 1, Stop: 3}), 3, 4),
+		// continuous
+		writeBinlog("mysql-bin.000002", set(binlog.GTIDInterval{Start: 1, Stop: 5}), 5),
+		// 6-7 are missing, 5 is unclaimed
+		writeBinlog("mysql-bin.000003", set(binlog.GTIDInterval{Start: 1, Stop: 5}, binlog.GTIDInterval{Start: 6, Stop: 8}), 8),
+	}
+	report, err := binlog.AnalyzeGTIDs(paths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Purged.String() != testUUID+":1-2" {
+		t.Errorf("got purged %s", report.Purged)
+	}
+	if len(report.Binlogs) != 3 || report.Binlogs[2].Contained.String() != testUUID+":8" {
+		t.Fatalf("got binlogs %+v", report.Binlogs)
+	}
+	if len(report.Gaps) != 1 {
+		t.Fatalf("got %d gaps, want 1", len(report.Gaps))
+	}
+	gap := report.Gaps[0]
+	if gap.PrevPath != paths[1] || gap.Path != paths[2] {
+		t.Errorf("got gap between %s and %s", gap.PrevPath, gap.Path)
+	}
+	if gap.Missing.String() != testUUID+":6-7" || gap.Unclaimed.String() != testUUID+":5" {
+		t.Errorf("got missing %s, unclaimed %s", gap.Missing, gap.Unclaimed)
+	}
+
+	// the options of walking are not applied
+	ddl := 0
+	filtered, err := binlog.AnalyzeGTIDs(paths, &binlog.BinReaderOption{
+		IgnoreServerIDs: []int64{1},
+		StopFunc:        func(event *binlog.BinEvent) bool { return event.Header.EventType == binlog.GTIDEvent },
+		OnDDL:           func(schema, query string) { ddl++ },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ddl != 0 {
+		t.Errorf("got %d DDL", ddl)
+	}
+	for i, gtids := range filtered.Binlogs {
+		if gtids.Executed().String() != report.Binlogs[i].Executed().String() {
+			t.Errorf("got executed %s of %s, want %s", gtids.Executed(), gtids.Path, report.Binlogs[i].Executed())
+		}
+	}
+	if filtered.Purged.String() != report.Purged.String() || len(filtered.Gaps) != len(report.Gaps) {
+		t.Errorf("got purged %s and %d gaps", filtered.Purged, len(filtered.Gaps))
+	}
+}
+
+func TestGTIDSetOperations(t *testing.T) {
+	const otherUUID = "4f22fb58-82db-22f2-af44-d91bba53a673"
+	set, err := binlog.ParseGTIDSet(testUUID + ":1-3:5-6,\n" + otherUUID + ":1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// overlapping 3-5 and adjacent 7 are merged
+	set.AddInterval(testUUID, binlog.GTIDInterval{Start: 3, Stop: 6})
+	set.Add(testUUID, 7)
+	if set.String() != testUUID+":1-7,"+otherUUID+":1" {
+		t.Errorf("got merged set %s", set)
+	}
+
+	other, err := binlog.ParseGTIDSet(testUUID + ":4-10:12")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if union := set.Union(other); union.String() != testUUID+":1-10:12,"+otherUUID+":1" {
+		t.Errorf("got union %s", union)
+	}
+	if diff := set.Subtract(other); diff.String() != testUUID+":1-3,"+otherUUID+":1" {
+		t.Errorf("got subtract %s", diff)
+	}
+	if diff := other.Subtract(set); diff.String() != testUUID+":8-10:12" {
+		t.Errorf("got subtract %s", diff)
+	}
+	// the operands are not changed
+	if set.String() != testUUID+":1-7,"+otherUUID+":1" || other.String() != testUUID+":4-10:12" {
+		t.Errorf("got changed operands %s, %s", set, other)
+	}
+
+	if !set.Contains(strings.ToUpper(testUUID), 7) || set.Contains(testUUID, 8) || set.Contains(otherUUID, 2) {
+		t.Errorf("got wrong Contains of %s", set)
+	}
+	if !set.Union(other).ContainsSet(other) || set.ContainsSet(other) {
+		t.Errorf("got wrong ContainsSet of %s", set)
+	}
+	if parsed, err := binlog.ParseGTIDSet(set.String()); err != nil || !parsed.Equal(set) {
+		t.Errorf("got parsed %s, %v", parsed, err)
+	}
+	if empty, err := binlog.ParseGTIDSet(""); err != nil || !empty.IsEmpty() {
+		t.Errorf("got empty set %s, %v", empty, err)
+	}
+	for _, invalid := range []string{"bad:1", testUUID, testUUID + ":0", testUUID + ":5-3", testUUID + ":a"} {
+		if _, err := binlog.ParseGTIDSet(invalid); err == nil {
+			t.Errorf("got no error of %q", invalid)
+		}
+	}
+}
+
+func TestTransactionString(t *testing.T) {
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	b.event(binlog.GTIDEvent, gtidBody(1))
+	b.event(binlog.QueryEvent, queryBody("test", "BEGIN"))
+	userTableMap(b)
+	b.rows(binlog.WriteRowsEventV2, 100, 3, []byte{0x07}, nil, []byte{0x04, 1, 0, 0, 0, 1, 'a'})
+	b.rows(binlog.UpdateRowsEventV2, 100, 3, []byte{0x07}, []byte{0x03}, []byte{0x04, 1, 0, 0, 0, 1, 'a', 0x00, 1, 0, 0, 0, 2, 'b', '\''})
+	b.event(binlog.XIDEvent, []byte{5, 0, 0, 0, 0, 0, 0, 0})
+
+	decoder, err := binlog.NewBinFileDecoder(b.file(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var txs []*binlog.Transaction
+	err = decoder.WalkTransaction(func(tx *binlog.Transaction) (isContinue bool, err error) {
+		txs = append(txs, tx)
+		return true, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txs) != 1 {
+		t.Fatalf("got %d transactions, want 1", len(txs))
+	}
+
+	want := fmt.Sprintf("GTID %s:1, XID 5, committed at %s (%s)\n", testUUID, txs[0].Order.Timestamp.Format("2006-01-02 15:04:05.999999 -0700"), txs[0].Order) +
+		"  [test] BEGIN\n" +
+		"  INSERT `test`.`user` (`@1`=1, `@2`='a', `@3`=NULL)\n" +
+		"  UPDATE `test`.`user` (`@1`=1, `@2`='a', `@3`=NULL) => (`@1`=1, `@2`='b\\'')\n" +
+		"  COMMIT /* XID 5 */\n"
+	if got := txs[0].String(); got != want {
+		t.Errorf("got transaction\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,91 @@
+package test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/obgnail/binlog-parser"
+)
+
+// jsonDoc return the JSONB of {"a": a, "b": [true, "x"]}
+func jsonDoc(a byte) []byte {
+	return []byte{
+		// small object, count 2, size 32
+		0x00, 2, 0, 32, 0,
+		// key entries
+		18, 0, 1, 0,
+		19, 0, 1, 0,
+		// value entries: inlined int16, small array at 20
+		0x05, a, 0,
+		0x02, 20, 0,
+		// keys
+		'a', 'b',
+		// small array, count 2, size 12
+		2, 0, 12, 0,
+		// value entries: inlined literal true, string at 10
+		0x04, 0x01, 0,
+		0x0c, 10, 0,
+		1, 'x',
+	}
+}
+
+func TestFullImageJSONUpdate(t *testing.T) {
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+
+	// CREATE TABLE test.doc (id INT, doc JSON)
+	b.tableMap(101, "test", "doc",
+		[]byte{binlog.MySQLTypeLong, binlog.MySQLTypeJSON},
+		[]byte{4},
+		[]byte{0x02},
+	)
+
+	// UPDATE test.doc SET doc = JSON_SET(doc, '$.a', 2) WHERE id = 1
+	var rows []byte
+	for _, a := range []byte{1, 2} {
+		doc := jsonDoc(a)
+		rows = append(rows, 0x00, 1, 0, 0, 0)
+		rows = appendUint32(rows, uint32(len(doc)))
+		rows = append(rows, doc...)
+	}
+	b.rows(binlog.UpdateRowsEventV2, 101, 2, []byte{0x03}, []byte{0x03}, rows)
+
+	events := rowsEvents(b.walk(t))
+	if len(events) != 1 {
+		t.Fatalf("got %d rows events, want 1", len(events))
+	}
+
+	want := []map[string]interface{}{
+		{"@1": int32(1), "@2": map[string]interface{}{"a": int64(1), "b": []interface{}{true, "x"}}},
+		{"@1": int32(1), "@2": map[string]interface{}{"a": int64(2), "b": []interface{}{true, "x"}}},
+	}
+	if !reflect.DeepEqual(events[0].Rows, want) {
+		t.Fatalf("got rows %v, want %v", events[0].Rows, want)
+	}
+}
+
+func TestJSONGet(t *testing.T) {
+	doc := map[string]interface{}{
+		"a":   map[string]interface{}{"b": []interface{}{int64(1), "x"}},
+		"c d": nil,
+	}
+	cases := []struct {
+		path  string
+		value interface{}
+		ok    bool
+	}{
+		{"$", doc, true},
+		{"$.a.b[0]", int64(1), true},
+		{"$.a.b[1]", "x", true},
+		{`$."c d"`, nil, true},
+		{"$.a.b[2]", nil, false},
+		{"$.a.c", nil, false},
+		{"$.a[0]", nil, false},
+		{"a.b", nil, false},
+	}
+	for _, c := range cases {
+		value, ok := binlog.JSONGet(doc, c.path)
+		if ok != c.ok || !reflect.DeepEqual(value, c.value) {
+			t.Errorf("%s got %v, %v, want %v, %v", c.path, value, ok, c.value, c.ok)
+		}
+	}
+}
@@ -0,0 +1,980 @@
+package test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/obgnail/binlog-parser"
+)
+
+// CREATE TABLE test.user (id INT, name VARCHAR(20), age INT NULL)
+func userTableMap(b *binlogBuilder) {
+	b.tableMap(100, "test", "user",
+		[]byte{binlog.MySQLTypeLong, binlog.MySQLTypeVarchar, binlog.MySQLTypeLong},
+		[]byte{20, 0},
+		[]byte{0x04},
+	)
+}
+
+func TestMinimalUpdateRows(t *testing.T) {
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	userTableMap(b)
+
+	// UPDATE test.user SET name = 'bob', age = NULL WHERE id = 1
+	// binlog_row_image=MINIMAL: before image has only PK, after image has only changed columns
+	b.rows(binlog.UpdateRowsEventV2, 100, 3, []byte{0x01}, []byte{0x06}, []byte{
+		// before image: null bitmap, id
+		0x00, 1, 0, 0, 0,
+		// after image: null bitmap (age is NULL), name
+		0x02, 3, 'b', 'o', 'b',
+	})
+
+	rows := rowsEvents(b.walk(t))
+	if len(rows) != 1 {
+		t.Fatalf("got %d rows events, want 1", len(rows))
+	}
+
+	if table := rows[0].TableMap(); table == nil || table.Schema != "test" || table.Table != "user" {
+		t.Fatalf("got table map %v, want test.user", table)
+	}
+
+	want := []map[string]interface{}{
+		{"@1": int32(1)},
+		{"@2": "bob", "@3": nil},
+	}
+	if !reflect.DeepEqual(rows[0].Rows, want) {
+		t.Fatalf("got rows %v, want %v", rows[0].Rows, want)
+	}
+
+	// absent columns are omitted, NULL columns are nil
+	if _, ok := rows[0].Rows[0]["@2"]; ok {
+		t.Errorf("absent column @2 should be omitted from before image")
+	}
+	if v, ok := rows[0].Rows[1]["@3"]; !ok || v != nil {
+		t.Errorf("NULL column @3 should be nil in after image, got %v, %v", v, ok)
+	}
+}
+
+func TestNullableAndNullValue(t *testing.T) {
+	// id INT NOT NULL, name VARCHAR(20) NOT NULL, age INT NULL
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	userTableMap(b)
+	// INSERT INTO test.user VALUES (1, 'a', NULL), (2, 'b', 30)
+	b.rows(binlog.WriteRowsEventV2, 100, 3, []byte{0x07}, nil, []byte{
+		0x04, 1, 0, 0, 0, 1, 'a',
+		0x00, 2, 0, 0, 0, 1, 'b', 30, 0, 0, 0,
+	})
+
+	rows := rowsEvents(b.walk(t))
+	if len(rows) != 1 {
+		t.Fatalf("got %d rows events, want 1", len(rows))
+	}
+	table := rows[0].TableMap()
+	if table.Nullable(0) || table.Nullable(1) || !table.Nullable(2) {
+		t.Errorf("got nullable bitmap %08b, want only age", table.NullBitmap)
+	}
+	want := []map[string]interface{}{
+		{"@1": int32(1), "@2": "a", "@3": nil},
+		{"@1": int32(2), "@2": "b", "@3": int32(30)},
+	}
+	if !reflect.DeepEqual(rows[0].Rows, want) {
+		t.Errorf("got rows %v, want %v", rows[0].Rows, want)
+	}
+
+	// the NULL of a NOT NULL column is only invalid if strict
+	b = newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	userTableMap(b)
+	b.rows(binlog.WriteRowsEventV2, 100, 3, []byte{0x07}, nil, []byte{0x01, 1, 'a', 30, 0, 0, 0})
+	rows = rowsEvents(b.walk(t))
+	if len(rows) != 1 || !reflect.DeepEqual(rows[0].Rows[0], map[string]interface{}{"@1": nil, "@2": "a", "@3": int32(30)}) {
+		t.Errorf("got rows %v without StrictNullability", rows)
+	}
+	decoder, err := binlog.NewBinFileDecoder(b.file(t), &binlog.BinReaderOption{StrictNullability: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = decoder.WalkEvent(func(event *binlog.BinEvent) (isContinue bool, err error) { return true, nil })
+	if err == nil || !strings.Contains(err.Error(), "declared NOT NULL") {
+		t.Errorf("got error %v, want NOT NULL violation", err)
+	}
+}
+
+func TestMinimalUpdateRowsNullBitmapSizes(t *testing.T) {
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	types := make([]byte, 10)
+	for i := range types {
+		types[i] = binlog.MySQLTypeLong
+	}
+	b.tableMap(101, "test", "wide", types, nil, []byte{0xff, 0x03})
+
+	// 2 rows, before image has only PK @1 (1 byte NULL-bitmap),
+	// after image has @2..@10 (2 bytes NULL-bitmap), @10 is NULL
+	after := func(v byte) []byte {
+		image := []byte{0x00, 0x01}
+		for i := 0; i < 8; i++ {
+			image = append(image, v+byte(i), 0, 0, 0)
+		}
+		return image
+	}
+	data := append([]byte{0x00, 1, 0, 0, 0}, after(10)...)
+	data = append(append(data, 0x00, 2, 0, 0, 0), after(20)...)
+	b.rows(binlog.UpdateRowsEventV2, 101, 10, []byte{0x01, 0x00}, []byte{0xfe, 0x03}, data)
+
+	rows := rowsEvents(b.walk(t))
+	if len(rows) != 1 || len(rows[0].Rows) != 4 {
+		t.Fatalf("got rows events %v, want 4 images", rows)
+	}
+	for i, id := range []int32{1, 2} {
+		before, after := rows[0].Rows[2*i], rows[0].Rows[2*i+1]
+		if !reflect.DeepEqual(before, map[string]interface{}{"@1": id}) {
+			t.Errorf("got before image %v", before)
+		}
+		if len(after) != 9 || after["@2"] != id*10 || after["@9"] != id*10+7 || after["@10"] != nil {
+			t.Errorf("got after image %v", after)
+		}
+	}
+}
+
+func TestColumnNames(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "columns.json")
+	if err := os.WriteFile(path, []byte(`{"test.user": ["id", "name"]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	columnNames, err := binlog.LoadColumnNames(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	userTableMap(b)
+	b.rows(binlog.WriteRowsEventV2, 100, 3, []byte{0x07}, nil, []byte{0x00, 1, 0, 0, 0, 1, 'a', 2, 0, 0, 0})
+
+	rows := rowsEvents(b.walk(t, &binlog.BinReaderOption{ColumnNames: columnNames}))
+	if len(rows) != 1 {
+		t.Fatalf("got %d rows events, want 1", len(rows))
+	}
+
+	// the column without hint falls back to positional name
+	want := map[string]interface{}{"id": int32(1), "name": "a", "@3": int32(2)}
+	if !reflect.DeepEqual(rows[0].Rows[0], want) {
+		t.Fatalf("got row %v, want %v", rows[0].Rows[0], want)
+	}
+}
+
+// optionalMeta return the optional metadata field
+func optionalMeta(t byte, value ...byte) []byte {
+	return append([]byte{t, byte(len(value))}, value...)
+}
+
+func TestTableMapPrimaryKey(t *testing.T) {
+	names := optionalMeta(binlog.TableMapOptColumnName, 2, 'i', 'd', 4, 'n', 'a', 'm', 'e', 3, 'a', 'g', 'e')
+	for _, tc := range []struct {
+		meta       []byte
+		wantPrefix []int
+	}{
+		{optionalMeta(binlog.TableMapOptSimplePrimaryKey, 0, 1), []int{0, 0}},
+		{optionalMeta(binlog.TableMapOptPrimaryKeyWithPrefix, 0, 0, 1, 10), []int{0, 10}},
+	} {
+		b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+		b.tableMap(100, "test", "user",
+			[]byte{binlog.MySQLTypeLong, binlog.MySQLTypeVarchar, binlog.MySQLTypeLong},
+			[]byte{20, 0},
+			append(append([]byte{0x04}, names...), tc.meta...),
+		)
+		b.rows(binlog.WriteRowsEventV2, 100, 3, []byte{0x07}, nil, []byte{0x04, 1, 0, 0, 0, 1, 'a'})
+
+		rows := rowsEvents(b.walk(t))
+		if len(rows) != 1 {
+			t.Fatalf("got %d rows events, want 1", len(rows))
+		}
+
+		table := rows[0].TableMap()
+		if !reflect.DeepEqual(table.PrimaryKey, []int{0, 1}) || !reflect.DeepEqual(table.PrimaryKeyPrefix, tc.wantPrefix) {
+			t.Errorf("got primary key %v prefix %v, want [0 1] %v", table.PrimaryKey, table.PrimaryKeyPrefix, tc.wantPrefix)
+		}
+
+		want := map[string]interface{}{"id": int32(1), "name": "a", "age": nil}
+		if !reflect.DeepEqual(rows[0].Rows[0], want) {
+			t.Errorf("got row %v, want %v", rows[0].Rows[0], want)
+		}
+	}
+}
+
+func TestAnonymizer(t *testing.T) {
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	userTableMap(b)
+	b.rows(binlog.WriteRowsEventV2, 100, 3, []byte{0x07}, nil, []byte{0x04, 1, 0, 0, 0, 3, 'b', 'o', 'b'})
+
+	rows := rowsEvents(b.walk(t, &binlog.BinReaderOption{ColumnNames: map[string][]string{"test.user": {"id", "name", "age"}}}))
+	anonymizer := binlog.NewAnonymizer([]string{"test.user.id", "test.user.name", "test.user.age"})
+	anonymizer.Maskers[binlog.MySQLTypeLong] = func(value interface{}, fieldType binlog.FieldType) interface{} { return int32(-1) }
+	anonymizer.Mask(rows[0])
+
+	// NULL age is kept
+	want := map[string]interface{}{"id": int32(-1), "name": "XXX", "age": nil}
+	if !reflect.DeepEqual(rows[0].Rows[0], want) {
+		t.Errorf("got row %v, want %v", rows[0].Rows[0], want)
+	}
+}
+
+func TestAnonymizerMaskBinlog(t *testing.T) {
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	userTableMap(b)
+	b.rows(binlog.WriteRowsEventV2, 100, 3, []byte{0x07}, nil, []byte{0x04, 1, 0, 0, 0, 3, 'b', 'o', 'b'})
+	b.rows(binlog.UpdateRowsEventV2, 100, 3, []byte{0x07}, []byte{0x07},
+		[]byte{0x04, 1, 0, 0, 0, 3, 'b', 'o', 'b', 0x00, 1, 0, 0, 0, 5, 'a', 'l', 'i', 'c', 'e', 30, 0, 0, 0})
+
+	// CREATE TABLE test.k (a JSON, b DECIMAL(10,2), c DATETIME(3), d TIMESTAMP(6), e TIME(2),
+	// f BIT(11), g ENUM('x','y'), h BLOB)
+	names := optionalMeta(binlog.TableMapOptColumnName, 1, 'a', 1, 'b', 1, 'c', 1, 'd', 1, 'e', 1, 'f', 1, 'g', 1, 'h')
+	enums := optionalMeta(binlog.TableMapOptEnumStrValue, 2, 1, 'x', 1, 'y')
+	b.tableMap(105, "test", "k",
+		[]byte{binlog.MySQLTypeJSON, binlog.MySQLTypeNewDecimal, binlog.MySQLTypeDatetime2, binlog.MySQLTypeTimestamp2,
+			binlog.MySQLTypeTime2, binlog.MySQLTypeBit, binlog.MySQLTypeString, binlog.MySQLTypeBlob},
+		[]byte{4, 10, 2, 3, 6, 2, 3, 1, binlog.MySQLTypeEnum, 1, 2},
+		append(append([]byte{0xff}, names...), enums...))
+	b.rows(binlog.WriteRowsEventV2, 105, 8, []byte{0xff}, nil, []byte{0x00,
+		// {"a": 1, "b": "cd"}
+		24, 0, 0, 0, 0x00, 2, 0, 23, 0, 18, 0, 1, 0, 19, 0, 1, 0, 0x05, 1, 0, 0x0c, 20, 0, 'a', 'b', 2, 'c', 'd',
+		0x80, 0, 0, 1, 5, // 1.05
+		0x99, 0xa0, 0xec, 0x10, 0x00, 0x04, 0xce, // 2018-09-22 01:00:00.123
+		0x5b, 0xa5, 0xf6, 0x5e, 0x01, 0xe2, 0x40, // 1537603166.123456
+		0x80, 0x10, 0x00, 0x32, // 01:00:00.50
+		0x04, 0x01, // b'10000000001'
+		2, // 'y'
+		2, 0, 'h', 'i',
+	})
+	before := b.buf.Len()
+
+	anonymizer := binlog.NewAnonymizer([]string{"test.user.name", "test.user.age",
+		"test.k.a", "test.k.b", "test.k.c", "test.k.d", "test.k.e", "test.k.f", "test.k.g", "test.k.h"})
+	anonymizer.ColumnNames = map[string][]string{"test.user": {"id", "name", "age"}}
+	var out bytes.Buffer
+	if err := anonymizer.MaskBinlog(bytes.NewReader(b.buf.Bytes()), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Len() != before {
+		t.Fatalf("got masked binary log of %d bytes, want %d", out.Len(), before)
+	}
+
+	// the checksums are verified
+	decoder, err := binlog.NewBinReaderDecoder(&out, &binlog.BinReaderOption{ColumnNames: anonymizer.ColumnNames})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rows []*binlog.BinRowsEvent
+	err = decoder.WalkEvent(func(event *binlog.BinEvent) (isContinue bool, err error) {
+		if e, ok := event.Body.(*binlog.BinRowsEvent); ok {
+			rows = append(rows, e)
+		}
+		return true, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]map[string]interface{}{
+		{{"id": int32(1), "name": "XXX", "age": nil}},
+		{{"id": int32(1), "name": "XXX", "age": nil}, {"id": int32(1), "name": "XXXXX", "age": int32(0)}},
+		{{
+			"a": map[string]interface{}{"a": int64(0), "b": "XX"},
+			"b": "0.00",
+			"c": "0000-00-00 00:00:00.000",
+			"d": time.Unix(0, 0).UTC(),
+			"e": time.Duration(0),
+			"f": uint64(0),
+			"g": "",
+			"h": []byte("XX"),
+		}},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("got %d rows events, want %d", len(rows), len(want))
+	}
+	for i, w := range want {
+		if !reflect.DeepEqual(rows[i].Rows, w) {
+			t.Errorf("rows event %d: got %v, want %v", i, rows[i].Rows, w)
+		}
+	}
+
+	// the values of custom maskers are re-encoded
+	custom := map[binlog.FieldType]interface{}{
+		binlog.MySQLTypeJSON:       map[string]interface{}{"a": int64(-2), "b": "zz"},
+		binlog.MySQLTypeNewDecimal: "-3.25",
+		binlog.MySQLTypeDatetime2:  "2020-01-02 03:04:05.678",
+		binlog.MySQLTypeTime2:      -(time.Hour + 500*time.Millisecond),
+		binlog.MySQLTypeEnum:       "x",
+	}
+	for fieldType, value := range custom {
+		value := value
+		anonymizer.Maskers[fieldType] = func(interface{}, binlog.FieldType) interface{} { return value }
+	}
+	out.Reset()
+	if err := anonymizer.MaskBinlog(bytes.NewReader(b.buf.Bytes()), &out); err != nil {
+		t.Fatal(err)
+	}
+	events := rowsEvents((&binlogBuilder{buf: out}).walk(t))
+	row := events[len(events)-1].Rows[0]
+	for name, value := range map[string]interface{}{"a": custom[binlog.MySQLTypeJSON], "b": "-3.25",
+		"c": "2020-01-02 03:04:05.678", "e": custom[binlog.MySQLTypeTime2], "g": "x"} {
+		if !reflect.DeepEqual(row[name], value) {
+			t.Errorf("got column %s %v, want %v", name, row[name], value)
+		}
+	}
+
+	// the masked value must keep its size
+	anonymizer.Maskers[binlog.MySQLTypeVarchar] = func(value interface{}, fieldType binlog.FieldType) interface{} { return "-" }
+	if err := anonymizer.MaskBinlog(bytes.NewReader(b.buf.Bytes()), io.Discard); err == nil {
+		t.Errorf("got no error of masked value in different size")
+	}
+}
+
+func TestDefaultMasker(t *testing.T) {
+	for _, tc := range []struct {
+		value     interface{}
+		fieldType binlog.FieldType
+		want      interface{}
+	}{
+		{"bob", binlog.MySQLTypeVarchar, "XXX"},
+		{[]byte("bob"), binlog.MySQLTypeBlob, []byte("XXX")},
+		{"12.50", binlog.MySQLTypeNewDecimal, "0"},
+		{int32(7), binlog.MySQLTypeLong, int32(0)},
+		{3.5, binlog.MySQLTypeDouble, 0.0},
+		{"2018-09-22", binlog.MySQLTypeDate, "0000-00-00"},
+		{"y", binlog.MySQLTypeEnum, ""},
+		{int64(2), binlog.MySQLTypeEnum, int64(0)},
+	} {
+		if got := binlog.DefaultMasker(tc.value, tc.fieldType); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("DefaultMasker(%v, %d) = %v (%T), want %v (%T)", tc.value, tc.fieldType, got, got, tc.want, tc.want)
+		}
+	}
+}
+
+func TestEventTypeFilter(t *testing.T) {
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	b.event(binlog.QueryEvent, queryBody("test", "BEGIN"))
+	userTableMap(b)
+	b.rows(binlog.WriteRowsEventV2, 100, 3, []byte{0x07}, nil, []byte{0x00, 1, 0, 0, 0, 1, 'a', 2, 0, 0, 0})
+	b.event(binlog.XIDEvent, make([]byte, 8))
+
+	events := b.walk(t, &binlog.BinReaderOption{
+		EventTypeFilter: func(eventType binlog.EventType) bool { return eventType == binlog.WriteRowsEventV2 },
+	})
+
+	// FORMAT_DESCRIPTION_EVENT and TABLE_MAP_EVENT are never filtered
+	var types []binlog.EventType
+	for _, event := range events {
+		types = append(types, event.Header.EventType)
+	}
+	want := []binlog.EventType{binlog.FormatDescriptionEvent, binlog.TableMapEvent, binlog.WriteRowsEventV2}
+	if !reflect.DeepEqual(types, want) {
+		t.Errorf("got event types %v, want %v", types, want)
+	}
+}
+
+func TestRowImageType(t *testing.T) {
+	for _, tc := range []struct {
+		bitmap byte
+		image  []byte
+		want   binlog.RowImageType
+	}{
+		{0x07, []byte{0x00, 1, 0, 0, 0, 1, 'a', 2, 0, 'x', 'y'}, binlog.RowImageFull},
+		{0x03, []byte{0x00, 1, 0, 0, 0, 1, 'a'}, binlog.RowImageNoBlob},
+		{0x01, []byte{0x00, 1, 0, 0, 0}, binlog.RowImageMinimal},
+	} {
+		// INT, VARCHAR(20), BLOB
+		b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+		b.tableMap(100, "test", "doc",
+			[]byte{binlog.MySQLTypeLong, binlog.MySQLTypeVarchar, binlog.MySQLTypeBlob},
+			[]byte{20, 0, 2},
+			[]byte{0x00},
+		)
+		b.rows(binlog.DeleteRowsEventV2, 100, 3, []byte{tc.bitmap}, nil, tc.image)
+
+		rows := rowsEvents(b.walk(t))
+		if len(rows) != 1 {
+			t.Fatalf("got %d rows events, want 1", len(rows))
+		}
+		if got := rows[0].ImageType(); got != tc.want {
+			t.Errorf("bitmap %#x: got image type %s, want %s", tc.bitmap, got, tc.want)
+		}
+	}
+}
+
+func TestSkipRowsEvents(t *testing.T) {
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	b.event(binlog.QueryEvent, queryBody("test", "BEGIN"))
+	userTableMap(b)
+	b.rows(binlog.WriteRowsEventV2, 100, 3, []byte{0x07}, nil, []byte{0x00, 1, 0, 0, 0, 1, 'a', 2, 0, 0, 0})
+	// the rows event of an unknown table fails if decoded
+	b.rows(binlog.WriteRowsEventV2, 200, 1, []byte{0x01}, nil, []byte{0x00})
+	b.event(binlog.XIDEvent, make([]byte, 8))
+	b.event(binlog.QueryEvent, queryBody("test", "ALTER TABLE user ADD COLUMN email VARCHAR(64)"))
+
+	events := b.walk(t, &binlog.BinReaderOption{
+		SkipRowsEvents:  true,
+		EventTypeFilter: func(eventType binlog.EventType) bool { return eventType == binlog.QueryEvent },
+	})
+	var queries []string
+	for _, event := range events {
+		if query, ok := event.Body.(*binlog.BinQueryEvent); ok {
+			queries = append(queries, query.Query)
+		} else if event.Header.EventType != binlog.FormatDescriptionEvent {
+			t.Errorf("got %s", event.Header.EventType)
+		}
+	}
+	want := []string{"BEGIN", "ALTER TABLE user ADD COLUMN email VARCHAR(64)"}
+	if !reflect.DeepEqual(queries, want) {
+		t.Errorf("got queries %q, want %q", queries, want)
+	}
+}
+
+func TestReplaceRows(t *testing.T) {
+	// REPLACE INTO user VALUES (1, 'b', 2) is logged as DELETE_ROWS_EVENT and WRITE_ROWS_EVENT
+	// of the same statement when the conflicting row can not be updated in place
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	userTableMap(b)
+	b.rows(binlog.DeleteRowsEventV2, 100, 3, []byte{0x07}, nil, []byte{0x00, 1, 0, 0, 0, 1, 'a', 2, 0, 0, 0})
+	b.rowsFlag = binlog.RowsEventStmtEndF
+	b.rows(binlog.WriteRowsEventV2, 100, 3, []byte{0x07}, nil, []byte{0x00, 1, 0, 0, 0, 1, 'b', 2, 0, 0, 0})
+
+	rows := rowsEvents(b.walk(t))
+	if len(rows) != 2 {
+		t.Fatalf("got %d rows events, want 2", len(rows))
+	}
+	if rows[0].Action() != binlog.RowsActionDelete || rows[0].StmtEnd() || rows[0].Rows[0]["@2"] != "a" {
+		t.Errorf("got first rows event %s %v, stmt end %v", rows[0].Action(), rows[0].Rows, rows[0].StmtEnd())
+	}
+	if rows[1].Action() != binlog.RowsActionInsert || !rows[1].StmtEnd() || rows[1].Rows[0]["@2"] != "b" {
+		t.Errorf("got second rows event %s %v, stmt end %v", rows[1].Action(), rows[1].Rows, rows[1].StmtEnd())
+	}
+}
+
+func TestNullBitmapOverPresentColumns(t *testing.T) {
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	types := make([]byte, 10)
+	for i := range types {
+		types[i] = binlog.MySQLTypeLong
+	}
+	b.tableMap(101, "test", "wide", types, nil, []byte{0xff, 0x03})
+
+	// columns 0, 2, 4, 6, 8, 9 are present, the NULL-bitmap has 1 byte for 6 present columns,
+	// bit 1 and bit 4 are the 2nd and 5th present columns, column 2 and column 8
+	b.rows(binlog.WriteRowsEventV2, 101, 10, []byte{0x55, 0x03}, nil, []byte{
+		0x12,
+		1, 0, 0, 0,
+		5, 0, 0, 0,
+		7, 0, 0, 0,
+		10, 0, 0, 0,
+	})
+
+	rows := rowsEvents(b.walk(t))
+	want := map[string]interface{}{"@1": int32(1), "@3": nil, "@5": int32(5), "@7": int32(7), "@9": nil, "@10": int32(10)}
+	if len(rows) != 1 || !reflect.DeepEqual(rows[0].Rows, []map[string]interface{}{want}) {
+		t.Fatalf("got rows %v, want %v", rows[0].Rows, want)
+	}
+}
+
+func TestLargeTableID(t *testing.T) {
+	for _, n := range []uint64{0xffffffff, 1 << 32, 1<<40 - 1, 1 << 40, 1<<48 - 1} {
+		if v := binlog.FixedLengthInt([]byte{byte(n), byte(n >> 8), byte(n >> 16), byte(n >> 24), byte(n >> 32), byte(n >> 40)}); v != n {
+			t.Errorf("got FixedLengthInt %d, want %d", v, n)
+		}
+
+		b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+		b.tableMap(n, "test", "user", []byte{binlog.MySQLTypeLong}, nil, []byte{0x00})
+		b.rows(binlog.WriteRowsEventV2, n, 1, []byte{0x01}, nil, []byte{0x00, 1, 0, 0, 0})
+
+		rows := rowsEvents(b.walk(t))
+		if len(rows) != 1 || rows[0].TableID != n || rows[0].TableMap().TableID != n {
+			t.Errorf("got rows events %v of table id %d", rows, n)
+		}
+	}
+}
+
+func TestTableMaps(t *testing.T) {
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	userTableMap(b)
+	b.tableMap(101, "test", "order", []byte{binlog.MySQLTypeLong}, nil, []byte{0x00})
+
+	decoder, err := binlog.NewBinFileDecoder(b.file(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = decoder.WalkEvent(func(event *binlog.BinEvent) (isContinue bool, err error) { return true, nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	tableMaps := decoder.TableMaps()
+	if len(tableMaps) != 2 || tableMaps[100].Table != "user" || tableMaps[101].Table != "order" {
+		t.Errorf("got table maps %v", tableMaps)
+	}
+
+	// the copy does not change the table maps of decoder
+	delete(tableMaps, 100)
+	if len(decoder.TableMaps()) != 2 {
+		t.Errorf("table maps of decoder are changed by the copy")
+	}
+}
+
+func TestTableMapSignedness(t *testing.T) {
+	// TINYINT UNSIGNED, VARCHAR(20), TINYINT, INT UNSIGNED, the bitmap only covers the numeric columns
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	b.tableMap(100, "test", "t",
+		[]byte{binlog.MySQLTypeTiny, binlog.MySQLTypeVarchar, binlog.MySQLTypeTiny, binlog.MySQLTypeLong},
+		[]byte{20, 0},
+		append([]byte{0x00}, optionalMeta(binlog.TableMapOptSignedness, 0xa0)...),
+	)
+	b.rows(binlog.WriteRowsEventV2, 100, 4, []byte{0x0f}, nil, []byte{0x00, 0xff, 1, 'a', 0xff, 0xff, 0xff, 0xff, 0xff})
+
+	rows := rowsEvents(b.walk(t))
+	want := map[string]interface{}{"@1": uint8(255), "@2": "a", "@3": int8(-1), "@4": uint32(0xffffffff)}
+	if len(rows) != 1 || !reflect.DeepEqual(rows[0].Rows[0], want) {
+		t.Errorf("got rows %v, want %v", rows, want)
+	}
+}
+
+func TestTableMapGeometryType(t *testing.T) {
+	// INT, POINT, GEOMETRY, the lengths of spatial values are stored in 4 bytes
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	b.tableMap(100, "test", "place",
+		[]byte{binlog.MySQLTypeLong, binlog.MySQLTypeGeometry, binlog.MySQLTypeGeometry},
+		[]byte{4, 4},
+		append([]byte{0x00}, optionalMeta(binlog.TableMapOptGeometryType, 1, 0)...),
+	)
+
+	// SRID 4326, little endian, POINT(1.5 -2)
+	point := []byte{0xe6, 0x10, 0, 0, 1, 1, 0, 0, 0}
+	point = append(point, make([]byte, 16)...)
+	binary.LittleEndian.PutUint64(point[9:], math.Float64bits(1.5))
+	binary.LittleEndian.PutUint64(point[17:], math.Float64bits(-2))
+	row := []byte{0x04, 1, 0, 0, 0, byte(len(point)), 0, 0, 0}
+	row = append(row, point...)
+	b.rows(binlog.WriteRowsEventV2, 100, 3, []byte{0x07}, nil, row)
+
+	rows := rowsEvents(b.walk(t))
+	if len(rows) != 1 {
+		t.Fatalf("got %d rows events, want 1", len(rows))
+	}
+	table := rows[0].TableMap()
+	if typ := table.ColumnMetaDef[1].GeometryType(); typ != binlog.GeometryPoint {
+		t.Errorf("got geometry type %s, want POINT", typ)
+	}
+	if typ := table.ColumnMetaDef[2].GeometryType(); typ != binlog.GeometryGeometry {
+		t.Errorf("got geometry type %s, want GEOMETRY", typ)
+	}
+
+	g, err := binlog.DecodeGeometry(rows[0].Rows[0]["@2"].([]byte))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.SRID != 4326 || g.Type != binlog.GeometryPoint || g.X != 1.5 || g.Y != -2 {
+		t.Errorf("got geometry %+v", g)
+	}
+}
+
+func TestFloatAndBlobMeta(t *testing.T) {
+	// FLOAT, BLOB, DOUBLE, VARCHAR(20), the meta of FLOAT and DOUBLE is the pack length
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	b.tableMap(100, "test", "measure",
+		[]byte{binlog.MySQLTypeFloat, binlog.MySQLTypeBlob, binlog.MySQLTypeDouble, binlog.MySQLTypeVarchar},
+		[]byte{4, 2, 8, 20, 0},
+		[]byte{0x00},
+	)
+	row := []byte{0x00}
+	row = appendUint32(row, math.Float32bits(1.5))
+	row = append(row, 2, 0, 'h', 'i')
+	bits := math.Float64bits(2.25)
+	row = appendUint32(appendUint32(row, uint32(bits)), uint32(bits>>32))
+	row = append(row, 1, 'z')
+	b.rows(binlog.WriteRowsEventV2, 100, 4, []byte{0x0f}, nil, row)
+
+	rows := rowsEvents(b.walk(t))
+	if len(rows) != 1 {
+		t.Fatalf("got %d rows events, want 1", len(rows))
+	}
+	want := map[string]interface{}{"@1": float32(1.5), "@2": []byte("hi"), "@3": float64(2.25), "@4": "z"}
+	if got := rows[0].Rows[0]; !reflect.DeepEqual(got, want) {
+		t.Errorf("got row %v, want %v", got, want)
+	}
+}
+
+func TestTypedArray(t *testing.T) {
+	// INT, typed array of BIGINT and VARCHAR(40) of multi-valued indexes, INT
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	b.tableMap(100, "test", "customer",
+		[]byte{binlog.MySQLTypeLong, binlog.MySQLTypeTypedArray, binlog.MySQLTypeTypedArray, binlog.MySQLTypeLong},
+		[]byte{binlog.MySQLTypeLonglong, binlog.MySQLTypeVarchar, 40, 0},
+		[]byte{0x00},
+	)
+	row := []byte{0x00, 1, 0, 0, 0}
+	row = append(row, 3, 0, 0, 0, 'a', 'b', 'c')
+	row = append(row, 2, 0, 0, 0, 'x', 'y')
+	row = append(row, 7, 0, 0, 0)
+	b.rows(binlog.WriteRowsEventV2, 100, 4, []byte{0x0f}, nil, row)
+
+	rows := rowsEvents(b.walk(t))
+	if len(rows) != 1 {
+		t.Fatalf("got %d rows events, want 1", len(rows))
+	}
+	table := rows[0].TableMap()
+	if typ := table.ColumnMetaDef[2].ElementType(); typ != binlog.MySQLTypeVarchar {
+		t.Errorf("got element type %d, want VARCHAR", typ)
+	}
+	want := map[string]interface{}{"@1": int32(1), "@2": []byte("abc"), "@3": []byte("xy"), "@4": int32(7)}
+	if got := rows[0].Rows[0]; !reflect.DeepEqual(got, want) {
+		t.Errorf("got row %v, want %v", got, want)
+	}
+}
+
+func TestTableMapEnumValues(t *testing.T) {
+	// ENUM('a','b','c'), SET('x','y','z'), ENUM('on','off'), all are stored as MYSQL_TYPE_STRING
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	meta := optionalMeta(binlog.TableMapOptEnumStrValue, 3, 1, 'a', 1, 'b', 1, 'c', 2, 2, 'o', 'n', 3, 'o', 'f', 'f')
+	meta = append(meta, optionalMeta(binlog.TableMapOptSetStrValue, 3, 1, 'x', 1, 'y', 1, 'z')...)
+	b.tableMap(100, "test", "t",
+		[]byte{binlog.MySQLTypeString, binlog.MySQLTypeString, binlog.MySQLTypeString},
+		[]byte{binlog.MySQLTypeEnum, 1, binlog.MySQLTypeSet, 1, binlog.MySQLTypeEnum, 1},
+		append([]byte{0x00}, meta...),
+	)
+	b.rows(binlog.WriteRowsEventV2, 100, 3, []byte{0x07}, nil, []byte{0x00, 2, 5, 1})
+
+	rows := rowsEvents(b.walk(t))
+	if len(rows) != 1 {
+		t.Fatalf("got %d rows events, want 1", len(rows))
+	}
+	if values := rows[0].TableMap().ColumnMetaDef[2].EnumValues(); !reflect.DeepEqual(values, []string{"on", "off"}) {
+		t.Errorf("got enum values %v", values)
+	}
+	want := map[string]interface{}{"@1": "b", "@2": "x,z", "@3": "on"}
+	if !reflect.DeepEqual(rows[0].Rows[0], want) {
+		t.Errorf("got rows %v, want %v", rows[0].Rows, want)
+	}
+}
+
+func TestTableMapCharset(t *testing.T) {
+	// INT, VARCHAR(20) utf8mb4, VARCHAR(20) latin1, BLOB, ENUM('a') latin1
+	types := []byte{binlog.MySQLTypeLong, binlog.MySQLTypeVarchar, binlog.MySQLTypeVarchar, binlog.MySQLTypeBlob, binlog.MySQLTypeString}
+	meta := []byte{20, 0, 20, 0, 2, binlog.MySQLTypeEnum, 1}
+	enumValues := optionalMeta(binlog.TableMapOptEnumStrValue, 1, 1, 'a')
+
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	// utf8mb4_0900_ai_ci(255) by default, except the 2nd latin1_swedish_ci(8) and the 3rd binary(63) string columns
+	defaultCharset := optionalMeta(binlog.TableMapOptDefaultCharset, 0xfc, 255, 0, 1, 8, 2, 63)
+	defaultCharset = append(defaultCharset, optionalMeta(binlog.TableMapOptEnumAndSetDefaultCharset, 8)...)
+	b.tableMap(100, "test", "t1", types, meta, append(append([]byte{0x00}, defaultCharset...), enumValues...))
+	// the collation of every string column
+	columnCharset := optionalMeta(binlog.TableMapOptColumnCharset, 0xfc, 255, 0, 8, 63)
+	columnCharset = append(columnCharset, optionalMeta(binlog.TableMapOptEnumAndSetColumnCharset, 8)...)
+	b.tableMap(101, "test", "t2", types, meta, append(append([]byte{0x00}, columnCharset...), enumValues...))
+
+	events := b.walk(t)
+	if len(events) != 3 {
+		t.Fatalf("got %d events, want 3", len(events))
+	}
+	for _, event := range events[1:] {
+		table := event.Body.(*binlog.BinTableMapEvent)
+		var collations []uint16
+		var charsets []string
+		for _, column := range table.ColumnMetaDef {
+			collations = append(collations, column.Collation())
+			charsets = append(charsets, column.Charset())
+		}
+		if want := []uint16{0, 255, 8, 63, 8}; !reflect.DeepEqual(collations, want) {
+			t.Errorf("%s: got collations %v, want %v", table.Table, collations, want)
+		}
+		if want := []string{"", "utf8mb4", "latin1", "binary", "latin1"}; !reflect.DeepEqual(charsets, want) {
+			t.Errorf("%s: got charsets %v, want %v", table.Table, charsets, want)
+		}
+	}
+
+	// the override index is beyond the string columns
+	b = newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	b.tableMap(100, "test", "t1", types, meta, append([]byte{0x00}, optionalMeta(binlog.TableMapOptDefaultCharset, 45, 3, 8)...))
+	decoder, err := binlog.NewBinFileDecoder(b.file(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := decoder.WalkEvent(func(event *binlog.BinEvent) (bool, error) { return true, nil }); err == nil {
+		t.Errorf("got no error of invalid charset column index")
+	}
+}
+
+func TestTableMapAfterAlter(t *testing.T) {
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	userTableMap(b)
+	b.rows(binlog.WriteRowsEventV2, 100, 3, []byte{0x07}, nil, []byte{0x04, 1, 0, 0, 0, 1, 'a'})
+	b.event(binlog.QueryEvent, queryBody("test", "ALTER TABLE user ADD COLUMN email VARCHAR(20) AFTER id"))
+	// the same table id with the new columns
+	b.tableMap(100, "test", "user",
+		[]byte{binlog.MySQLTypeLong, binlog.MySQLTypeVarchar, binlog.MySQLTypeVarchar, binlog.MySQLTypeLong},
+		[]byte{20, 0, 20, 0},
+		[]byte{0x0e},
+	)
+	b.rows(binlog.WriteRowsEventV2, 100, 4, []byte{0x0f}, nil, []byte{0x08, 2, 0, 0, 0, 1, 'e', 1, 'b'})
+	// the names follow the renamed table
+	b.event(binlog.QueryEvent, queryBody("test", "RENAME TABLE dept TO department"))
+	b.tableMap(101, "test", "department", []byte{binlog.MySQLTypeLong}, nil, []byte{0x00})
+	b.rows(binlog.WriteRowsEventV2, 101, 1, []byte{0x01}, nil, []byte{0x00, 3, 0, 0, 0})
+
+	columnNames := map[string][]string{"test.user": {"id", "name", "age"}, "test.dept": {"dept_no"}}
+	rows := rowsEvents(b.walk(t, &binlog.BinReaderOption{ColumnNames: columnNames}))
+	if len(rows) != 3 {
+		t.Fatalf("got %d rows events, want 3", len(rows))
+	}
+	want := []map[string]interface{}{
+		{"id": int32(1), "name": "a", "age": nil},
+		// the stale names are dropped by ALTER TABLE
+		{"@1": int32(2), "@2": "e", "@3": "b", "@4": nil},
+		{"dept_no": int32(3)},
+	}
+	for i := range want {
+		if !reflect.DeepEqual(rows[i].Rows[0], want[i]) {
+			t.Errorf("got row %v, want %v", rows[i].Rows[0], want[i])
+		}
+	}
+	if len(rows[1].TableMap().ColumnTypeDef) != 4 {
+		t.Errorf("got %d columns after ALTER TABLE, want 4", len(rows[1].TableMap().ColumnTypeDef))
+	}
+	if len(columnNames) != 2 || columnNames["test.user"] == nil {
+		t.Errorf("got column names of option changed %v", columnNames)
+	}
+}
+
+func TestTableMapAfterAlterAcrossRotate(t *testing.T) {
+	dir := t.TempDir()
+	first := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	first.event(binlog.QueryEvent, queryBody("test", "ALTER TABLE user ADD COLUMN email VARCHAR(20) AFTER id"))
+	rotate := make([]byte, 8)
+	binary.LittleEndian.PutUint64(rotate, 4)
+	first.event(binlog.RotateEvent, append(rotate, "mysql-bin.000002"...))
+	path := first.writeFile(t, filepath.Join(dir, "mysql-bin.000001"))
+
+	second := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	second.tableMap(100, "test", "user",
+		[]byte{binlog.MySQLTypeLong, binlog.MySQLTypeVarchar, binlog.MySQLTypeVarchar, binlog.MySQLTypeLong},
+		[]byte{20, 0, 20, 0},
+		[]byte{0x0e},
+	)
+	second.rows(binlog.WriteRowsEventV2, 100, 4, []byte{0x0f}, nil, []byte{0x08, 2, 0, 0, 0, 1, 'e', 1, 'b'})
+	second.writeFile(t, filepath.Join(dir, "mysql-bin.000002"))
+
+	option := &binlog.BinReaderOption{FollowRotate: true, ColumnNames: map[string][]string{"test.user": {"id", "name", "age"}}}
+	decoder, err := binlog.NewBinFileDecoder(path, option)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rows []*binlog.BinRowsEvent
+	err = decoder.WalkEvent(func(event *binlog.BinEvent) (isContinue bool, err error) {
+		if e, ok := event.Body.(*binlog.BinRowsEvent); ok {
+			rows = append(rows, e)
+		}
+		return true, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"@1": int32(2), "@2": "e", "@3": "b", "@4": nil}
+	if len(rows) != 1 || !reflect.DeepEqual(rows[0].Rows[0], want) {
+		t.Errorf("got rows %v in the rotated binary log, want %v", rows, want)
+	}
+}
+
+func TestWalkRowChanges(t *testing.T) {
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	userTableMap(b)
+	b.rows(binlog.WriteRowsEventV2, 100, 3, []byte{0x07}, nil, []byte{0x04, 1, 0, 0, 0, 1, 'a', 0x00, 2, 0, 0, 0, 1, 'b', 7, 0, 0, 0})
+	b.rows(binlog.UpdateRowsEventV2, 100, 3, []byte{0x07}, []byte{0x07}, []byte{0x04, 1, 0, 0, 0, 1, 'a', 0x04, 1, 0, 0, 0, 1, 'c'})
+	b.tableMap(101, "test", "other", []byte{binlog.MySQLTypeLong}, nil, []byte{0x00})
+	b.rows(binlog.WriteRowsEventV2, 101, 1, []byte{0x01}, nil, []byte{0x00, 3, 0, 0, 0})
+	b.rows(binlog.DeleteRowsEventV2, 100, 3, []byte{0x07}, nil, []byte{0x00, 2, 0, 0, 0, 1, 'b', 7, 0, 0, 0})
+
+	option := &binlog.BinReaderOption{ColumnNames: map[string][]string{"test.user": {"id", "name", "age"}}}
+	decoder, err := binlog.NewBinFileDecoder(b.file(t), option)
+	if err != nil {
+		t.Fatal(err)
+	}
+	anonymizer := binlog.NewAnonymizer([]string{"test.user.name"})
+	dropSecond := func(change binlog.RowChange) (binlog.RowChange, bool) {
+		return change, change.Action != binlog.RowsActionInsert || change.After["id"] != int32(2)
+	}
+
+	var changes []binlog.RowChange
+	err = decoder.WalkRowChanges(func(change binlog.RowChange) (isContinue bool, err error) {
+		changes = append(changes, change)
+		return true, nil
+	}, binlog.FilterTables("test.user"), dropSecond, anonymizer.Transform, binlog.ProjectColumns("id", "name"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		action        binlog.RowsAction
+		before, after map[string]interface{}
+	}{
+		{binlog.RowsActionInsert, nil, map[string]interface{}{"id": int32(1), "name": "X"}},
+		{binlog.RowsActionUpdate, map[string]interface{}{"id": int32(1), "name": "X"}, map[string]interface{}{"id": int32(1), "name": "X"}},
+		{binlog.RowsActionDelete, map[string]interface{}{"id": int32(2), "name": "X"}, nil},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("got %d changes, want %d", len(changes), len(want))
+	}
+	for i, w := range want {
+		change := changes[i]
+		if change.Action != w.action || change.Table != "user" || !reflect.DeepEqual(change.Before, w.before) || !reflect.DeepEqual(change.After, w.after) {
+			t.Errorf("change %d: got %s %s.%s %v => %v", i, change.Action, change.Schema, change.Table, change.Before, change.After)
+		}
+		if _, ok := change.Event.Body.(*binlog.BinRowsEvent); !ok || change.TableMap == nil {
+			t.Errorf("change %d: got event %v, table map %v", i, change.Event, change.TableMap)
+		}
+	}
+}
+
+func TestWideTable(t *testing.T) {
+	// 300 columns, VARCHAR(20) every 3 columns, others are INT, column count takes 3 bytes
+	const columns = 300
+	types := make([]byte, columns)
+	var meta []byte
+	for i := range types {
+		if i%3 == 0 {
+			types[i] = binlog.MySQLTypeVarchar
+			meta = append(meta, 20, 0)
+		} else {
+			types[i] = binlog.MySQLTypeLong
+		}
+	}
+	nullable := bytes.Repeat([]byte{0xff}, (columns+7)/8)
+
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	b.tableMap(100, "test", "wide", types, meta, nullable)
+
+	// column 150 and column 299 are NULL
+	present := bytes.Repeat([]byte{0xff}, (columns+7)/8)
+	present[len(present)-1] = 0x0f
+	nulls := make([]byte, (columns+7)/8)
+	nulls[150/8] |= 1 << (150 % 8)
+	nulls[299/8] |= 1 << (299 % 8)
+	row := append([]byte(nil), nulls...)
+	want := make(map[string]interface{}, columns)
+	for i := 0; i < columns; i++ {
+		name := fmt.Sprintf("@%d", i+1)
+		switch {
+		case i == 150 || i == 299:
+			want[name] = nil
+		case i%3 == 0:
+			v := strconv.Itoa(i)
+			row = append(append(row, byte(len(v))), v...)
+			want[name] = v
+		default:
+			row = append(row, byte(i), byte(i>>8), 0, 0)
+			want[name] = int32(i)
+		}
+	}
+	b.rows(binlog.WriteRowsEventV2, 100, columns, present, nil, row)
+	b.event(binlog.XIDEvent, make([]byte, 8))
+
+	events := b.walk(t)
+	rows := rowsEvents(events)
+	if len(rows) != 1 || len(rows[0].Rows) != 1 {
+		t.Fatalf("got rows events %v", rows)
+	}
+	table := rows[0].TableMap()
+	if table.ColumnCount != columns || len(table.ColumnMetaDef) != columns || len(table.NullBitmap) != (columns+7)/8 {
+		t.Errorf("got table map of %d columns, %d metas, null bitmap %d bytes", table.ColumnCount, len(table.ColumnMetaDef), len(table.NullBitmap))
+	}
+	if !reflect.DeepEqual(rows[0].Rows[0], want) {
+		t.Errorf("got row %v, want %v", rows[0].Rows[0], want)
+	}
+	if _, ok := events[len(events)-1].Body.(*binlog.BinXIDEvent); !ok {
+		t.Errorf("got last event %+v, want XID_EVENT", events[len(events)-1].Body)
+	}
+}
+
+func TestScanInto(t *testing.T) {
+	type user struct {
+		ID      int64  `binlog:"id"`
+		Name    string `binlog:"name"`
+		Age     *uint8 `binlog:"age"`
+		Ignored string `binlog:"-"`
+	}
+
+	names := optionalMeta(binlog.TableMapOptColumnName, 2, 'i', 'd', 4, 'n', 'a', 'm', 'e', 3, 'a', 'g', 'e')
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	b.tableMap(100, "test", "user",
+		[]byte{binlog.MySQLTypeLong, binlog.MySQLTypeVarchar, binlog.MySQLTypeLong},
+		[]byte{20, 0},
+		append([]byte{0x04}, names...),
+	)
+	b.rows(binlog.WriteRowsEventV2, 100, 3, []byte{0x07}, nil, []byte{
+		0x00, 1, 0, 0, 0, 1, 'a', 20, 0, 0, 0,
+		0x04, 2, 0, 0, 0, 1, 'b',
+	})
+	rows := rowsEvents(b.walk(t))
+
+	var users []*user
+	if err := rows[0].ScanInto(&users); err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 2 || users[0].ID != 1 || users[0].Name != "a" || users[0].Age == nil || *users[0].Age != 20 {
+		t.Errorf("got first user %+v", users[0])
+	}
+	if users[1].ID != 2 || users[1].Name != "b" || users[1].Age != nil {
+		t.Errorf("got second user %+v", users[1])
+	}
+
+	// -1 overflows uint8
+	rows[0].Rows[0]["age"] = int32(-1)
+	var values []user
+	if err := rows[0].ScanInto(&values); err == nil || !strings.Contains(err.Error(), "field Age") {
+		t.Errorf("got error %v, want conversion error of Age", err)
+	}
+	if err := rows[0].ScanInto(values); err == nil {
+		t.Errorf("got no error of non-pointer dest")
+	}
+}
+
+func TestInsertBatcher(t *testing.T) {
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	userTableMap(b)
+	b.tableMap(101, "test", "log", []byte{binlog.MySQLTypeLong}, nil, []byte{0x00})
+
+	// statement 1: 3 rows into test.user over 2 events, 1 row into test.log by trigger
+	b.rows(binlog.WriteRowsEventV2, 100, 3, []byte{0x07}, nil, []byte{0x04, 1, 0, 0, 0, 1, 'a', 0x04, 2, 0, 0, 0, 1, 'b'})
+	b.rows(binlog.WriteRowsEventV2, 101, 1, []byte{0x01}, nil, []byte{0x00, 9, 0, 0, 0})
+	b.rowsFlag = binlog.RowsEventStmtEndF
+	b.rows(binlog.WriteRowsEventV2, 100, 3, []byte{0x07}, nil, []byte{0x00, 3, 0, 0, 0, 1, 'c', 30, 0, 0, 0})
+	// statement 2: delete is ignored
+	b.rows(binlog.DeleteRowsEventV2, 100, 3, []byte{0x07}, nil, []byte{0x04, 1, 0, 0, 0, 1, 'a'})
+
+	batcher := &binlog.InsertBatcher{}
+	var statements [][]*binlog.InsertStatement
+	for _, e := range rowsEvents(b.walk(t)) {
+		if s := batcher.Add(e); s != nil {
+			statements = append(statements, s)
+		}
+	}
+	if len(statements) != 1 || len(statements[0]) != 2 {
+		t.Fatalf("got statements %v", statements)
+	}
+	want := []*binlog.InsertStatement{
+		{Schema: "test", Table: "user", Columns: []string{"@1", "@2", "@3"}, Values: [][]interface{}{
+			{int32(1), "a", nil}, {int32(2), "b", nil}, {int32(3), "c", int32(30)},
+		}},
+		{Schema: "test", Table: "log", Columns: []string{"@1"}, Values: [][]interface{}{{int32(9)}}},
+	}
+	if !reflect.DeepEqual(statements[0], want) {
+		t.Errorf("got statement %+v, want %+v", statements[0], want)
+	}
+	if s := batcher.Flush(); s != nil {
+		t.Errorf("got statements %v in progress", s)
+	}
+}
@@ -0,0 +1,227 @@
+package test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/obgnail/binlog-parser"
+)
+
+func TestRowsSQL(t *testing.T) {
+	names := optionalMeta(binlog.TableMapOptColumnName, 2, 'i', 'd', 4, 'n', 'a', 'm', 'e', 3, 'a', 'g', 'e')
+	pk := optionalMeta(binlog.TableMapOptSimplePrimaryKey, 0)
+
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	b.tableMap(100, "test", "user",
+		[]byte{binlog.MySQLTypeLong, binlog.MySQLTypeVarchar, binlog.MySQLTypeLong},
+		[]byte{20, 0},
+		append(append([]byte{0x04}, names...), pk...),
+	)
+	b.rows(binlog.WriteRowsEventV2, 100, 3, []byte{0x07}, nil, []byte{0x04, 1, 0, 0, 0, 3, 'b', 'o', 'b'})
+	b.rows(binlog.UpdateRowsEventV2, 100, 3, []byte{0x07}, []byte{0x07}, []byte{
+		0x04, 1, 0, 0, 0, 3, 'b', 'o', 'b',
+		0x00, 1, 0, 0, 0, 3, 'b', 'o', '\'', 18, 0, 0, 0,
+	})
+	b.rows(binlog.DeleteRowsEventV2, 100, 3, []byte{0x07}, nil, []byte{0x00, 1, 0, 0, 0, 3, 'b', 'o', '\'', 18, 0, 0, 0})
+
+	rows := rowsEvents(b.walk(t))
+	if len(rows) != 3 {
+		t.Fatalf("got %d rows events, want 3", len(rows))
+	}
+
+	for _, tc := range []struct {
+		option *binlog.SQLOption
+		want   []string
+	}{
+		{nil, []string{
+			"INSERT INTO `test`.`user` (`id`, `name`, `age`) VALUES (1, 'bob', NULL)",
+			"UPDATE `test`.`user` SET `id`=1, `name`='bo\\'', `age`=18 WHERE `id`=1",
+			"DELETE FROM `test`.`user` WHERE `id`=1",
+		}},
+		{&binlog.SQLOption{FullColumnWhere: true}, []string{
+			"INSERT INTO `test`.`user` (`id`, `name`, `age`) VALUES (1, 'bob', NULL)",
+			"UPDATE `test`.`user` SET `id`=1, `name`='bo\\'', `age`=18 WHERE `id`=1 AND `name`='bob' AND `age` IS NULL LIMIT 1",
+			"DELETE FROM `test`.`user` WHERE `id`=1 AND `name`='bo\\'' AND `age`=18 LIMIT 1",
+		}},
+	} {
+		var got []string
+		for _, e := range rows {
+			statements, err := e.SQL(tc.option)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, statements...)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("got SQL\n%q\nwant\n%q", got, tc.want)
+		}
+	}
+}
+
+func TestTimestampSQL(t *testing.T) {
+	names := optionalMeta(binlog.TableMapOptColumnName, 2, 'i', 'd', 2, 't', 's')
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	b.tableMap(100, "test", "event", []byte{binlog.MySQLTypeLong, binlog.MySQLTypeTimestamp2}, []byte{0}, append([]byte{0x00}, names...))
+	// the zero TIMESTAMP is stored as 0
+	b.rows(binlog.WriteRowsEventV2, 100, 2, []byte{0x03}, nil, append([]byte{0x00, 1, 0, 0, 0}, bigEndian(0, 4)...))
+	b.rows(binlog.WriteRowsEventV2, 100, 2, []byte{0x03}, nil, append([]byte{0x00, 2, 0, 0, 0}, bigEndian(1537611870, 4)...))
+
+	rows := rowsEvents(b.walk(t))
+	if len(rows) != 2 {
+		t.Fatalf("got %d rows events, want 2", len(rows))
+	}
+	for _, tc := range []struct {
+		option *binlog.SQLOption
+		want   []string
+	}{
+		{nil, []string{
+			"INSERT INTO `test`.`event` (`id`, `ts`) VALUES (1, '0000-00-00 00:00:00')",
+			"INSERT INTO `test`.`event` (`id`, `ts`) VALUES (2, '2018-09-22 10:24:30')",
+		}},
+		// the values are in UTC
+		{&binlog.SQLOption{SetTimeZone: true}, []string{
+			"SET time_zone='+00:00'",
+			"INSERT INTO `test`.`event` (`id`, `ts`) VALUES (1, '0000-00-00 00:00:00')",
+			"SET time_zone='+00:00'",
+			"INSERT INTO `test`.`event` (`id`, `ts`) VALUES (2, '2018-09-22 10:24:30')",
+		}},
+	} {
+		var got []string
+		for _, e := range rows {
+			statements, err := e.SQL(tc.option)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, statements...)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("got SQL\n%q\nwant\n%q", got, tc.want)
+		}
+	}
+}
+
+func TestInvisibleColumns(t *testing.T) {
+	names := optionalMeta(binlog.TableMapOptColumnName, 2, 'i', 'd', 4, 'n', 'a', 'm', 'e', 3, 'a', 'g', 'e')
+	// name is invisible
+	visibility := optionalMeta(binlog.TableMapOptColumnVisibility, 0xa0)
+
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	b.tableMap(100, "test", "user",
+		[]byte{binlog.MySQLTypeLong, binlog.MySQLTypeVarchar, binlog.MySQLTypeLong},
+		[]byte{20, 0},
+		append(append([]byte{0x04}, names...), visibility...),
+	)
+	b.rows(binlog.WriteRowsEventV2, 100, 3, []byte{0x07}, nil, []byte{0x00, 1, 0, 0, 0, 3, 'b', 'o', 'b', 18, 0, 0, 0})
+	userTableMap(b)
+	b.rows(binlog.WriteRowsEventV2, 100, 3, []byte{0x07}, nil, []byte{0x00, 1, 0, 0, 0, 3, 'b', 'o', 'b', 18, 0, 0, 0})
+
+	rows := rowsEvents(b.walk(t))
+	if len(rows) != 2 {
+		t.Fatalf("got %d rows events, want 2", len(rows))
+	}
+	var invisible []bool
+	for i := range rows[0].TableMap().ColumnMetaDef {
+		invisible = append(invisible, rows[0].TableMap().ColumnMetaDef[i].Invisible())
+	}
+	if !reflect.DeepEqual(invisible, []bool{false, true, false}) {
+		t.Errorf("got invisible columns %v", invisible)
+	}
+
+	statements, err := rows[0].SQL(&binlog.SQLOption{SkipInvisibleColumns: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "INSERT INTO `test`.`user` (`id`, `age`) VALUES (1, 18)"; len(statements) != 1 || statements[0] != want {
+		t.Errorf("got SQL %q, want %q", statements, want)
+	}
+
+	// all columns are visible without the visibility metadata
+	if statements, err = rows[1].SQL(&binlog.SQLOption{SkipInvisibleColumns: true}); err != nil || len(statements) != 1 ||
+		statements[0] != "INSERT INTO `test`.`user` (`@1`, `@2`, `@3`) VALUES (1, 'bob', 18)" {
+		t.Errorf("got SQL %q, error %v", statements, err)
+	}
+}
+
+func TestFormatRow(t *testing.T) {
+	// INT, VARCHAR(20), TIMESTAMP(3), TIME(2), BLOB, INT NULL
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	b.tableMap(100, "test", "t",
+		[]byte{binlog.MySQLTypeLong, binlog.MySQLTypeVarchar, binlog.MySQLTypeTimestamp2, binlog.MySQLTypeTime2, binlog.MySQLTypeBlob, binlog.MySQLTypeLong},
+		[]byte{20, 0, 3, 2, 2},
+		[]byte{0x3f},
+	)
+	row := []byte{0x20, 0xfb, 0xff, 0xff, 0xff, 1, 'a'}
+	row = append(row, bigEndian(1537611870, 4)...)
+	row = append(row, bigEndian(1230, 2)...)
+	row = append(row, bigEndian(0x800000+(1<<12|2<<6|3), 3)...)
+	row = append(row, 50, 2, 0, 0xde, 0xad)
+	b.rows(binlog.WriteRowsEventV2, 100, 6, []byte{0x3f}, nil, row)
+
+	rows := rowsEvents(b.walk(t))
+	if len(rows) != 1 {
+		t.Fatalf("got %d rows events, want 1", len(rows))
+	}
+	got := binlog.FormatRow(rows[0].Rows[0], rows[0].TableMap())
+	want := map[string]string{
+		"@1": "-5",
+		"@2": "a",
+		"@3": "2018-09-22 10:24:30.123",
+		"@4": "01:02:03.50",
+		"@5": "0xdead",
+		"@6": "NULL",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got formatted row %v, want %v", got, want)
+	}
+}
+
+func TestUnsignedBigint(t *testing.T) {
+	// BIGINT UNSIGNED, BIGINT, VARCHAR(20) NULL
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	b.tableMap(100, "test", "t",
+		[]byte{binlog.MySQLTypeLonglong, binlog.MySQLTypeLonglong, binlog.MySQLTypeVarchar},
+		[]byte{20, 0},
+		append([]byte{0x04}, optionalMeta(binlog.TableMapOptSignedness, 0x80)...),
+	)
+	row := []byte{0x04, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x80}
+	b.rows(binlog.WriteRowsEventV2, 100, 3, []byte{0x07}, nil, row)
+
+	rows := rowsEvents(b.walk(t))
+	if len(rows) != 1 {
+		t.Fatalf("got %d rows events, want 1", len(rows))
+	}
+	if v := rows[0].Rows[0]["@1"]; v != uint64(18446744073709551615) {
+		t.Errorf("got value %v (%T)", v, v)
+	}
+
+	statements, err := rows[0].SQL(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "INSERT INTO `test`.`t` (`@1`, `@2`, `@3`) VALUES (18446744073709551615, -9223372036854775808, NULL)"; len(statements) != 1 || statements[0] != want {
+		t.Errorf("got statements %v, want %s", statements, want)
+	}
+	if got := binlog.FormatRow(rows[0].Rows[0], rows[0].TableMap()); got["@1"] != "18446744073709551615" || got["@2"] != "-9223372036854775808" {
+		t.Errorf("got formatted row %v", got)
+	}
+
+	doc, err := binlog.RowJSON(rows[0].Rows[0], rows[0].TableMap())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"@1":"18446744073709551615","@2":"-9223372036854775808","@3":null}`; string(doc) != want {
+		t.Errorf("got json %s, want %s", doc, want)
+	}
+
+	// the integers of JSON column, and the safe integers are numbers
+	doc, err = binlog.RowJSON(map[string]interface{}{
+		"id":  uint64(1) << 53,
+		"doc": map[string]interface{}{"a": []interface{}{uint64(18446744073709551615), int64(7)}},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"doc":{"a":["18446744073709551615",7]},"id":"9007199254740992"}`; string(doc) != want {
+		t.Errorf("got json %s, want %s", doc, want)
+	}
+}
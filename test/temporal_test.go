@@ -0,0 +1,97 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/obgnail/binlog-parser"
+)
+
+// decodeColumn return the decoded value of a single column table
+func decodeColumn(t *testing.T, columnType byte, meta []byte, value []byte) interface{} {
+	b := newBinlogBuilder(binlog.BinlogChecksumAlgCRC32)
+	b.tableMap(1, "test", "t", []byte{columnType}, meta, []byte{0x01})
+	b.rows(binlog.WriteRowsEventV2, 1, 1, []byte{0x01}, nil, append([]byte{0x00}, value...))
+
+	rows := rowsEvents(b.walk(t))
+	if len(rows) != 1 || len(rows[0].Rows) != 1 {
+		t.Fatalf("got rows events %v", rows)
+	}
+	return rows[0].Rows[0]["@1"]
+}
+
+// bigEndian return the n bytes big-endian of v
+func bigEndian(v uint64, n int) []byte {
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(v >> (8 * uint(n-i-1)))
+	}
+	return data
+}
+
+func TestTimestamp2(t *testing.T) {
+	cases := []struct {
+		fsp  byte
+		frac []byte
+		usec int
+	}{
+		{0, nil, 0},
+		{3, bigEndian(1230, 2), 123000},
+		{6, bigEndian(123456, 3), 123456},
+	}
+	for _, c := range cases {
+		v := decodeColumn(t, binlog.MySQLTypeTimestamp2, []byte{c.fsp}, append(bigEndian(1537611870, 4), c.frac...))
+		want := time.Unix(1537611870, int64(c.usec)*int64(time.Microsecond)).UTC()
+		if ts, ok := v.(time.Time); !ok || !ts.Equal(want) {
+			t.Errorf("fsp %d got %v, want %v", c.fsp, v, want)
+		}
+	}
+}
+
+func TestDatetime2(t *testing.T) {
+	// 2018-09-22 10:24:30
+	ymd := uint64((2018*13+9)<<5 | 22)
+	intPart := ymd<<17 | 10<<12 | 24<<6 | 30 + 0x8000000000
+
+	cases := []struct {
+		fsp  byte
+		frac []byte
+		want string
+	}{
+		{0, nil, "2018-09-22 10:24:30"},
+		{3, bigEndian(1230, 2), "2018-09-22 10:24:30.123"},
+		{6, bigEndian(123456, 3), "2018-09-22 10:24:30.123456"},
+	}
+	for _, c := range cases {
+		v := decodeColumn(t, binlog.MySQLTypeDatetime2, []byte{c.fsp}, append(bigEndian(intPart, 5), c.frac...))
+		if v != c.want {
+			t.Errorf("fsp %d got %v, want %s", c.fsp, v, c.want)
+		}
+	}
+}
+
+func TestTime2(t *testing.T) {
+	const offset = 0x800000
+	maxTime := uint64(838<<12 | 59<<6 | 59)
+
+	cases := []struct {
+		name  string
+		fsp   byte
+		value []byte
+		want  time.Duration
+	}{
+		{"-00:00:01", 0, bigEndian(offset-1, 3), -time.Second},
+		{"838:59:59", 0, bigEndian(offset+maxTime, 3), 838*time.Hour + 59*time.Minute + 59*time.Second},
+		{"-838:59:59", 0, bigEndian(offset-maxTime, 3), -(838*time.Hour + 59*time.Minute + 59*time.Second)},
+		// the integer part is floored, the fractional part is stored as negative
+		{"-00:00:01.5", 1, append(bigEndian(offset-2, 3), byte(256-50)), -1500 * time.Millisecond},
+		{"-00:00:01.234", 3, append(bigEndian(offset-2, 3), bigEndian(65536-2340, 2)...), -1234 * time.Millisecond},
+		{"-00:00:01.000001", 6, bigEndian(0x800000000000-(1<<24+1), 6), -(time.Second + time.Microsecond)},
+	}
+	for _, c := range cases {
+		v := decodeColumn(t, binlog.MySQLTypeTime2, []byte{c.fsp}, c.value)
+		if v != c.want {
+			t.Errorf("%s got %v, want %v", c.name, v, c.want)
+		}
+	}
+}
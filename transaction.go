@@ -0,0 +1,338 @@
+package binlog
+
+import (
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TransactionIDKind is the discriminator of TransactionID
+type TransactionIDKind int
+
+const (
+	// TransactionIDNone means the transaction has no identifier, e.g. committed by QUERY_EVENT
+	TransactionIDNone TransactionIDKind = iota
+	// TransactionIDXID is the XID of XID_EVENT
+	TransactionIDXID
+	// TransactionIDXA is the xid of XA transaction, gtrid, bqual and formatID
+	TransactionIDXA
+)
+
+// TransactionID is the identifier of normal and XA transactions
+type TransactionID struct {
+	Kind TransactionIDKind
+
+	// if TransactionIDXID
+	XID uint64
+
+	// if TransactionIDXA
+	FormatID int32
+	GTRID    []byte
+	BQUAL    []byte
+}
+
+// String return the XID in decimal, or the XA xid in the form of XA RECOVER CONVERT XID,
+// e.g. X'6774726964',X'6271',1
+func (id *TransactionID) String() string {
+	switch id.Kind {
+	case TransactionIDXID:
+		return fmt.Sprint(id.XID)
+	case TransactionIDXA:
+		return fmt.Sprintf("X'%s',X'%s',%d", hex.EncodeToString(id.GTRID), hex.EncodeToString(id.BQUAL), id.FormatID)
+	}
+	return ""
+}
+
+// TransactionEnd is the commit of a transaction, e.g. a checkpoint of transaction boundary
+type TransactionEnd struct {
+	XID       uint64 // zero if the transaction is committed by QUERY_EVENT, e.g. non-transactional engine
+	ID        TransactionID
+	Position  int64 // end position of the commit event, where the next transaction starts
+	Timestamp time.Time
+}
+
+// TransactionEnd return the TransactionEnd if the event commits a transaction,
+// XID_EVENT for InnoDB, XA_PREPARE_LOG_EVENT for XA transaction,
+// or QUERY_EVENT of COMMIT for non-transactional engines
+func (event *BinEvent) TransactionEnd() (*TransactionEnd, bool) {
+	end := &TransactionEnd{Position: event.Header.LogPos, Timestamp: event.Time()}
+	switch body := event.Body.(type) {
+	case *BinXIDEvent:
+		end.XID = body.XID
+		end.ID = TransactionID{Kind: TransactionIDXID, XID: body.XID}
+		return end, true
+	case *BinXAPrepareEvent:
+		end.ID = TransactionID{Kind: TransactionIDXA, FormatID: body.FormatID, GTRID: body.GTRID, BQUAL: body.BQUAL}
+		return end, true
+	case *BinQueryEvent:
+		if strings.EqualFold(strings.TrimSpace(body.Query), "COMMIT") {
+			return end, true
+		}
+	}
+	return nil, false
+}
+
+// CommitOrder is the ordering key of transaction, composed of the binary log coordinates of commit event.
+// It is monotonic in the commit order of a server, since the coordinates only grow. Across servers
+// the order is deterministic but not chronological, the ties are broken by ServerID, see Timestamp.
+type CommitOrder struct {
+	FileIndex uint64 // the number of binary log name, e.g. 4 of mysql-bin.000004
+	Position  int64  // end position of the commit event
+	ServerID  int64  // server_id of the commit event
+
+	// informational, not compared
+	File           string
+	SequenceNumber int64     // logical clock of GTID_EVENT, reset by each binary log, 0 if absent
+	Timestamp      time.Time // commit time, with microseconds if known
+}
+
+// Compare return -1, 0 or 1 if order is before, equal to or after other
+func (order CommitOrder) Compare(other CommitOrder) int {
+	switch {
+	case order.FileIndex != other.FileIndex:
+		return compareUint64(order.FileIndex, other.FileIndex)
+	case order.Position != other.Position:
+		return compareUint64(uint64(order.Position), uint64(other.Position))
+	}
+	return compareUint64(uint64(order.ServerID), uint64(other.ServerID))
+}
+
+// Less return bool of if order is before other, e.g. sort.Slice
+func (order CommitOrder) Less(other CommitOrder) bool {
+	return order.Compare(other) < 0
+}
+
+// String return the coordinates, e.g. mysql-bin.000004:1234
+func (order CommitOrder) String() string {
+	return fmt.Sprintf("%s:%d", order.File, order.Position)
+}
+
+func compareUint64(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// Transaction is the events of a transaction, from GTID_EVENT or BEGIN to the commit,
+// or a single DDL QUERY_EVENT with its GTID_EVENT
+type Transaction struct {
+	GTID     string // empty if GTID mode is off
+	Events   []*BinEvent
+	End      *TransactionEnd
+	Rollback bool // ended by ROLLBACK, e.g. the non-transactional changes of a rolled back transaction
+	Order    CommitOrder
+
+	// the transaction exceeds BinReaderOption.MaxTransactionSize and continues in the next Transaction,
+	// End, Rollback and Order are only set in the last part. Part is the index of part, 0 if not split
+	Continues bool
+	Part      int
+}
+
+// WalkTransaction will walk the binary log by transactions, see WalkEvent. The events out of
+// transactions (e.g. FORMAT_DESCRIPTION_EVENT, ROTATE_EVENT) are not included, and the incomplete
+// transaction at the end of binary log is dropped. The events of a transaction are buffered until
+// its commit, see BinReaderOption.MaxTransactionSize to bound the memory of huge transactions.
+func (decoder *BinFileDecoder) WalkTransaction(f func(tx *Transaction) (isContinue bool, err error)) error {
+	file := filepath.Base(decoder.Path)
+	var tx *Transaction
+	var began bool
+	var sequence, size int64
+	var maxSize int64
+	var stream bool
+	if decoder.Option != nil {
+		maxSize, stream = decoder.Option.MaxTransactionSize, decoder.Option.StreamLargeTransactions
+	}
+
+	return decoder.WalkEvent(func(event *BinEvent) (isContinue bool, err error) {
+		var end *TransactionEnd
+		rollback := false
+		switch body := event.Body.(type) {
+		case *BinRotateEvent:
+			file = body.FileName
+			return true, nil
+		case *BinFmtDescEvent, *BinPreGTIDsEvent, *BinHeartbeatEvent, *BinStopEvent:
+			return true, nil
+		case *BinGTIDEvent:
+			// a new transaction, the previous one is incomplete if not ended
+			tx, began, sequence, size = &Transaction{}, false, body.SequenceNumber, 0
+			if event.Header.EventType == GTIDEvent {
+				tx.GTID = body.GTID()
+			}
+		case *BinQueryEvent:
+			query := strings.ToUpper(strings.TrimSpace(body.Query))
+			switch {
+			case query == "BEGIN", strings.HasPrefix(query, "XA START"):
+				began = true
+			case query == "ROLLBACK":
+				rollback = true
+				end = &TransactionEnd{Position: event.Header.LogPos, Timestamp: event.Time()}
+			case !began:
+				// DDL commits implicitly
+				end = &TransactionEnd{Position: event.Header.LogPos, Timestamp: event.Time()}
+			}
+		}
+		if e, ok := event.TransactionEnd(); ok {
+			end = e
+		}
+
+		if tx == nil {
+			tx = &Transaction{}
+		}
+		tx.Events = append(tx.Events, event)
+		size += event.Header.EventSize
+		if end == nil {
+			if maxSize <= 0 || size <= maxSize {
+				return true, nil
+			}
+			if !stream {
+				return false, fmt.Errorf("%w: %d bytes buffered at %s:%d, limit %d",
+					ErrTransactionTooLarge, size, file, event.EndPosition(), maxSize)
+			}
+			part := tx
+			part.Continues = true
+			tx, size = &Transaction{GTID: part.GTID, Part: part.Part + 1}, 0
+			return f(part)
+		}
+
+		tx.End, tx.Rollback = end, rollback
+		tx.Order = CommitOrder{
+			Position:       event.EndPosition(),
+			ServerID:       event.Header.ServerID,
+			File:           file,
+			SequenceNumber: sequence,
+			Timestamp:      end.Timestamp,
+		}
+		tx.Order.FileIndex, _ = binlogIndex(file)
+
+		committed := tx
+		tx, began, sequence, size = nil, false, 0, 0
+		return f(committed)
+	})
+}
+
+// String render the transaction as a readable block like a binary log inspector, e.g.
+//
+//	GTID 3e11fa47-71ca-11e1-9e33-c80aa9429562:1, XID 5, committed at 2018-09-22 18:24:30.123456 +0800 (mysql-bin.000001:1234)
+//	  [test] BEGIN
+//	  INSERT `test`.`user` (`id`=1, `name`='a')
+//	  UPDATE `test`.`user` (`id`=1, `name`='a') => (`id`=1, `name`='b')
+//	  COMMIT /* XID 5 */
+//
+// The SET statements of INTVAR, RAND and USER_VAR events are rendered before their queries.
+// The parts of a split transaction are headed by ", part N", and ", continues" without commit if not the last.
+func (tx *Transaction) String() string {
+	var b strings.Builder
+	if tx.GTID != "" {
+		b.WriteString("GTID " + tx.GTID)
+	} else {
+		b.WriteString("ANONYMOUS")
+	}
+	if tx.End != nil {
+		switch tx.End.ID.Kind {
+		case TransactionIDXID:
+			b.WriteString(", XID " + tx.End.ID.String())
+		case TransactionIDXA:
+			b.WriteString(", XA " + tx.End.ID.String())
+		}
+	}
+	if tx.Part > 0 || tx.Continues {
+		fmt.Fprintf(&b, ", part %d", tx.Part)
+	}
+	if tx.Continues {
+		b.WriteString(", continues\n")
+	} else {
+		if tx.Rollback {
+			b.WriteString(", rolled back")
+		} else {
+			b.WriteString(", committed")
+		}
+		fmt.Fprintf(&b, " at %s (%s)\n", tx.Order.Timestamp.Format("2006-01-02 15:04:05.999999 -0700"), tx.Order)
+	}
+
+	for _, event := range tx.Events {
+		for _, line := range formatTransactionEvent(event) {
+			b.WriteString("  " + line + "\n")
+		}
+	}
+	return b.String()
+}
+
+// formatTransactionEvent return the lines of event in Transaction.String, nil if the event is not rendered
+func formatTransactionEvent(event *BinEvent) []string {
+	switch body := event.Body.(type) {
+	case *BinGTIDEvent, *BinTableMapEvent, *BinIntvarEvent, *BinRandEvent, *BinUserVarEvent:
+		// rendered in the header, the rows or the SET statements
+		return nil
+	case *BinQueryEvent:
+		lines := body.Context.SetStatements()
+		query := body.Query
+		if body.Schema != "" {
+			query = "[" + body.Schema + "] " + query
+		}
+		if body.Failed() {
+			query += fmt.Sprintf(" /* error %d: %s */", body.ErrorCode, body.ErrorMessage())
+		}
+		return append(lines, query)
+	case *BinRowsEvent:
+		return formatRows(body)
+	case *BinXIDEvent:
+		return []string{fmt.Sprintf("COMMIT /* XID %d */", body.XID)}
+	case *BinXAPrepareEvent:
+		id := TransactionID{Kind: TransactionIDXA, FormatID: body.FormatID, GTRID: body.GTRID, BQUAL: body.BQUAL}
+		if body.OnePhase {
+			return []string{"XA COMMIT " + id.String() + " ONE PHASE"}
+		}
+		return []string{"XA PREPARE " + id.String()}
+	}
+	return []string{event.Header.Type()}
+}
+
+// formatRows return a line per row of rows event, the before and after images of UPDATE in the same line
+func formatRows(e *BinRowsEvent) []string {
+	table := "`?`"
+	if t := e.TableMap(); t != nil {
+		table = quoteIdentifier(t.Schema) + "." + quoteIdentifier(t.Table)
+	}
+	prefix := e.Action().String() + " " + table + " "
+
+	var lines []string
+	for i := 0; i < len(e.Rows); i++ {
+		if e.Action() == RowsActionUpdate && i+1 < len(e.Rows) {
+			lines = append(lines, prefix+formatImage(e.Rows[i], e.TableMap())+" => "+formatImage(e.Rows[i+1], e.TableMap()))
+			i++
+			continue
+		}
+		lines = append(lines, prefix+formatImage(e.Rows[i], e.TableMap()))
+	}
+	return lines
+}
+
+// formatImage render the columns of row image in the order of table, e.g. (`id`=1, `name`='a')
+func formatImage(row map[string]interface{}, table *BinTableMapEvent) string {
+	var names []string
+	if table != nil {
+		for i := range table.ColumnMetaDef {
+			if _, ok := row[table.ColumnName(i)]; ok {
+				names = append(names, table.ColumnName(i))
+			}
+		}
+	} else {
+		for name := range row {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	columns := make([]string, 0, len(names))
+	for _, name := range names {
+		columns = append(columns, quoteIdentifier(name)+"="+formatSQLValue(row[name]))
+	}
+	return "(" + strings.Join(columns, ", ") + ")"
+}
@@ -1,24 +1,19 @@
 package binlog
 
 import (
+	"encoding/binary"
 	"io"
 )
 
-// ReadNBytes read n bytes from io.Reader
+// ReadNBytes read n bytes from io.Reader,
+// return io.EOF if nothing is read, io.ErrUnexpectedEOF with the partial bytes if less than n bytes are read
 func ReadNBytes(rd io.Reader, size int64) ([]byte, error) {
 	data := make([]byte, size)
-	n, err := rd.Read(data)
-	total := n
-	for int64(total) < size && err == nil {
-		n, err = rd.Read(data[total:])
-		total += n
-	}
-
-	if n == 0 && err != nil {
+	n, err := io.ReadFull(rd, data)
+	if err == io.EOF {
 		return nil, err
 	}
-
-	return data, err
+	return data[:n], err
 }
 
 // FixedLengthInt will turn byte to uint64
@@ -31,6 +26,16 @@ func FixedLengthInt(buf []byte) uint64 {
 	return num
 }
 
+// BFixedLengthInt will turn big-endian byte to uint64
+// this function is from 'github.com/siddontang/go-mysql/replication/util.go'
+func BFixedLengthInt(buf []byte) uint64 {
+	var num uint64
+	for i, b := range buf {
+		num |= uint64(b) << (uint(len(buf)-i-1) * 8)
+	}
+	return num
+}
+
 // LengthEncodedInt will decode byte to uint64
 // this function is from 'github.com/siddontang/go-mysql/replication/util.go'
 func LengthEncodedInt(b []byte) (num uint64, isNull bool, n int) {
@@ -68,16 +73,31 @@ func LengthEncodedInt(b []byte) (num uint64, isNull bool, n int) {
 // LengthEncodedString will decode bytes
 func LengthEncodedString(b []byte) ([]byte, bool, int, error) {
 	// Get length
-	num, isNull, n := LengthEncodedInt(b)
+	num, n, err := readLengthEncodedInt(b, 0)
+	if err != nil {
+		return nil, false, n, err
+	}
 	if num < 1 {
-		return nil, isNull, n, nil
+		return nil, b[0] == 0xfb, n, nil
 	}
 
-	n += int(num)
-
 	// Check data length
-	if len(b) >= n {
-		return b[n-int(num) : n], false, n, nil
+	if uint64(len(b)-n) < num {
+		return nil, false, n + int(num), io.EOF
 	}
-	return nil, false, n, io.EOF
+	return b[n : n+int(num)], false, n + int(num), nil
+}
+
+// appendUint32 append v in little endian
+func appendUint32(data []byte, v uint32) []byte {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], v)
+	return append(data, b[:]...)
+}
+
+// appendUint64 append v in little endian
+func appendUint64(data []byte, v uint64) []byte {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], v)
+	return append(data, b[:]...)
 }
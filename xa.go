@@ -0,0 +1,214 @@
+package binlog
+
+import (
+	"encoding/hex"
+	"strconv"
+	"strings"
+)
+
+// XAAction is the action of XA statement
+type XAAction int
+
+// XA statements
+const (
+	XAUnknown XAAction = iota
+	XAStart
+	XAEnd
+	XAPrepare
+	XACommit
+	XARollback
+)
+
+var xaAction2Str = map[XAAction]string{
+	XAUnknown:  "UNKNOWN",
+	XAStart:    "XA START",
+	XAEnd:      "XA END",
+	XAPrepare:  "XA PREPARE",
+	XACommit:   "XA COMMIT",
+	XARollback: "XA ROLLBACK",
+}
+
+// String return the statement of action
+func (action XAAction) String() string {
+	return xaAction2Str[action]
+}
+
+// ParseXA parse the XA statement of QUERY_EVENT, return the action and the xid, e.g.
+// XA COMMIT X'6774726964',X'6271',1 which is logged by server, or XA COMMIT 'gtrid','bqual',1.
+// bqual is empty and formatID is 1 if omitted. return false if query is not XA statement.
+func ParseXA(query string) (XAAction, TransactionID, bool) {
+	id := TransactionID{Kind: TransactionIDXA, FormatID: 1}
+	tokens := tokenizeSQL(query)
+	if len(tokens) < 3 || tokens[0].quoted || !strings.EqualFold(tokens[0].text, "XA") {
+		return XAUnknown, id, false
+	}
+
+	var action XAAction
+	switch strings.ToUpper(tokens[1].text) {
+	case "START", "BEGIN":
+		action = XAStart
+	case "END":
+		action = XAEnd
+	case "PREPARE":
+		action = XAPrepare
+	case "COMMIT":
+		action = XACommit
+	case "ROLLBACK":
+		action = XARollback
+	default:
+		return XAUnknown, id, false
+	}
+
+	// gtrid [, bqual [, formatID]]
+	tokens = tokens[2:]
+	for i := 0; i < 3 && len(tokens) > 0; i++ {
+		if i > 0 {
+			if tokens[0].text != "," || tokens[0].quoted {
+				break
+			}
+			tokens = tokens[1:]
+		}
+		if i == 2 {
+			if len(tokens) == 0 {
+				return XAUnknown, id, false
+			}
+			formatID, err := strconv.ParseInt(tokens[0].text, 10, 32)
+			if err != nil {
+				return XAUnknown, id, false
+			}
+			id.FormatID = int32(formatID)
+			break
+		}
+
+		value, n, ok := xaString(tokens)
+		if !ok {
+			return XAUnknown, id, false
+		}
+		if i == 0 {
+			id.GTRID = value
+		} else {
+			id.BQUAL = value
+		}
+		tokens = tokens[n:]
+	}
+	if id.GTRID == nil {
+		return XAUnknown, id, false
+	}
+	if id.BQUAL == nil {
+		id.BQUAL = []byte{}
+	}
+	return action, id, true
+}
+
+// xaString parse the string of xid, 'str', X'hex' or 0xhex, return the bytes and the number of tokens
+func xaString(tokens []sqlToken) ([]byte, int, bool) {
+	if len(tokens) == 0 {
+		return nil, 0, false
+	}
+	token := tokens[0]
+	switch {
+	case token.quoted:
+		return []byte(token.text), 1, true
+	case strings.EqualFold(token.text, "X") && len(tokens) > 1 && tokens[1].quoted:
+		value, err := hex.DecodeString(tokens[1].text)
+		return value, 2, err == nil
+	case len(token.text) > 2 && strings.EqualFold(token.text[:2], "0x"):
+		value, err := hex.DecodeString(token.text[2:])
+		return value, 1, err == nil
+	}
+	return nil, 0, false
+}
+
+// XAState is the state of XA transaction in binary log
+type XAState int
+
+// XA transaction states
+const (
+	XAStatePrepared XAState = iota
+	XAStateCommitted
+	XAStateRolledBack
+)
+
+var xaState2Str = map[XAState]string{
+	XAStatePrepared:   "prepared",
+	XAStateCommitted:  "committed",
+	XAStateRolledBack: "rolled back",
+}
+
+// String return the name of state
+func (state XAState) String() string {
+	return xaState2Str[state]
+}
+
+// XATransaction is the lifecycle of XA transaction, from XA_PREPARE_LOG_EVENT to XA COMMIT or XA ROLLBACK,
+// which are logged as separate transactions, maybe in different binary logs.
+type XATransaction struct {
+	ID    TransactionID
+	State XAState
+
+	// XA_PREPARE_LOG_EVENT, nil if the prepare is not seen, e.g. it is in the earlier binary log
+	Prepare *BinEvent
+	// QUERY_EVENT of XA COMMIT or XA ROLLBACK, nil if prepared, or committed by XA COMMIT ... ONE PHASE
+	End *BinEvent
+
+	// committed by XA COMMIT ... ONE PHASE, which is logged as a XA_PREPARE_LOG_EVENT without prepare
+	OnePhase bool
+}
+
+// XATracker correlate the XA_PREPARE_LOG_EVENTs with their XA COMMIT or XA ROLLBACK, e.g. replicate
+// XA transactions in the right relationship. Feed the events in order, it could be kept across
+// binary logs, the zero value is ready to use.
+type XATracker struct {
+	prepared map[string]*XATransaction
+}
+
+// Track will track the event, return the XA transaction whose state is changed by the event,
+// false if the event is neither XA_PREPARE_LOG_EVENT nor XA COMMIT or XA ROLLBACK
+func (t *XATracker) Track(event *BinEvent) (*XATransaction, bool) {
+	if t.prepared == nil {
+		t.prepared = make(map[string]*XATransaction)
+	}
+
+	switch body := event.Body.(type) {
+	case *BinXAPrepareEvent:
+		tx := &XATransaction{
+			ID:       TransactionID{Kind: TransactionIDXA, FormatID: body.FormatID, GTRID: body.GTRID, BQUAL: body.BQUAL},
+			State:    XAStatePrepared,
+			Prepare:  event,
+			OnePhase: body.OnePhase,
+		}
+		if body.OnePhase {
+			tx.State = XAStateCommitted
+			return tx, true
+		}
+		t.prepared[tx.ID.String()] = tx
+		return tx, true
+
+	case *BinQueryEvent:
+		action, id, ok := ParseXA(body.Query)
+		if !ok || action != XACommit && action != XARollback {
+			return nil, false
+		}
+		tx, ok := t.prepared[id.String()]
+		if ok {
+			delete(t.prepared, id.String())
+		} else {
+			tx = &XATransaction{ID: id}
+		}
+		tx.End, tx.State = event, XAStateCommitted
+		if action == XARollback {
+			tx.State = XAStateRolledBack
+		}
+		return tx, true
+	}
+	return nil, false
+}
+
+// Prepared return the XA transactions prepared but not committed or rolled back yet, in no particular order
+func (t *XATracker) Prepared() []*XATransaction {
+	txs := make([]*XATransaction, 0, len(t.prepared))
+	for _, tx := range t.prepared {
+		txs = append(txs, tx)
+	}
+	return txs
+}